@@ -30,6 +30,27 @@ var logger *log.Logger = nil
 var logFile *os.File = nil
 
 func NewClient(config *cf.Configuration) (*photon.Client, error) {
+	return newClient(config, updateToken)
+}
+
+// Creates a photon client for the target saved in the given profile.
+// Refreshed access tokens are written back to the profile.
+func NewProfileClient(name string) (*photon.Client, error) {
+	config, err := cf.LoadProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(config, func(newToken string) {
+		config.Token = newToken
+		err := cf.SaveProfile(name, config)
+		if err != nil {
+			fmt.Printf("Could not save profile '%s' with refreshed token: %s", name, err)
+		}
+	})
+}
+
+func newClient(config *cf.Configuration, tokenCallback func(string)) (*photon.Client, error) {
 	if len(config.CloudTarget) == 0 {
 		return nil, errors.New("Specify a Photon Controller endpoint by running 'target set' command")
 	}
@@ -40,7 +61,7 @@ func NewClient(config *cf.Configuration) (*photon.Client, error) {
 			AccessToken:  config.Token,
 			RefreshToken: config.RefreshToken,
 		},
-		UpdateAccessTokenCallback: updateToken,
+		UpdateAccessTokenCallback: tokenCallback,
 	}
 
 	//
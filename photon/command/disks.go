@@ -47,7 +47,8 @@ func GetDiskCommand() cli.Command {
 					"   specify the appropriate flavor.\n\n" +
 					"   Example:\n" +
 					"     photon disk create --name persistent-disk-1 --flavor disk-flavor --capacityGB 10 \\ \n" +
-					"       --affinities vm:\"ID of VM\"",
+					"       --affinities vm:\"ID of VM\"\n" +
+					"     photon disk create --spec-file disk.yaml --name persistent-disk-2",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -77,6 +78,7 @@ func GetDiskCommand() cli.Command {
 						Name:  "project, p",
 						Usage: "Project name",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createDisk(c, os.Stdout)
@@ -173,6 +175,15 @@ func createDisk(c *cli.Context, w io.Writer) error {
 	projectName := c.String("project")
 	tags := c.String("tags")
 
+	spec := photon.DiskCreateSpec{}
+	err = readSpecFileFlag(c, &spec)
+	if err != nil {
+		return err
+	}
+	name = stringOrSpec(name, spec.Name)
+	flavor = stringOrSpec(flavor, spec.Flavor)
+	capacityGB = intOrSpec(capacityGB, spec.CapacityGB)
+
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
@@ -196,7 +207,7 @@ func createDisk(c *cli.Context, w io.Writer) error {
 		if err != nil {
 			return err
 		}
-		if !c.IsSet("capacityGB") {
+		if !c.IsSet("capacityGB") && capacityGB == 0 {
 			capacity, err := askForInput("Disk capacity in GB: ", "")
 			if err != nil {
 				return err
@@ -212,18 +223,27 @@ func createDisk(c *cli.Context, w io.Writer) error {
 		return fmt.Errorf("please provide disk name and flavor")
 	}
 
-	affinitiesList, err := parseAffinitiesListFromFlag(affinities)
-	if err != nil {
-		return err
+	affinitiesList := spec.Affinities
+	if len(affinities) != 0 || len(affinitiesList) == 0 {
+		affinitiesList, err = parseAffinitiesListFromFlag(affinities)
+		if err != nil {
+			return err
+		}
 	}
 
 	diskSpec := photon.DiskCreateSpec{}
 	diskSpec.Name = name
 	diskSpec.Flavor = flavor
 	diskSpec.CapacityGB = capacityGB
-	diskSpec.Kind = "persistent-disk"
+	diskSpec.Kind = spec.Kind
+	if len(diskSpec.Kind) == 0 {
+		diskSpec.Kind = "persistent-disk"
+	}
 	diskSpec.Affinities = affinitiesList
-	diskSpec.Tags = regexp.MustCompile(`\s*,\s*`).Split(tags, -1)
+	diskSpec.Tags = spec.Tags
+	if len(tags) != 0 || len(diskSpec.Tags) == 0 {
+		diskSpec.Tags = regexp.MustCompile(`\s*,\s*`).Split(tags, -1)
+	}
 
 	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
 		fmt.Printf("\nCreating disk: %s (%s)\n", diskSpec.Name, diskSpec.Flavor)
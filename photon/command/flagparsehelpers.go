@@ -15,6 +15,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+
 	"github.com/vmware/photon-controller-go-sdk/photon"
 )
 
@@ -109,6 +111,28 @@ func parseMapFromFlag(cmdFlag string) (map[string]string, error) {
 	return newMap, nil
 }
 
+// Check the CIDR of a flag such as -privateIpCidr/-i
+func checkCidrFromFlag(flagName, cidr string) error {
+	_, err := ipaddr.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("Invalid --%s: %s", flagName, err)
+	}
+	return nil
+}
+
+// Get the addresses of a comma separated list flag such as -dns-server-addresses
+func parseIpListFromFlag(flagName, addresses string) ([]string, error) {
+	ips, err := ipaddr.ParseIPs(addresses)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --%s: %s", flagName, err)
+	}
+	ipList := []string{}
+	for _, ip := range ips {
+		ipList = append(ipList, ip.String())
+	}
+	return ipList, nil
+}
+
 // Convert the QuotaLineItems into QuotaSpec
 func convertQuotaSpecFromQuotaLineItems(quotaLineItems []photon.QuotaLineItem) photon.QuotaSpec {
 	quotaSpec := photon.QuotaSpec{}
@@ -53,7 +53,9 @@ func GetFlavorsCommand() cli.Command {
 					"   Example VM flavor command:\n" +
 					"      photon flavor create --name f1 --kind vm --cost 'vm.memory 1 GB, vm.cpu 1 COUNT'\n" +
 					"   Example disk flavor:\n" +
-					"      photon flavor create --name f1 --kind persistent-disk.count --cost 'persistent-disk.count 1 COUNT'",
+					"      photon flavor create --name f1 --kind persistent-disk.count --cost 'persistent-disk.count 1 COUNT'\n" +
+					"   Example flavor from a spec file:\n" +
+					"      photon flavor create --spec-file flavor.yaml",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -67,6 +69,7 @@ func GetFlavorsCommand() cli.Command {
 						Name:  "cost, c",
 						Usage: "Comma-separated costs. Each cost is \"type number unit\"",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createFlavor(c, os.Stdout)
@@ -151,10 +154,21 @@ func createFlavor(c *cli.Context, w io.Writer) error {
 	kind := c.String("kind")
 	cost := c.String("cost")
 
-	costList, err := parseLimitsListFromFlag(cost)
+	spec := photon.FlavorCreateSpec{}
+	err = readSpecFileFlag(c, &spec)
 	if err != nil {
 		return err
 	}
+	name = stringOrSpec(name, spec.Name)
+	kind = stringOrSpec(kind, spec.Kind)
+
+	costList := spec.Cost
+	if len(cost) != 0 || len(costList) == 0 {
+		costList, err = parseLimitsListFromFlag(cost)
+		if err != nil {
+			return err
+		}
+	}
 
 	if !c.GlobalIsSet("non-interactive") {
 		name, err = askForInput("Flavor name: ", name)
@@ -0,0 +1,279 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Number of images deleted at the same time by image gc --delete
+const defaultImageGCParallelism = 4
+
+// Image that no VM or service configuration uses, and that is either READY or
+// stuck in another state.
+type unusedImage struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+	// Time the image was created at in milliseconds since the epoch, or 0 if unknown.
+	CreatedTime int64 `json:"createdTime"`
+}
+
+// Lists the images that can be deleted and deletes them with --delete.
+func collectImages(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	olderThan, err := parseAge(c.String("older-than"))
+	if err != nil {
+		return err
+	}
+	parallel := c.Int("parallel")
+	if parallel <= 0 {
+		parallel = defaultImageGCParallelism
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	images, err := findUnusedImages(olderThan, time.Now())
+	if err != nil {
+		return err
+	}
+	err = printUnusedImages(images, c, w)
+	if err != nil {
+		return err
+	}
+	if !c.Bool("delete") || len(images) == 0 {
+		return nil
+	}
+
+	if !confirmed(c) {
+		fmt.Println("OK, canceled")
+		return nil
+	}
+	return deleteUnusedImages(images, parallel, c)
+}
+
+// Returns the images created more than olderThan ago that are not used by any VM
+// or service configuration, sorted by name. Images that are used are never
+// returned, even when they are not READY. Images whose creation task is gone are
+// considered old enough.
+func findUnusedImages(olderThan time.Duration, now time.Time) ([]unusedImage, error) {
+	used, err := getUsedImages()
+	if err != nil {
+		return nil, err
+	}
+	images, err := client.Photonclient.Images.GetAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := []unusedImage{}
+	for _, image := range images.Items {
+		if used[image.ID] != 0 {
+			continue
+		}
+		reason := "unreferenced"
+		if image.State != "READY" {
+			reason = "stuck in state " + image.State
+		}
+
+		created, err := getImageCreatedTime(image.ID)
+		if err != nil {
+			return nil, err
+		}
+		if created != 0 && now.Sub(time.Unix(0, created*int64(time.Millisecond))) < olderThan {
+			continue
+		}
+		unused = append(unused, unusedImage{
+			ID:          image.ID,
+			Name:        image.Name,
+			State:       image.State,
+			Size:        image.Size,
+			Reason:      reason,
+			CreatedTime: created,
+		})
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].Name != unused[j].Name {
+			return unused[i].Name < unused[j].Name
+		}
+		return unused[i].ID < unused[j].ID
+	})
+	return unused, nil
+}
+
+// Returns the number of VMs using each image, across all tenants and projects.
+// Images of the service configurations count as used by one VM, since services
+// create their VMs from them.
+func getUsedImages() (map[string]int, error) {
+	used := map[string]int{}
+
+	tenants, err := client.Photonclient.Tenants.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, tenant := range tenants.Items {
+		projects, err := client.Photonclient.Tenants.GetProjects(tenant.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects.Items {
+			vms, err := client.Photonclient.Projects.GetVMs(project.ID, nil)
+			if err != nil {
+				return nil, err
+			}
+			for _, vm := range vms.Items {
+				used[vm.SourceImageID]++
+			}
+		}
+	}
+
+	info, err := client.Photonclient.System.GetSystemInfo()
+	if err != nil {
+		return nil, err
+	}
+	for _, configuration := range info.ServiceConfigurations {
+		used[configuration.ImageID]++
+	}
+	return used, nil
+}
+
+// Returns the time the image creation was queued at, or 0 if its task is gone.
+func getImageCreatedTime(id string) (int64, error) {
+	tasks, err := client.Photonclient.Images.GetTasks(id, &photon.TaskGetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	for _, task := range tasks.Items {
+		if task.Operation == "CREATE_IMAGE" {
+			return task.QueuedTime, nil
+		}
+	}
+	return 0, nil
+}
+
+func printUnusedImages(images []unusedImage, c *cli.Context, w io.Writer) error {
+	if utils.NeedsFormatting(c) {
+		utils.FormatObjects(images, w, c)
+		return nil
+	}
+
+	var total int64
+	for _, image := range images {
+		total += image.Size
+	}
+	if c.GlobalIsSet("non-interactive") {
+		for _, image := range images {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", image.ID, image.Name, image.State, image.Size,
+				formatCreatedTime(image.CreatedTime), image.Reason)
+		}
+		return nil
+	}
+
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tName\tState\tSize(Byte)\tCreated\tReason\n")
+	for _, image := range images {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", image.ID, image.Name, image.State, image.Size,
+			formatCreatedTime(image.CreatedTime), image.Reason)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nTotal: %d, %s\n", len(images), formatBytes(total))
+	return nil
+}
+
+func formatCreatedTime(created int64) string {
+	if created == 0 {
+		return "-"
+	}
+	return time.Unix(0, created*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+}
+
+// Deletes the images, at most parallel at a time, and returns an error listing
+// the images that could not be deleted.
+func deleteUnusedImages(images []unusedImage, parallel int, c *cli.Context) error {
+	errs := make([]error, len(images))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for i := range images {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			task, err := client.Photonclient.Images.Delete(images[i].ID)
+			if err == nil {
+				_, err = client.Photonclient.Tasks.Wait(task.ID)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, image := range images {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "Failed to delete image %s (%s): %s\n", image.Name, image.ID, errs[i])
+			failed = append(failed, image.ID)
+		}
+	}
+	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
+		fmt.Printf("Images deleted: %d, failed: %d\n", len(images)-len(failed), len(failed))
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("Failed to delete %d of %d images: %s", len(failed), len(images), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Parses an age such as "30d" or "12h". Days are accepted on top of the units of
+// time.ParseDuration. An empty age means any age.
+func parseAge(age string) (time.Duration, error) {
+	if len(age) == 0 {
+		return 0, nil
+	}
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("Invalid --older-than '%s', expected an age such as 30d or 12h", age)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid --older-than '%s', expected an age such as 30d or 12h", age)
+	}
+	return d, nil
+}
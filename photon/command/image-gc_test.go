@@ -0,0 +1,135 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func runImageGC(t *testing.T, olderThan string, delete bool) []unusedImage {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalSet.String("output", "json", "output")
+	err := globalSet.Parse([]string{"--non-interactive", "--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("older-than", olderThan, "older than")
+	set.Bool("delete", delete, "delete")
+	set.Int("parallel", 2, "parallel")
+
+	var output bytes.Buffer
+	err = collectImages(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	if err != nil {
+		t.Fatal("Not expecting error collecting images: " + err.Error())
+	}
+	var images []unusedImage
+	err = json.Unmarshal(output.Bytes(), &images)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding images: %s\n%s", err, output.String())
+	}
+	return images
+}
+
+func TestImageGC(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	usedID := sim.AddImage("used", 1024)
+	unusedID := sim.AddImage("unused", 2048)
+	serviceID := sim.AddImage("service", 4096)
+	task, err := api.System.EnableServiceType(&photon.ServiceConfigurationSpec{Type: "KUBERNETES", ImageID: serviceID})
+	waitForEntity(t, task, err)
+	task, err = api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm1", Flavor: "small", SourceImageID: usedID})
+	waitForEntity(t, task, err)
+
+	// The upload is not waited on, so the image stays CREATING.
+	uploadTask, err := api.Images.Create(strings.NewReader("disk"), "uploading", &photon.ImageCreateOptions{})
+	if err != nil {
+		t.Fatal("Not expecting error uploading image: " + err.Error())
+	}
+	stuckID := uploadTask.Entity.ID
+	// Stuck images that are used are never listed.
+	uploadTask, err = api.Images.Create(strings.NewReader("disk"), "uploading-used", &photon.ImageCreateOptions{})
+	if err != nil {
+		t.Fatal("Not expecting error uploading image: " + err.Error())
+	}
+	task, err = api.System.EnableServiceType(&photon.ServiceConfigurationSpec{Type: "SWARM", ImageID: uploadTask.Entity.ID})
+	waitForEntity(t, task, err)
+
+	images := runImageGC(t, "7d", false)
+	expected := []unusedImage{{ID: unusedID, Name: "unused", State: "READY", Size: 2048, Reason: "unreferenced"}}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("Expected only the unused image without creation time, got %+v", images)
+	}
+
+	images = runImageGC(t, "", false)
+	if len(images) != 2 || images[1].ID != stuckID || images[1].Reason != "stuck in state CREATING" ||
+		images[1].CreatedTime == 0 {
+		t.Errorf("Expected the image being uploaded to be listed as stuck without age limit, got %+v", images)
+	}
+
+	runImageGC(t, "", true)
+	all, err := api.Images.GetAll(nil)
+	if err != nil {
+		t.Fatal("Not expecting error listing images: " + err.Error())
+	}
+	var remaining []string
+	for _, image := range all.Items {
+		remaining = append(remaining, image.Name)
+	}
+	sort.Strings(remaining)
+	if !reflect.DeepEqual(remaining, []string{"service", "uploading-used", "used"}) {
+		t.Errorf("Expected the unused and stuck images to be deleted, got %v", remaining)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    0,
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	}
+	for age, expected := range cases {
+		d, err := parseAge(age)
+		if err != nil || d != expected {
+			t.Errorf("Expected age %s to be %s, got %s (%v)", age, expected, d, err)
+		}
+	}
+	for _, age := range []string{"30", "-1d", "abc"} {
+		if _, err := parseAge(age); err == nil {
+			t.Errorf("Expected age %s to be rejected", age)
+		}
+	}
+}
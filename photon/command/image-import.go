@@ -0,0 +1,266 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/ovf"
+
+	"github.com/urfave/cli"
+)
+
+// Number of times a download is retried after an error
+const downloadRetries = 3
+
+// Delay before retrying a download, multiplied by the number of the attempt
+var downloadRetryDelay = time.Second
+
+// Body of a download that resumes with a range request when the connection fails.
+type downloadReader struct {
+	url     string
+	offset  int64
+	length  int64
+	body    io.ReadCloser
+	retries int
+}
+
+// Returns true if location is an http or https URL rather than a file path.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// Returns the default name of an image created from a file or URL.
+func imageDefaultName(location string) string {
+	if u, err := url.Parse(location); err == nil && isURL(location) {
+		return path.Base(u.Path)
+	}
+	return filepath.Base(location)
+}
+
+// Opens the disk to create an image from, which is the file or URL itself, or
+// the disk of an OVA archive or OVF descriptor. OVA archives are uploaded as
+// they are with --upload-archive. The hardware of an appliance that the image
+// does not keep is reported on stderr.
+func openImageUpload(location, name string, c *cli.Context) (*uploadReader, error) {
+	_, err := parseRate(c.String("limit-rate"))
+	if err != nil {
+		return nil, err
+	}
+
+	var source *uploadSource
+	switch strings.ToLower(path.Ext(imageDefaultName(location))) {
+	case ".ova":
+		if c.Bool("upload-archive") {
+			source, err = locationSource(location)
+		} else {
+			source, err = ovaSource(location)
+		}
+	case ".ovf":
+		source, err = ovfSource(location)
+	default:
+		source, err = locationSource(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newUpload(source, name, c)
+}
+
+// Returns a source reading a file or downloading a URL.
+func locationSource(location string) (*uploadSource, error) {
+	if !isURL(location) {
+		source, err := fileSource(location)
+		if err != nil {
+			return nil, fmt.Errorf("No such image file at that path")
+		}
+		return source, nil
+	}
+	return downloadSource(location)
+}
+
+func downloadSource(location string) (*uploadSource, error) {
+	open := func() (io.ReadCloser, error) {
+		r := &downloadReader{url: location}
+		err := r.get()
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	first, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return &uploadSource{size: first.(*downloadReader).length, open: open, first: first}, nil
+}
+
+// Returns a source reading the disk of an OVA archive, which is read up to the disk
+// every time the source is opened.
+func ovaSource(location string) (*uploadSource, error) {
+	archive, err := locationSource(location)
+	if err != nil {
+		return nil, err
+	}
+	var ova *ovf.Archive
+	open := func() (io.ReadCloser, error) {
+		stream := archive.first
+		archive.first = nil
+		if stream == nil {
+			stream, err = archive.open()
+			if err != nil {
+				return nil, err
+			}
+		}
+		ova, err = ovf.OpenArchive(stream)
+		if err != nil {
+			_ = stream.Close()
+			return nil, fmt.Errorf("Cannot read '%s': %s", location, err)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{ova.Disk, stream}, nil
+	}
+
+	first, err := open()
+	if err != nil {
+		return nil, err
+	}
+	printIgnoredHardware(ova.Descriptor)
+	return &uploadSource{size: ova.DiskSize, open: open, first: first}, nil
+}
+
+// Returns a source reading the disk of an OVF descriptor, which is next to it.
+func ovfSource(location string) (*uploadSource, error) {
+	source, err := locationSource(location)
+	if err != nil {
+		return nil, err
+	}
+	stream := source.first
+	if stream == nil {
+		stream, err = source.open()
+		if err != nil {
+			return nil, err
+		}
+	}
+	descriptor, err := ovf.Parse(stream)
+	_ = stream.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read '%s': %s", location, err)
+	}
+	disk, err := descriptor.DiskFile()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read '%s': %s", location, err)
+	}
+	printIgnoredHardware(descriptor)
+
+	if isURL(location) {
+		base, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := url.Parse(disk.Href)
+		if err != nil {
+			return nil, fmt.Errorf("Bad disk file '%s' in '%s'", disk.Href, location)
+		}
+		return locationSource(base.ResolveReference(ref).String())
+	}
+	return locationSource(filepath.Join(filepath.Dir(location), filepath.FromSlash(disk.Href)))
+}
+
+func printIgnoredHardware(descriptor *ovf.Envelope) {
+	for _, warning := range descriptor.Ignored() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+// Sends a GET request for the rest of the download, retrying on errors.
+func (r *downloadReader) get() error {
+	var err error
+	for attempt := 0; attempt <= downloadRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * downloadRetryDelay)
+		}
+		var retry bool
+		retry, err = r.tryGet()
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// Sends a GET request from the current offset, returning whether it is worth retrying
+// when it fails.
+func (r *downloadReader) tryGet() (bool, error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return false, err
+	}
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("Cannot download '%s': %s", r.url, err)
+	}
+	if res.StatusCode >= 300 {
+		_ = res.Body.Close()
+		return res.StatusCode >= 500, fmt.Errorf("Cannot download '%s': %s", r.url, res.Status)
+	}
+
+	switch {
+	case r.offset == 0:
+		r.length = res.ContentLength
+	case res.StatusCode != http.StatusPartialContent:
+		// The server ignored the range, skip what was already read.
+		_, err = io.CopyN(ioutil.Discard, res.Body, r.offset)
+		if err != nil {
+			_ = res.Body.Close()
+			return true, fmt.Errorf("Cannot download '%s': %s", r.url, err)
+		}
+	}
+	r.body = res.Body
+	return false, nil
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.length >= 0 && r.offset < r.length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil || err == io.EOF || r.retries >= downloadRetries {
+		return n, err
+	}
+
+	// Resume the download after the bytes read so far.
+	r.retries++
+	_ = r.body.Close()
+	getErr := r.get()
+	if getErr != nil {
+		return n, getErr
+	}
+	return n, nil
+}
+
+func (r *downloadReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+)
+
+const importDescriptor = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+    xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData">
+  <References><File ovf:id="file1" ovf:href="disk1.vmdk"/></References>
+  <DiskSection><Disk ovf:diskId="vmdisk1" ovf:fileRef="file1"/></DiskSection>
+  <VirtualSystem ovf:id="golden">
+    <VirtualHardwareSection>
+      <Item><rasd:ElementName>4 virtual CPU(s)</rasd:ElementName><rasd:ResourceType>3</rasd:ResourceType></Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+// Returns an OVA archive of importDescriptor and disk.
+func newTestOVA(t *testing.T, disk []byte) *bytes.Buffer {
+	var ova bytes.Buffer
+	tw := tar.NewWriter(&ova)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"golden.ovf", []byte(importDescriptor)}, {"disk1.vmdk", disk}} {
+		err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data))})
+		if err == nil {
+			_, err = tw.Write(f.data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ova
+}
+
+func createImageFrom(t *testing.T, location string, flags ...string) uploadedImage {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalSet.String("output", "json", "output")
+	err := globalSet.Parse([]string{"--non-interactive", "--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "golden", "name")
+	set.String("image_replication", "EAGER", "replication")
+	set.String("scope", "", "scope")
+	set.String("project", "", "project")
+	set.String("limit-rate", "", "limit rate")
+	set.Bool("upload-archive", false, "upload archive")
+	err = set.Parse(append(flags, location))
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = createImage(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	if err != nil {
+		t.Fatalf("Not expecting error creating image from %s: %s", location, err)
+	}
+	var image uploadedImage
+	err = json.Unmarshal(output.Bytes(), &image)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding image: %s\n%s", err, output.String())
+	}
+	return image
+}
+
+func TestCreateImageFromOVAURL(t *testing.T) {
+	// The download goes through the default client, which other tests mock.
+	mocks.Deactivate()
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+	defer func(delay time.Duration) { downloadRetryDelay = delay }(downloadRetryDelay)
+	downloadRetryDelay = time.Millisecond
+
+	disk := bytes.Repeat([]byte("golden disk "), 10000)
+	ova := newTestOVA(t, disk)
+
+	// The first download is cut in the middle of the disk.
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Content-Length", strconv.Itoa(ova.Len()))
+			// The connection is cut, the client sees the error.
+			_, _ = w.Write(ova.Bytes()[:ova.Len()/2])
+			return
+		}
+		http.ServeContent(w, r, "golden.ova", time.Time{}, bytes.NewReader(ova.Bytes()))
+	}))
+	defer server.Close()
+
+	image := createImageFrom(t, server.URL+"/images/golden.ova")
+	sum := sha256.Sum256(disk)
+	if image.Size != int64(len(disk)) || image.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the image to be the disk of the OVA, got %+v", image)
+	}
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != "bytes="+strconv.Itoa(ova.Len()/2)+"-" {
+		t.Errorf("Expected the download to resume where it was cut, got ranges %q", ranges)
+	}
+}
+
+func TestCreateImageFromLocalOVA(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+
+	dir, err := ioutil.TempDir("", "image-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	disk := []byte("golden disk")
+	ova := newTestOVA(t, disk)
+	path := filepath.Join(dir, "golden.ova")
+	err = ioutil.WriteFile(path, ova.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := createImageFrom(t, path)
+	sum := sha256.Sum256(disk)
+	if image.Size != int64(len(disk)) || image.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the image to be the disk of the OVA, got %+v", image)
+	}
+	image = createImageFrom(t, path, "--upload-archive")
+	sum = sha256.Sum256(ova.Bytes())
+	if image.Size != int64(ova.Len()) || image.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the OVA to be uploaded as it is, got %+v", image)
+	}
+}
+
+func TestCreateImageFromOVF(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+
+	dir, err := ioutil.TempDir("", "image-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	disk := []byte("golden disk")
+	err = ioutil.WriteFile(filepath.Join(dir, "golden.ovf"), []byte(importDescriptor), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "disk1.vmdk"), disk, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := createImageFrom(t, filepath.Join(dir, "golden.ovf"))
+	sum := sha256.Sum256(disk)
+	if image.Size != int64(len(disk)) || image.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the image to be the disk next to the OVF, got %+v", image)
+	}
+}
+
+func TestImageDefaultName(t *testing.T) {
+	cases := map[string]string{
+		"/tmp/images/kube.vmdk":                            "kube.vmdk",
+		"https://artifacts.example.com/golden/kube.ova":    "kube.ova",
+		"http://artifacts.example.com/golden/kube.ovf?v=1": "kube.ovf",
+	}
+	for location, expected := range cases {
+		if name := imageDefaultName(location); name != expected {
+			t.Errorf("Expected name %s for %s, got %s", expected, location, name)
+		}
+	}
+}
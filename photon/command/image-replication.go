@@ -0,0 +1,190 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Delay between two polls of image replication-status --watch
+var imageReplicationPollDelay = 5 * time.Second
+
+// Width of the progress bars of image replication-status
+const replicationBarWidth = 20
+
+// Replication status of an image
+type imageReplication struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	State               string `json:"state"`
+	ReplicationType     string `json:"replicationType"`
+	ReplicationProgress string `json:"replicationProgress"`
+	SeedingProgress     string `json:"seedingProgress"`
+	Status              string `json:"status"`
+
+	changed time.Time
+}
+
+// Shows the replication progress of the given images, or of all images, and
+// returns an error unless they are all fully replicated. With --watch, the
+// progress is polled until every image is replicated, failed or stalled.
+func showImageReplication(c *cli.Context, w io.Writer) error {
+	watch := c.Bool("watch")
+	stallTimeout := time.Duration(c.Int("stall-timeout")) * time.Second
+
+	var err error
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	ids := []string(c.Args())
+	if len(ids) == 0 {
+		images, err := client.Photonclient.Images.GetAll(nil)
+		if err != nil {
+			return err
+		}
+		for _, image := range images.Items {
+			ids = append(ids, image.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("There are no images")
+	}
+
+	statuses := make([]imageReplication, len(ids))
+	interactive := !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c)
+	drawn := false
+	for {
+		now := time.Now()
+		done := true
+		for i, id := range ids {
+			image, err := client.Photonclient.Images.Get(id)
+			if err != nil {
+				return err
+			}
+			updateImageReplication(&statuses[i], image, now, watch, stallTimeout)
+			if statuses[i].Status == "replicating" {
+				done = false
+			}
+		}
+		if interactive {
+			if drawn {
+				// Move the cursor back to the first line of the table.
+				fmt.Fprintf(w, "\033[%dA", len(statuses)+1)
+			}
+			drawn = true
+			err = printImageReplicationTable(statuses, w)
+			if err != nil {
+				return err
+			}
+		}
+		if !watch || done {
+			break
+		}
+		time.Sleep(imageReplicationPollDelay)
+	}
+
+	if utils.NeedsFormatting(c) {
+		utils.FormatObjects(statuses, w, c)
+	} else if c.GlobalIsSet("non-interactive") {
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", status.ID, status.Name, status.ReplicationType,
+				status.ReplicationProgress, status.SeedingProgress, status.Status)
+		}
+	}
+
+	var incomplete []string
+	for _, status := range statuses {
+		if status.Status != "replicated" {
+			incomplete = append(incomplete, fmt.Sprintf("%s (%s)", status.ID, status.Status))
+		}
+	}
+	if len(incomplete) != 0 {
+		return fmt.Errorf("%d of %d images are not fully replicated: %s",
+			len(incomplete), len(statuses), strings.Join(incomplete, ", "))
+	}
+	return nil
+}
+
+// Updates the status of an image from its latest progress. ON_DEMAND images are only
+// copied to a datastore when a VM uses them there, so they are replicated once seeded.
+// An image stalls when its progress has not changed for stallTimeout, which can only
+// be told when watching.
+func updateImageReplication(status *imageReplication, image *photon.Image, now time.Time, watch bool,
+	stallTimeout time.Duration) {
+
+	if status.changed.IsZero() || image.ReplicationProgress != status.ReplicationProgress ||
+		image.SeedingProgress != status.SeedingProgress {
+		status.changed = now
+	}
+	status.ID = image.ID
+	status.Name = image.Name
+	status.State = image.State
+	status.ReplicationType = image.ReplicationType
+	status.ReplicationProgress = image.ReplicationProgress
+	status.SeedingProgress = image.SeedingProgress
+
+	replicated := parseProgress(image.ReplicationProgress) >= 100 || image.ReplicationType == "ON_DEMAND"
+	switch {
+	case image.State == "ERROR":
+		status.Status = "failed"
+	case replicated && parseProgress(image.SeedingProgress) >= 100:
+		status.Status = "replicated"
+	case watch && status.changed.Before(now) && now.Sub(status.changed) >= stallTimeout:
+		status.Status = "stalled"
+	default:
+		status.Status = "replicating"
+	}
+}
+
+func printImageReplicationTable(statuses []imageReplication, w io.Writer) error {
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tName\tReplication_type\tReplication\tSeeding\tStatus\033[K\n")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\033[K\n", status.ID, status.Name, status.ReplicationType,
+			formatProgressBar(status.ReplicationProgress), formatProgressBar(status.SeedingProgress), status.Status)
+	}
+	return tw.Flush()
+}
+
+// Parses a progress such as "45.5%" into a percentage, or 0 if it is not known.
+func parseProgress(progress string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(progress, "%")), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// Formats a progress such as "45.0%" as "[#########-----------]  45.0%".
+func formatProgressBar(progress string) string {
+	value := parseProgress(progress)
+	if value > 100 {
+		value = 100
+	} else if value < 0 {
+		value = 0
+	}
+	filled := int(value / 100 * replicationBarWidth)
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", filled),
+		strings.Repeat("-", replicationBarWidth-filled), value)
+}
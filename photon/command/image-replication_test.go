@@ -0,0 +1,129 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func runImageReplication(t *testing.T, watch bool, stallTimeout int, ids ...string) ([]imageReplication, error) {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalSet.String("output", "json", "output")
+	err := globalSet.Parse([]string{"--non-interactive", "--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.Bool("watch", watch, "watch")
+	set.Int("stall-timeout", stallTimeout, "stall timeout")
+	err = set.Parse(ids)
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = showImageReplication(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	var statuses []imageReplication
+	if jsonErr := json.Unmarshal(output.Bytes(), &statuses); jsonErr != nil {
+		t.Fatalf("Not expecting error decoding statuses: %s\n%s", jsonErr, output.String())
+	}
+	return statuses, err
+}
+
+func TestImageReplicationStatus(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+	defer func(delay time.Duration) { imageReplicationPollDelay = delay }(imageReplicationPollDelay)
+	imageReplicationPollDelay = time.Millisecond
+
+	readyID := sim.AddImage("ready", 1024)
+	// The upload is not waited on, so the image is not replicated yet.
+	task, err := api.Images.Create(strings.NewReader("disk"), "uploading", &photon.ImageCreateOptions{})
+	if err != nil {
+		t.Fatal("Not expecting error uploading image: " + err.Error())
+	}
+	uploadingID := task.Entity.ID
+
+	statuses, err := runImageReplication(t, false, 300)
+	if err == nil || err.Error() != "1 of 2 images are not fully replicated: "+uploadingID+" (replicating)" {
+		t.Errorf("Expected the uploading image to fail the status, got %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].ID != readyID || statuses[0].Status != "replicated" ||
+		statuses[1].ReplicationProgress != "0.0%" {
+		t.Errorf("Unexpected statuses %+v", statuses)
+	}
+
+	_, err = runImageReplication(t, false, 300, readyID)
+	if err != nil {
+		t.Error("Not expecting error for a replicated image: " + err.Error())
+	}
+
+	statuses, err = runImageReplication(t, true, 0, uploadingID)
+	if err == nil || len(statuses) != 1 || statuses[0].Status != "stalled" {
+		t.Errorf("Expected the image without progress to stall, got %+v, %v", statuses, err)
+	}
+
+	// Simulated tasks only progress when polled. t.Fatal must not be called from
+	// the goroutine, the error is checked once the watch returns.
+	uploaded := make(chan error, 1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, err := api.Tasks.Wait(task.ID)
+		uploaded <- err
+	}()
+	statuses, err = runImageReplication(t, true, 300, uploadingID)
+	if uploadErr := <-uploaded; uploadErr != nil {
+		t.Fatal("Not expecting error uploading image: " + uploadErr.Error())
+	}
+	if err != nil || statuses[0].Status != "replicated" || statuses[0].SeedingProgress != "100.0%" {
+		t.Errorf("Expected watch to wait for the replication, got %+v, %v", statuses, err)
+	}
+
+	// ON_DEMAND images are replicated once seeded.
+	task, err = api.Images.Create(strings.NewReader("disk"), "on-demand",
+		&photon.ImageCreateOptions{ReplicationType: "ON_DEMAND"})
+	onDemandID := waitForEntity(t, task, err)
+	statuses, err = runImageReplication(t, true, 300)
+	if err != nil || len(statuses) != 3 {
+		t.Fatalf("Expected all the images to be replicated, got %+v, %v", statuses, err)
+	}
+	if statuses[2].ID != onDemandID || statuses[2].ReplicationProgress != "0.0%" || statuses[2].Status != "replicated" {
+		t.Errorf("Expected the seeded ON_DEMAND image to be replicated, got %+v", statuses[2])
+	}
+}
+
+func TestFormatProgressBar(t *testing.T) {
+	cases := map[string]string{
+		"0.0%":   "[--------------------]   0.0%",
+		"45.0%":  "[#########-----------]  45.0%",
+		"100.0%": "[####################] 100.0%",
+		"":       "[--------------------]   0.0%",
+		"-5.0%":  "[--------------------]   0.0%",
+	}
+	for progress, expected := range cases {
+		if bar := formatProgressBar(progress); bar != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, progress, bar)
+		}
+	}
+}
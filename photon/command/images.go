@@ -14,7 +14,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
@@ -33,6 +32,8 @@ import (
 //              list;   Usage: image list
 //              show;   Usage: image show <id>
 //              tasks;  Usage: image tasks <id> [<options>]
+//              gc;     Usage: image gc [<options>]
+//              replication-status; Usage: image replication-status [<id>...] [<options>]
 //              iam show;  Usage: image iam show <id> [<options>]
 //              iam add; Usage: image iam add <id> [<options>]
 //              iam remove; Usage: image iam remove <id> [<options>]
@@ -44,15 +45,21 @@ func GetImagesCommand() cli.Command {
 			{
 				Name:      "create",
 				Usage:     "Create a new image",
-				ArgsUsage: "<image-filename>",
+				ArgsUsage: "<image-filename-or-url>",
 				Description: "Upload a new image to Photon Controller.\n" +
+					"   The image can be a disk file, or an OVA archive or OVF descriptor whose disk is uploaded,\n" +
+					"   either on disk or at an http(s) URL. Use --upload-archive to upload an OVA archive as it is.\n" +
+					"   Hardware settings of OVA and OVF appliances are not kept in the image, they are listed as\n" +
+					"   warnings.\n" +
 					"   If the image replication is EAGER, it will be distributed to all allowed datastores on all ESXi hosts\n" +
 					"   If the image replication is ON_DEMAND, it will be distributed to all image datastores\n" +
 					"   An image can have project scope or infrastructure scope. Only system administrators can create\n" +
-					"   infrastructure images.\n\n" +
+					"   infrastructure images.\n" +
+					"   The upload progress and the SHA-256 checksum of the file are printed on stderr.\n\n" +
 					"   Example:\n" +
 					"   create image:\n" +
-					"        photon image create kubernetes-1.6.ova -n kube-demo -i EAGER",
+					"        photon image create kubernetes-1.6.ova -n kube-demo -i EAGER --limit-rate 20M\n" +
+					"        photon image create https://artifacts.example.com/images/kubernetes-1.6.ova -n kube-demo",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -70,6 +77,11 @@ func GetImagesCommand() cli.Command {
 						Name:  "project, p",
 						Usage: "Project ID, required for image with project scope.",
 					},
+					cli.BoolFlag{
+						Name:  "upload-archive",
+						Usage: "Upload an OVA archive as it is instead of its disk",
+					},
+					limitRateFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createImage(c, os.Stdout)
@@ -137,6 +149,70 @@ func GetImagesCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "gc",
+				Usage:     "List and delete unused images",
+				ArgsUsage: " ",
+				Description: "List the images that no VM of any project and no service configuration uses,\n" +
+					"   including those stuck in a state other than READY, with their size.\n" +
+					"   Only images created before --older-than are listed. Images whose creation task\n" +
+					"   is no longer known are listed whatever their age.\n" +
+					"   With --delete, the listed images are deleted after confirmation.\n\n" +
+					"   Example:\n" +
+					"   photon image gc --older-than 30d\n" +
+					"   photon image gc --older-than 30d --delete --parallel 8",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "older-than",
+						Value: "7d",
+						Usage: "Minimum age of the images to list, e.g.: 30d or 12h",
+					},
+					cli.BoolFlag{
+						Name:  "delete",
+						Usage: "Delete the listed images",
+					},
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: defaultImageGCParallelism,
+						Usage: "Number of images to delete at the same time",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := collectImages(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "replication-status",
+				Usage:     "Show the replication progress of images",
+				ArgsUsage: "[<image-id>...]",
+				Description: "Show the replication and seeding progress of the given images, or of all images.\n" +
+					"   With --watch, the progress is refreshed until every image is fully replicated, failed,\n" +
+					"   or stalled, that is its progress has not changed for --stall-timeout seconds.\n" +
+					"   ON_DEMAND images are copied to datastores as VMs use them, so only their seeding is awaited.\n" +
+					"   The command fails unless all the images are fully replicated.\n\n" +
+					"   Example:\n" +
+					"   photon image replication-status <image-id> --watch --stall-timeout 600",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "watch, w",
+						Usage: "Refresh the progress until the images are replicated",
+					},
+					cli.IntFlag{
+						Name:  "stall-timeout",
+						Value: 300,
+						Usage: "Seconds without progress after which an image is reported as stalled",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := showImageReplication(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:  "iam",
 				Usage: "options for identity and access management",
@@ -209,8 +285,14 @@ func GetImagesCommand() cli.Command {
 	return command
 }
 
+// Image created from a file, with the SHA-256 checksum of the file
+type uploadedImage struct {
+	photon.Image
+	Checksum string `json:"sha256"`
+}
+
 // Create an image
-func createImage(c *cli.Context, w io.Writer) error {
+func createImage(c *cli.Context, w io.Writer) (err error) {
 	if len(c.Args()) > 1 {
 		return fmt.Errorf("Unknown argument: %v", c.Args()[1:])
 	}
@@ -232,18 +314,20 @@ func createImage(c *cli.Context, w io.Writer) error {
 		return fmt.Errorf("Please provide image path")
 	}
 
-	filePath, err := filepath.Abs(filePath)
-	if err != nil {
-		return err
-	}
+	if !isURL(filePath) {
+		filePath, err = filepath.Abs(filePath)
+		if err != nil {
+			return err
+		}
 
-	_, err = os.Stat(filePath)
-	if err != nil {
-		return fmt.Errorf("No such image file at that path")
+		_, err = os.Stat(filePath)
+		if err != nil {
+			return fmt.Errorf("No such image file at that path")
+		}
 	}
 
 	if !c.GlobalIsSet("non-interactive") {
-		defaultName := path.Base(filePath)
+		defaultName := imageDefaultName(filePath)
 		name, err = askForInput("Image name (default: "+defaultName+"): ", name)
 		if err != nil {
 			return err
@@ -296,10 +380,16 @@ func createImage(c *cli.Context, w io.Writer) error {
 		}
 	}
 
-	file, err := os.Open(filePath)
+	upload, err := openImageUpload(filePath, name, c)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		e := upload.Close()
+		if e != nil && err == nil {
+			err = e
+		}
+	}()
 
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
@@ -315,9 +405,9 @@ func createImage(c *cli.Context, w io.Writer) error {
 
 	var uploadTask *photon.Task
 	if len(projectID) == 0 {
-		uploadTask, err = client.Photonclient.Images.Create(file, name, options)
+		uploadTask, err = client.Photonclient.Images.Create(upload, name, options)
 	} else {
-		uploadTask, err = client.Photonclient.Projects.CreateImage(projectID, file, name, options)
+		uploadTask, err = client.Photonclient.Projects.CreateImage(projectID, upload, name, options)
 	}
 	if err != nil {
 		return err
@@ -328,17 +418,14 @@ func createImage(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
-	err = file.Close()
-	if err != nil {
-		return err
-	}
+	upload.printSummary()
 
 	if utils.NeedsFormatting(c) {
 		image, err := client.Photonclient.Images.Get(imageID)
 		if err != nil {
 			return err
 		}
-		utils.FormatObject(image, w, c)
+		utils.FormatObject(uploadedImage{*image, upload.Checksum()}, w, c)
 	}
 
 	return nil
@@ -0,0 +1,307 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Number of VMs whose networks are fetched at the same time
+const defaultInventoryParallelism = 8
+
+// Characters that are not allowed in Ansible group names
+var badGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// VM of an inventory with the address to reach it at.
+type inventoryHost struct {
+	Name    string
+	VM      photon.VM
+	Address string
+}
+
+// Creates a cli.Command for inventory
+// Subcommands: ansible;    Usage: inventory ansible [<options>]
+//              ssh-config; Usage: inventory ssh-config [<options>]
+func GetInventoryCommand() cli.Command {
+	scopeFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "tenant, t",
+			Usage: "Tenant name",
+		},
+		cli.StringFlag{
+			Name:  "project, p",
+			Usage: "Project name",
+		},
+		cli.BoolFlag{
+			Name:  "use-floating-ip",
+			Usage: "Reach VMs at their floating IP when they have one",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Value: defaultInventoryParallelism,
+			Usage: "Number of VMs to get the networks of at the same time",
+		},
+	}
+	command := cli.Command{
+		Name:  "inventory",
+		Usage: "options for inventories of VMs",
+		Subcommands: []cli.Command{
+			{
+				Name:      "ansible",
+				Usage:     "Print an Ansible inventory of the VMs of a project",
+				ArgsUsage: " ",
+				Description: "Print the VMs of a project that have an IP address as an Ansible dynamic inventory\n" +
+					"   in JSON. VMs are keyed by name, their metadata become host variables and --group-by\n" +
+					"   puts them in groups by tag, flavor, host, or the value of a metadata key.\n\n" +
+					"   Example: \n" +
+					"   photon inventory ansible -t cloud-dev -p cloud-dev-staging --group-by flavor \n" +
+					"   photon inventory ansible --group-by role > inventory.json \n",
+				Flags: append([]cli.Flag{
+					cli.StringFlag{
+						Name:  "group-by, g",
+						Usage: "Group VMs by 'tag', 'flavor', 'host' or the value of the given metadata key",
+					},
+				}, scopeFlags...),
+				Action: func(c *cli.Context) {
+					err := printAnsibleInventory(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "ssh-config",
+				Usage:     "Print ssh config entries for the VMs of a project",
+				ArgsUsage: " ",
+				Description: "Print a 'Host' entry named after each VM of a project that has an IP address, to\n" +
+					"   add to ~/.ssh/config.\n\n" +
+					"   Example: \n" +
+					"   photon inventory ssh-config -p cloud-dev-staging --user root >> ~/.ssh/config \n",
+				Flags: append([]cli.Flag{
+					cli.StringFlag{
+						Name:  "user, u",
+						Usage: "User to log in as",
+					},
+					cli.StringFlag{
+						Name:  "identity-file, i",
+						Usage: "Private key to log in with",
+					},
+				}, scopeFlags...),
+				Action: func(c *cli.Context) {
+					err := printSSHConfig(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+		},
+	}
+	return command
+}
+
+// Prints the VMs of a project in the JSON format of Ansible dynamic inventories
+func printAnsibleInventory(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	groupBy := c.String("group-by")
+
+	hosts, err := getInventoryHosts(c)
+	if err != nil {
+		return err
+	}
+
+	groups := map[string][]string{}
+	hostVars := map[string]map[string]interface{}{}
+	for _, h := range hosts {
+		vars := map[string]interface{}{}
+		for key, value := range h.VM.Metadata {
+			vars[key] = value
+		}
+		vars["ansible_host"] = h.Address
+		vars["photon_id"] = h.VM.ID
+		vars["photon_flavor"] = h.VM.Flavor
+		vars["photon_host"] = h.VM.Host
+		vars["photon_state"] = h.VM.State
+		vars["photon_tags"] = h.VM.Tags
+		if len(h.VM.FloatingIp) != 0 {
+			vars["photon_floating_ip"] = h.VM.FloatingIp
+		}
+		hostVars[h.Name] = vars
+
+		for _, group := range inventoryGroups(h.VM, groupBy) {
+			groups[group] = append(groups[group], h.Name)
+		}
+	}
+
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostVars},
+	}
+	all := []string{}
+	for _, h := range hosts {
+		all = append(all, h.Name)
+	}
+	inventory["all"] = map[string]interface{}{"hosts": all}
+	for group, names := range groups {
+		inventory[group] = map[string]interface{}{"hosts": names}
+	}
+
+	buf, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", buf)
+	return err
+}
+
+// Returns the Ansible groups of a VM, e.g. "flavor_small" with --group-by flavor
+// or "role_web" with --group-by role.
+func inventoryGroups(vm photon.VM, groupBy string) []string {
+	var values []string
+	switch groupBy {
+	case "":
+		return nil
+	case "tag":
+		values = vm.Tags
+	case "flavor":
+		values = []string{vm.Flavor}
+	case "host":
+		values = []string{vm.Host}
+	default:
+		if value, ok := vm.Metadata[groupBy]; ok {
+			values = []string{value}
+		}
+	}
+
+	var groups []string
+	for _, value := range values {
+		if len(value) != 0 {
+			groups = append(groups, badGroupChars.ReplaceAllString(groupBy+"_"+value, "_"))
+		}
+	}
+	return groups
+}
+
+// Prints a ssh config entry for each VM of a project
+func printSSHConfig(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	user := c.String("user")
+	identityFile := c.String("identity-file")
+
+	hosts, err := getInventoryHosts(c)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		fmt.Fprintf(w, "Host %s\n", h.Name)
+		fmt.Fprintf(w, "  HostName %s\n", h.Address)
+		if len(user) != 0 {
+			fmt.Fprintf(w, "  User %s\n", user)
+		}
+		if len(identityFile) != 0 {
+			fmt.Fprintf(w, "  IdentityFile %s\n", identityFile)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return nil
+}
+
+// Returns the VMs of the project that have an address, sorted by name. The networks
+// of the VMs are fetched in parallel, and VMs without an address are reported on stderr.
+func getInventoryHosts(c *cli.Context) ([]inventoryHost, error) {
+	tenantName := c.String("tenant")
+	projectName := c.String("project")
+	useFloatingIp := c.Bool("use-floating-ip")
+	parallel := c.Int("parallel")
+	if parallel <= 0 {
+		parallel = defaultInventoryParallelism
+	}
+
+	var err error
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := verifyTenant(tenantName)
+	if err != nil {
+		return nil, err
+	}
+	project, err := verifyProject(tenant.ID, projectName)
+	if err != nil {
+		return nil, err
+	}
+	vms, err := client.Photonclient.Projects.GetVMs(project.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, len(vms.Items))
+	errs := make([]error, len(vms.Items))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if useFloatingIp && len(vm.FloatingIp) != 0 {
+			addresses[i] = vm.FloatingIp
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			networks, err := fetchVMNetworks(vms.Items[i].ID, false)
+			addresses[i], errs[i] = primaryIP(networks, ""), err
+		}(i)
+	}
+	wg.Wait()
+
+	names := map[string]int{}
+	for _, vm := range vms.Items {
+		names[vm.Name]++
+	}
+	var hosts []inventoryHost
+	for i, vm := range vms.Items {
+		switch {
+		case errs[i] != nil:
+			fmt.Fprintf(os.Stderr, "Skipping VM %s (%s): %s\n", vm.Name, vm.ID, errs[i])
+		case len(addresses[i]) == 0:
+			fmt.Fprintf(os.Stderr, "Skipping VM %s (%s): it has no IP address\n", vm.Name, vm.ID)
+		default:
+			// VM names are not unique, so duplicates are told apart by their ID.
+			name := vm.Name
+			if names[vm.Name] > 1 {
+				name = vm.Name + "-" + vm.ID
+			}
+			hosts = append(hosts, inventoryHost{Name: name, VM: vm, Address: addresses[i]})
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts, nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestInventory(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+
+	ips := map[string]string{}
+	for _, name := range []string{"web1", "web2", "db1"} {
+		task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{
+			Name:          name,
+			Flavor:        "small",
+			SourceImageID: imageID,
+			Tags:          []string{"app:" + name[:len(name)-1]},
+		})
+		id := waitForEntity(t, task, err)
+		task, err = api.VMs.SetMetadata(id, &photon.VmMetadata{Metadata: map[string]string{"role": name[:len(name)-1]}})
+		waitForEntity(t, task, err)
+		if name == "db1" {
+			continue
+		}
+		task, err = api.VMs.Start(id)
+		waitForEntity(t, task, err)
+		networks, err := fetchVMNetworks(id, false)
+		if err != nil {
+			t.Fatal("Not expecting error getting VM networks: " + err.Error())
+		}
+		ips[name] = primaryIP(networks, "")
+	}
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", "project1", "project")
+	set.Bool("use-floating-ip", false, "floating ip")
+	set.Int("parallel", 2, "parallel")
+	set.String("group-by", "role", "group by")
+	set.String("user", "root", "user")
+	set.String("identity-file", "", "identity file")
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	var output bytes.Buffer
+	err = printAnsibleInventory(cxt, &output)
+	if err != nil {
+		t.Fatal("Not expecting error printing inventory: " + err.Error())
+	}
+	var inventory struct {
+		All  struct{ Hosts []string }
+		Web  struct{ Hosts []string }  `json:"role_web"`
+		Db   *struct{ Hosts []string } `json:"role_db"`
+		Meta struct {
+			HostVars map[string]map[string]interface{} `json:"hostvars"`
+		} `json:"_meta"`
+	}
+	err = json.Unmarshal(output.Bytes(), &inventory)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding inventory: %s\n%s", err, output.String())
+	}
+	if !reflect.DeepEqual(inventory.All.Hosts, []string{"web1", "web2"}) ||
+		!reflect.DeepEqual(inventory.Web.Hosts, []string{"web1", "web2"}) || inventory.Db != nil {
+		t.Errorf("Expected the started VMs in group role_web, got:\n%s", output.String())
+	}
+	vars := inventory.Meta.HostVars["web2"]
+	if vars["ansible_host"] != ips["web2"] || vars["role"] != "web" || vars["photon_flavor"] != "small" {
+		t.Errorf("Unexpected host variables for web2: %v", vars)
+	}
+
+	output.Reset()
+	err = printSSHConfig(cxt, &output)
+	if err != nil {
+		t.Fatal("Not expecting error printing ssh config: " + err.Error())
+	}
+	expected := "Host web1\n  HostName " + ips["web1"] + "\n  User root\n\n" +
+		"Host web2\n  HostName " + ips["web2"] + "\n  User root\n\n"
+	if output.String() != expected {
+		t.Errorf("Expected ssh config:\n%s\ngot:\n%s", expected, output.String())
+	}
+}
+
+func TestInventoryGroups(t *testing.T) {
+	vm := photon.VM{
+		Flavor:   "core-100",
+		Host:     "10.0.0.5",
+		Tags:     []string{"app:web", "env=prod"},
+		Metadata: map[string]string{"role": "front end"},
+	}
+	cases := map[string][]string{
+		"":        nil,
+		"flavor":  {"flavor_core_100"},
+		"host":    {"host_10_0_0_5"},
+		"tag":     {"tag_app_web", "tag_env_prod"},
+		"role":    {"role_front_end"},
+		"missing": nil,
+	}
+	for groupBy, expected := range cases {
+		groups := inventoryGroups(vm, groupBy)
+		if !reflect.DeepEqual(groups, expected) {
+			t.Errorf("Expected groups %v for --group-by '%s', got %v", expected, groupBy, groups)
+		}
+	}
+}
@@ -491,21 +491,11 @@ func printServiceList(serviceList []photon.Service, w io.Writer, c *cli.Context,
 func printServiceVMs(vms []photon.VM, w io.Writer, c *cli.Context) (err error) {
 	serviceVMs := []ServiceVM{}
 	for _, vm := range vms {
-		ipAddr := "-"
 		networks, err := getVMNetworks(vm.ID, c)
 		if err != nil {
 			continue
 		}
-		for _, nt := range networks {
-			network := nt.(map[string]interface{})
-			if val, ok := network["network"]; !ok || val == nil {
-				continue
-			}
-			if val, ok := network["ipAddress"]; ok && val != nil {
-				ipAddr = val.(string)
-				break
-			}
-		}
+		ipAddr := dashIfEmpty(primaryIP(networks, ""))
 		serviceVM := ServiceVM{
 			vm,
 			ipAddr,
@@ -538,61 +528,19 @@ func printServiceVMs(vms []photon.VM, w io.Writer, c *cli.Context) (err error) {
 	return nil
 }
 
-func getVMNetworks(id string, c *cli.Context) (networks []interface{}, err error) {
-	task, err := client.Photonclient.VMs.GetNetworks(id)
-	if err != nil {
-		return nil, err
-	}
-
-	if c.GlobalIsSet("non-interactive") {
-		task, err = client.Photonclient.Tasks.Wait(task.ID)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		task, err = pollTask(task.ID)
-		if err != nil {
-			return nil, err
-		}
-	}
-	networkConnections := task.ResourceProperties.(map[string]interface{})
-	networks = networkConnections["networkConnections"].([]interface{})
-	return networks, nil
-}
-
-func printVMNetworks(networks []interface{}, isScripting bool) error {
+func printVMNetworks(networks []VMNetwork, isScripting bool) error {
 	w := new(tabwriter.Writer)
 	if !isScripting {
 		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
 		fmt.Fprintf(w, "Network\tMAC Address\tIP Address\tNetmask\tIsConnected\n")
 	}
-	for _, nt := range networks {
-		networkName := "-"
-		macAddr := "-"
-		ipAddr := "-"
-		netMask := "-"
-		isConnected := "-"
-
-		network := nt.(map[string]interface{})
-		if val, ok := network["network"]; ok && val != nil {
-			networkName = val.(string)
-		}
-		if val, ok := network["macAddress"]; ok && val != nil {
-			macAddr = val.(string)
-		}
-		if val, ok := network["ipAddress"]; ok && val != nil {
-			ipAddr = val.(string)
-		}
-		if val, ok := network["netmask"]; ok && val != nil {
-			netMask = val.(string)
-		}
-		if val, ok := network["isConnected"]; ok && val != nil {
-			isConnected = val.(string)
-		}
+	for _, n := range networks {
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", dashIfEmpty(n.Network), dashIfEmpty(n.MacAddress),
+			dashIfEmpty(n.IpAddress), dashIfEmpty(n.Netmask), dashIfEmpty(n.IsConnected))
 		if isScripting {
-			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", networkName, macAddr, ipAddr, netMask, isConnected)
+			fmt.Print(line)
 		} else {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", networkName, macAddr, ipAddr, netMask, isConnected)
+			fmt.Fprint(w, line)
 		}
 	}
 	if !isScripting {
@@ -0,0 +1,229 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Cluster and OIDC credentials of a Kubernetes service, as kubectl needs them.
+type kubectlAuth struct {
+	ClusterName string
+	Server      string
+	// PEM of the CA of the cluster, or empty to skip the verification of its certificate
+	ClusterCA []byte
+	UserName  string
+	// Config of the oidc auth provider of the user, in the order it is printed
+	AuthProvider yaml.MapSlice
+	// When set, kubectl gets the tokens of the user from service kubectl-credential
+	// for this service instead of the auth provider
+	ExecServiceID string
+}
+
+func (auth *kubectlAuth) contextName() string {
+	return auth.ClusterName + "-context"
+}
+
+func (auth *kubectlAuth) execArgs() []string {
+	return []string{"service", "kubectl-credential", auth.ExecServiceID}
+}
+
+// Prints the kubectl commands that set the cluster, user and context of a service
+// and make the context the current one.
+func printKubectlCommands(auth *kubectlAuth, w io.Writer) {
+	// Command for create the user in the kubectl config
+	fmt.Fprintf(w, "kubectl config set-credentials %s \\\n", auth.UserName)
+	if len(auth.ExecServiceID) != 0 {
+		fmt.Fprintf(w, "    --exec-api-version=%s \\\n", defaultExecCredentialAPIVersion)
+		fmt.Fprintf(w, "    --exec-command=photon \\\n")
+		for i, arg := range auth.execArgs() {
+			end := "\\"
+			if i == len(auth.execArgs())-1 {
+				end = ""
+			}
+			fmt.Fprintf(w, "    --exec-arg=%s %s\n", arg, end)
+		}
+	} else {
+		fmt.Fprintf(w, "    --auth-provider=oidc \\\n")
+		for i, arg := range auth.AuthProvider {
+			end := "\\"
+			if i == len(auth.AuthProvider)-1 {
+				end = ""
+			}
+			fmt.Fprintf(w, "    --auth-provider-arg=%s=%s %s\n", arg.Key, arg.Value, end)
+		}
+	}
+	fmt.Fprintln(w, "")
+
+	// Command for create the cluster in the kubectl config
+	fmt.Fprintf(w, "kubectl config set-cluster %s \\\n", auth.ClusterName)
+	fmt.Fprintf(w, "    --server=%s \\\n", auth.Server)
+	fmt.Fprintf(w, "    --insecure-skip-tls-verify=%t \n", len(auth.ClusterCA) == 0)
+	if len(auth.ClusterCA) != 0 {
+		fmt.Fprintf(w, "kubectl config set clusters.%s.certificate-authority-data %s \n",
+			auth.ClusterName, base64.StdEncoding.EncodeToString(auth.ClusterCA))
+	}
+	fmt.Fprintln(w, "")
+
+	// Command for create the context in the kubectl config
+	fmt.Fprintf(w, "kubectl config set-context %s \\\n", auth.contextName())
+	fmt.Fprintf(w, "    --cluster %s \\\n", auth.ClusterName)
+	fmt.Fprintf(w, "    --user=%s \n", auth.UserName)
+	fmt.Fprintln(w, "")
+
+	// Command for use the kubectl text just created as default
+	fmt.Fprintf(w, "kubectl config use-context %s \n", auth.contextName())
+}
+
+// Returns the kubeconfig file kubectl uses: the first file of $KUBECONFIG, or
+// .kube/config in the home directory.
+func defaultKubeconfigPath() string {
+	paths := filepath.SplitList(os.Getenv("KUBECONFIG"))
+	if len(paths) != 0 && len(paths[0]) != 0 {
+		return paths[0]
+	}
+	home := "HOME"
+	if runtime.GOOS == "windows" {
+		home = "USERPROFILE"
+	}
+	return filepath.Join(os.Getenv(home), ".kube", "config")
+}
+
+// Adds the cluster, user and context of a service to a kubeconfig file and makes
+// the context the current one. Entries with the same names are replaced where they
+// are, and the rest of the file is kept.
+func writeKubeconfig(path string, auth *kubectlAuth) error {
+	config := yaml.MapSlice{}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			return fmt.Errorf("Invalid kubeconfig '%s': %s", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, item := range []yaml.MapItem{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Config"},
+		{Key: "preferences", Value: yaml.MapSlice{}},
+	} {
+		if _, ok := getMapSliceValue(config, item.Key.(string)); !ok {
+			config = setMapSliceValue(config, item.Key.(string), item.Value)
+		}
+	}
+
+	cluster := yaml.MapSlice{{Key: "server", Value: auth.Server}}
+	if len(auth.ClusterCA) != 0 {
+		cluster = append(cluster, yaml.MapItem{
+			Key:   "certificate-authority-data",
+			Value: base64.StdEncoding.EncodeToString(auth.ClusterCA),
+		})
+	} else {
+		cluster = append(cluster, yaml.MapItem{Key: "insecure-skip-tls-verify", Value: true})
+	}
+	user := yaml.MapSlice{{Key: "auth-provider", Value: yaml.MapSlice{
+		{Key: "name", Value: "oidc"},
+		{Key: "config", Value: auth.AuthProvider},
+	}}}
+	if len(auth.ExecServiceID) != 0 {
+		user = yaml.MapSlice{{Key: "exec", Value: yaml.MapSlice{
+			{Key: "apiVersion", Value: defaultExecCredentialAPIVersion},
+			{Key: "command", Value: "photon"},
+			{Key: "args", Value: auth.execArgs()},
+			{Key: "interactiveMode", Value: "IfAvailable"},
+		}}}
+	}
+	context := yaml.MapSlice{
+		{Key: "cluster", Value: auth.ClusterName},
+		{Key: "user", Value: auth.UserName},
+	}
+
+	config, err = setKubeconfigEntry(config, "clusters", auth.ClusterName, "cluster", cluster)
+	if err != nil {
+		return err
+	}
+	config, err = setKubeconfigEntry(config, "users", auth.UserName, "user", user)
+	if err != nil {
+		return err
+	}
+	config, err = setKubeconfigEntry(config, "contexts", auth.contextName(), "context", context)
+	if err != nil {
+		return err
+	}
+	config = setMapSliceValue(config, "current-context", auth.contextName())
+
+	data, err = yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	// The file holds the tokens of the user
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Sets the value of the entry with the given name in a list of a kubeconfig, such
+// as the cluster of an entry of clusters. The entry is added if there is none.
+func setKubeconfigEntry(config yaml.MapSlice, list, name, key string, value interface{}) (yaml.MapSlice, error) {
+	entries := []interface{}{}
+	if v, ok := getMapSliceValue(config, list); ok && v != nil {
+		entries, ok = v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid kubeconfig: %s is not a list", list)
+		}
+	}
+
+	found := false
+	for i, e := range entries {
+		entry, ok := e.(yaml.MapSlice)
+		if !ok {
+			return nil, fmt.Errorf("Invalid kubeconfig: entry of %s is not a map", list)
+		}
+		if entryName, _ := getMapSliceValue(entry, "name"); entryName == name {
+			entries[i] = setMapSliceValue(entry, key, value)
+			found = true
+		}
+	}
+	if !found {
+		entries = append(entries, yaml.MapSlice{{Key: "name", Value: name}, {Key: key, Value: value}})
+	}
+	return setMapSliceValue(config, list, entries), nil
+}
+
+func getMapSliceValue(m yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Sets the value of a key, keeping its place if it is already in the map.
+func setMapSliceValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
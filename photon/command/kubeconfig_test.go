@@ -0,0 +1,299 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const existingKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: other
+  cluster:
+    server: https://10.0.0.1:6443
+- name: k8s
+  cluster:
+    server: https://10.0.0.2:6443
+    insecure-skip-tls-verify: true
+users:
+- name: admin@tenant1
+  user:
+    token: old-token
+contexts: []
+current-context: other-context
+preferences:
+  colors: true
+`
+
+type testKubeconfig struct {
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string                 `yaml:"name"`
+		User map[string]interface{} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string            `yaml:"name"`
+		Context map[string]string `yaml:"context"`
+	} `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	Preferences    map[string]interface{} `yaml:"preferences"`
+}
+
+func testKubectlAuth() *kubectlAuth {
+	return &kubectlAuth{
+		ClusterName: "k8s",
+		Server:      "https://10.0.0.3:6443",
+		ClusterCA:   []byte("-----BEGIN CERTIFICATE-----\n"),
+		UserName:    "admin@tenant1",
+		AuthProvider: yaml.MapSlice{
+			{Key: "client-id", Value: "client-1"},
+			{Key: "id-token", Value: "token-1"},
+		},
+	}
+}
+
+func TestWriteKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(path, []byte(existingKubeconfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := testKubectlAuth()
+	err = writeKubeconfig(path, auth)
+	if err != nil {
+		t.Fatal("Not expecting error writing kubeconfig: " + err.Error())
+	}
+	// Writing the same service again must not add entries
+	err = writeKubeconfig(path, auth)
+	if err != nil {
+		t.Fatal("Not expecting error writing kubeconfig: " + err.Error())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config testKubeconfig
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		t.Fatalf("Expected a valid kubeconfig, got %s\n%s", err, data)
+	}
+
+	if len(config.Clusters) != 2 || config.Clusters[0].Name != "other" || config.Clusters[1].Name != "k8s" {
+		t.Fatalf("Expected the cluster to be updated in place, got %+v", config.Clusters)
+	}
+	cluster := config.Clusters[1].Cluster
+	if cluster.Server != auth.Server || cluster.InsecureSkipTLSVerify ||
+		cluster.CertificateAuthorityData != base64.StdEncoding.EncodeToString(auth.ClusterCA) {
+		t.Errorf("Expected the cluster to have the server and CA of the service, got %+v", cluster)
+	}
+	if len(config.Users) != 1 || config.Users[0].User["token"] != nil {
+		t.Errorf("Expected the user to be replaced, got %+v", config.Users)
+	}
+	provider, _ := config.Users[0].User["auth-provider"].(map[interface{}]interface{})
+	providerConfig, _ := provider["config"].(map[interface{}]interface{})
+	if provider["name"] != "oidc" || providerConfig["id-token"] != "token-1" {
+		t.Errorf("Expected the user to have the oidc auth provider, got %+v", config.Users[0].User)
+	}
+	if len(config.Contexts) != 1 || config.Contexts[0].Name != "k8s-context" ||
+		config.Contexts[0].Context["cluster"] != "k8s" || config.Contexts[0].Context["user"] != "admin@tenant1" {
+		t.Errorf("Expected the context to be added, got %+v", config.Contexts)
+	}
+	if config.CurrentContext != "k8s-context" || config.Preferences["colors"] != true {
+		t.Errorf("Expected the current context to be set and the preferences kept, got %+v", config)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the kubeconfig to be readable by its owner only, got %v, %v", info.Mode(), err)
+	}
+
+	// A new file is created with the entries of the service
+	path = filepath.Join(dir, "new", "config")
+	err = writeKubeconfig(path, auth)
+	if err != nil {
+		t.Fatal("Not expecting error writing kubeconfig: " + err.Error())
+	}
+	data, _ = ioutil.ReadFile(path)
+	if !strings.HasPrefix(string(data), "apiVersion: v1\nkind: Config\n") ||
+		!strings.Contains(string(data), "current-context: k8s-context\n") {
+		t.Errorf("Expected a new kubeconfig, got:\n%s", data)
+	}
+}
+
+func TestPrintKubectlCommands(t *testing.T) {
+	var output bytes.Buffer
+	printKubectlCommands(testKubectlAuth(), &output)
+	for _, line := range []string{
+		"kubectl config set-credentials admin@tenant1 \\",
+		"    --auth-provider-arg=id-token=token-1 ",
+		"    --insecure-skip-tls-verify=false ",
+		"kubectl config set clusters.k8s.certificate-authority-data " +
+			base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----\n")) + " ",
+		"kubectl config use-context k8s-context ",
+	} {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("Expected commands to contain '%s', got:\n%s", line, output.String())
+		}
+	}
+
+	auth := testKubectlAuth()
+	auth.ExecServiceID = "service-1"
+	output.Reset()
+	printKubectlCommands(auth, &output)
+	if !strings.Contains(output.String(), "    --exec-command=photon \\\n") ||
+		!strings.Contains(output.String(), "    --exec-arg=service-1 \n") ||
+		strings.Contains(output.String(), "--auth-provider") {
+		t.Errorf("Expected the user to run service kubectl-credential, got:\n%s", output.String())
+	}
+}
+
+func TestGetServerCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := getServerCACert(u.Host)
+	if err != nil {
+		t.Fatal("Not expecting error getting the CA of the server: " + err.Error())
+	}
+	if !cert.Equal(server.Certificate()) {
+		t.Errorf("Expected the certificate of the server, got %s", cert.Subject)
+	}
+}
+
+func TestGetClusterCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	dir, err := ioutil.TempDir("", "cluster-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, serverPEM, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	err = ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newContext := func(nonInteractive bool, args ...string) *cli.Context {
+		globalSet := flag.NewFlagSet("test", 0)
+		globalSet.Bool("non-interactive", false, "doc")
+		globalArgs := []string{}
+		if nonInteractive {
+			globalArgs = append(globalArgs, "--non-interactive")
+		}
+		err := globalSet.Parse(globalArgs)
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		set := flag.NewFlagSet("test", 0)
+		set.String("cluster-ca", "", "cluster CA")
+		err = set.Parse(args)
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+	}
+
+	// The certificate presented by the cluster is not trusted without confirmation
+	_, err = getClusterCA(newContext(true), u.Host)
+	if err == nil || !strings.Contains(err.Error(), "use --cluster-ca") {
+		t.Errorf("Expected the cluster to be refused in non-interactive mode, got %v", err)
+	}
+
+	ca, err := getClusterCA(newContext(true, "--cluster-ca", caFile), u.Host)
+	if err != nil || !bytes.Equal(ca, serverPEM) {
+		t.Errorf("Expected the CA to be read from the file, got %v", err)
+	}
+	_, err = getClusterCA(newContext(true, "--cluster-ca", invalidFile), u.Host)
+	if err == nil || !strings.Contains(err.Error(), "is not a PEM encoded certificate") {
+		t.Errorf("Expected an invalid CA file to be reported, got %v", err)
+	}
+
+	// In interactive mode the user confirms the fetched certificate
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	answer := func(input string) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.WriteString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = r
+	}
+	answer("yes\n")
+	ca, err = getClusterCA(newContext(false), u.Host)
+	if err != nil || !bytes.Equal(ca, serverPEM) {
+		t.Errorf("Expected the confirmed certificate of the cluster, got %v", err)
+	}
+	answer("no\n")
+	_, err = getClusterCA(newContext(false), u.Host)
+	if err == nil || !strings.Contains(err.Error(), "is not trusted") {
+		t.Errorf("Expected a rejected certificate to be reported, got %v", err)
+	}
+
+	server.Close()
+	_, err = getClusterCA(newContext(false), u.Host)
+	if err == nil || !strings.Contains(err.Error(), "use --cluster-ca") {
+		t.Errorf("Expected an unreachable cluster to be reported, got %v", err)
+	}
+}
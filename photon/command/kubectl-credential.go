@@ -0,0 +1,192 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon/lightwave"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Version of the client-go exec credential protocol, when kubectl does not give one
+const defaultExecCredentialAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+// Tokens that expire within this time are refreshed, so that they do not expire
+// while kubectl uses them.
+var kubectlTokenExpiryMargin = time.Minute
+
+// Credential printed for kubectl by an exec credential plugin.
+type execCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+type execCredentialStatus struct {
+	Token               string `json:"token"`
+	ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+}
+
+// Prints an ExecCredential with the id token of a user for a Kubernetes service.
+// The tokens are cached in the CLI config directory and refreshed when they expire,
+// with the refresh token or else with the password of the user. The API is only
+// contacted to refresh them.
+func getKubectlCredential(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	username := c.String("username")
+	password := c.String("password")
+
+	tokens, err := cf.LoadServiceTokens(id)
+	if err != nil {
+		return err
+	}
+	// The tokens of another user are no use, the client of the service still is
+	if tokens == nil {
+		tokens = &cf.ServiceTokens{Username: username}
+	} else if len(username) != 0 && username != tokens.Username {
+		tokens = &cf.ServiceTokens{ClientID: tokens.ClientID, Username: username}
+	}
+
+	expires := getTokenExpiry(tokens.IdToken)
+	if expires.Before(time.Now().Add(kubectlTokenExpiryMargin)) {
+		client.Photonclient, err = client.GetClient(c)
+		if err != nil {
+			return err
+		}
+		if len(tokens.ClientID) == 0 {
+			service, err := client.Photonclient.Services.Get(id)
+			if err != nil {
+				return err
+			}
+			tokens.ClientID = service.ClientID
+		}
+
+		err = refreshServiceTokens(c, tokens, password)
+		if err != nil {
+			return err
+		}
+		err = cf.SaveServiceTokens(id, tokens)
+		if err != nil {
+			return err
+		}
+		expires = getTokenExpiry(tokens.IdToken)
+	}
+
+	credential := execCredential{
+		APIVersion: getExecCredentialAPIVersion(),
+		Kind:       "ExecCredential",
+		Status:     execCredentialStatus{Token: tokens.IdToken},
+	}
+	if !expires.IsZero() {
+		credential.Status.ExpirationTimestamp = expires.UTC().Format(time.RFC3339)
+	}
+	return json.NewEncoder(w).Encode(credential)
+}
+
+// Gets new tokens for the OIDC client of a service, with the cached refresh token if
+// it is still valid, or else with the username and password of the user.
+func refreshServiceTokens(c *cli.Context, tokens *cf.ServiceTokens, password string) error {
+	var refreshErr error
+	if len(tokens.RefreshToken) != 0 && len(password) == 0 {
+		options, err := client.Photonclient.Auth.GetTokensByRefreshToken(tokens.RefreshToken)
+		switch {
+		case err != nil:
+			refreshErr = err
+		case len(options.IdToken) == 0:
+			refreshErr = fmt.Errorf("no id token was returned")
+		default:
+			tokens.IdToken = options.IdToken
+			if len(options.RefreshToken) != 0 {
+				tokens.RefreshToken = options.RefreshToken
+			}
+			return nil
+		}
+	}
+
+	// kubectl reads the credential on stdout, so the prompts go to stderr, and only
+	// when kubectl lets the plugin use the terminal.
+	if !c.GlobalIsSet("non-interactive") && terminal.IsTerminal(int(syscall.Stdin)) {
+		if len(tokens.Username) == 0 {
+			fmt.Fprintf(os.Stderr, "User name (username@tenant): ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			tokens.Username = strings.TrimSpace(line)
+		}
+		if len(password) == 0 {
+			fmt.Fprintf(os.Stderr, "Password for %s: ", tokens.Username)
+			// Casting syscall.Stdin to int because during
+			// Windows cross-compilation syscall.Stdin is incorrectly
+			// treated as a String.
+			bytePassword, err := terminal.ReadPassword(int(syscall.Stdin))
+			if err != nil {
+				return err
+			}
+			password = string(bytePassword)
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+	}
+	if len(tokens.Username) == 0 || len(password) == 0 {
+		if refreshErr != nil {
+			return fmt.Errorf("The cached tokens of the service have expired and could not be refreshed (%s), "+
+				"please provide a valid username/password", refreshErr)
+		}
+		return fmt.Errorf("The cached tokens of the service have expired, please provide a valid username/password")
+	}
+
+	options, err := client.Photonclient.Auth.GetClientTokensByPassword(tokens.Username, password, tokens.ClientID)
+	if err != nil {
+		return err
+	}
+	tokens.IdToken = options.IdToken
+	tokens.RefreshToken = options.RefreshToken
+	return nil
+}
+
+// Returns the expiry of a JWT token, or the zero time if it has none.
+func getTokenExpiry(token string) time.Time {
+	if len(token) == 0 {
+		return time.Time{}
+	}
+	expires := lightwave.ParseTokenDetails(token).Expires
+	if expires == 0 {
+		return time.Time{}
+	}
+	return time.Unix(expires, 0)
+}
+
+// Returns the version of the protocol kubectl asks for in $KUBERNETES_EXEC_INFO.
+func getExecCredentialAPIVersion() string {
+	var info struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	err := json.Unmarshal([]byte(os.Getenv("KUBERNETES_EXEC_INFO")), &info)
+	if err != nil || len(info.APIVersion) == 0 {
+		return defaultExecCredentialAPIVersion
+	}
+	return info.APIVersion
+}
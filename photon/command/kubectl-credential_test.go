@@ -0,0 +1,210 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func testJWT(subject string, expires time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." +
+		encode([]byte(fmt.Sprintf(`{"sub":"%s","exp":%d}`, subject, expires.Unix()))) + ".c2ln"
+}
+
+func runKubectlCredential(t *testing.T, id string, args []string) (*execCredential, error) {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("username", "", "username")
+	set.String("password", "", "password")
+	err = set.Parse(append(args, id))
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = getKubectlCredential(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	if err != nil {
+		return nil, err
+	}
+	credential := &execCredential{}
+	err = json.Unmarshal(output.Bytes(), credential)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding the credential: %s\n%s", err, output.String())
+	}
+	return credential, nil
+}
+
+func TestKubectlCredential(t *testing.T) {
+	var err error
+	cf.UserConfigDir, err = ioutil.TempDir("", "kubectl-credential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(cf.UserConfigDir)
+		cf.UserConfigDir = ""
+	}()
+
+	// Lightwave token endpoint, which hands out tokens that expire in an hour
+	var grants []string
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	tokenServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			t.Error("Not expecting error parsing token request: " + err.Error())
+		}
+		grant := r.Form.Get("grant_type")
+		grants = append(grants, grant)
+		valid := grant == "password" && r.Form.Get("password") == "secret" && r.Form.Get("client_id") != "" ||
+			grant == "refresh_token" && r.Form.Get("refresh_token") == "refresh-1"
+		if !valid {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		err = json.NewEncoder(w).Encode(photon.TokenOptions{
+			IdToken:      testJWT(grant, expires),
+			RefreshToken: "refresh-1",
+		})
+		if err != nil {
+			t.Error("Not expecting error writing tokens: " + err.Error())
+		}
+	}))
+	defer tokenServer.Close()
+	lightwaveURL, _ := url.Parse(tokenServer.URL)
+	port, _ := strconv.Atoi(lightwaveURL.Port())
+
+	sim := simulator.NewServer()
+	defer sim.Close()
+	sim.SetAuthInfo(photon.AuthInfo{Endpoint: lightwaveURL.Hostname(), Port: port, Domain: "photon.local"})
+	api := photon.NewTestClient(sim.URL, &photon.ClientOptions{TaskPollDelay: time.Millisecond,
+		IgnoreCertificate: true}, &http.Client{})
+	client.Photonclient = api
+
+	imageID := sim.AddImage("kubernetes", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{Name: "project1"})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateService(projectID, &photon.ServiceCreateSpec{
+		Name:        "k8s",
+		Type:        "KUBERNETES",
+		ImageID:     imageID,
+		WorkerCount: 1,
+	})
+	serviceID := waitForEntity(t, task, err)
+	service, err := api.Services.Get(serviceID)
+	if err != nil {
+		t.Fatal("Not expecting error getting service: " + err.Error())
+	}
+
+	_, err = runKubectlCredential(t, serviceID, nil)
+	if err == nil {
+		t.Fatal("Expected an error without cached tokens or password")
+	}
+
+	err = os.Setenv("KUBERNETES_EXEC_INFO", `{"apiVersion": "client.authentication.k8s.io/v1", "kind": "ExecCredential"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Unsetenv("KUBERNETES_EXEC_INFO")
+	}()
+	credential, err := runKubectlCredential(t, serviceID, []string{"-username=admin@tenant1", "-password=secret"})
+	if err != nil {
+		t.Fatal("Not expecting error getting the credential: " + err.Error())
+	}
+	if credential.APIVersion != "client.authentication.k8s.io/v1" || credential.Kind != "ExecCredential" ||
+		credential.Status.Token != testJWT("password", expires) ||
+		credential.Status.ExpirationTimestamp != expires.UTC().Format(time.RFC3339) {
+		t.Errorf("Expected the credential of the password grant, got %+v", credential)
+	}
+
+	tokens, err := cf.LoadServiceTokens(serviceID)
+	if err != nil || tokens.ClientID != service.ClientID {
+		t.Errorf("Expected the client ID of the service to be cached, got %+v, %v", tokens, err)
+	}
+
+	// The cached token is still valid, and the service is not looked up again
+	sim.InjectFault(simulator.Fault{
+		Method:     "GET",
+		Path:       "/services/*",
+		StatusCode: 404,
+		Error:      photon.ApiError{Code: "NotFound", Message: "not expecting the service to be looked up"},
+	})
+	grants = nil
+	credential, err = runKubectlCredential(t, serviceID, nil)
+	if err != nil || credential.Status.Token != testJWT("password", expires) || len(grants) != 0 {
+		t.Errorf("Expected the cached token, got %+v, %v after %v", credential, err, grants)
+	}
+
+	// An expired token is refreshed with the refresh token
+	err = cf.SaveServiceTokens(serviceID, &cf.ServiceTokens{
+		ClientID:     service.ClientID,
+		Username:     "admin@tenant1",
+		IdToken:      testJWT("password", time.Now().Add(-time.Minute)),
+		RefreshToken: "refresh-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Unsetenv("KUBERNETES_EXEC_INFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err = runKubectlCredential(t, serviceID, nil)
+	if err != nil || credential.Status.Token != testJWT("refresh_token", expires) ||
+		credential.APIVersion != defaultExecCredentialAPIVersion {
+		t.Errorf("Expected the credential of the refresh token grant, got %+v, %v", credential, err)
+	}
+	tokens, err = cf.LoadServiceTokens(serviceID)
+	if err != nil || tokens.IdToken != testJWT("refresh_token", expires) {
+		t.Errorf("Expected the refreshed tokens to be cached, got %+v, %v", tokens, err)
+	}
+
+	// When the refresh token has expired too, the password is needed
+	tokens.IdToken = ""
+	tokens.RefreshToken = "expired"
+	err = cf.SaveServiceTokens(serviceID, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants = nil
+	_, err = runKubectlCredential(t, serviceID, nil)
+	if err == nil || len(grants) != 1 || grants[0] != "refresh_token" || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected an error with the cause of the failed refresh token grant, got %v after %v", err, grants)
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Creates a cli.Command for migrate
+// Usage: migrate --from <profile> --to <profile> --tenant <name> [<options>]
+func GetMigrateCommand() cli.Command {
+	command := cli.Command{
+		Name:      "migrate",
+		Usage:     "Recreate a tenant and its configuration on another deployment",
+		ArgsUsage: " ",
+		Description: "Read the configuration of a tenant from one deployment and replay it on another one.\n" +
+			"   Both deployments are referred to by profile names, see 'photon target save-profile'.\n" +
+			"   You must be a system administrator on both deployments.\n\n" +
+			"   The following are migrated:\n" +
+			"     - flavors that do not exist on the target yet\n" +
+			"     - the tenant with its quota, security groups and IAM policy\n" +
+			"     - projects with their quota, security groups, IAM policy, routers and subnets\n" +
+			"     - with --with-disks, persistent disks (without their contents)\n" +
+			"     - with --with-vms, VMs that are not running (without their contents)\n\n" +
+			"   Flavors and images are matched by name on the target. Images are not copied;\n" +
+			"   VMs whose image does not exist on the target, running VMs and projects that already\n" +
+			"   exist on the target are listed in a report at the end.\n\n" +
+			"   Example:\n" +
+			"      photon migrate --from old --to new --tenant tenant1 --with-disks --with-vms",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "from",
+				Usage: "Profile of the deployment to read from",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Usage: "Profile of the deployment to write to",
+			},
+			cli.StringFlag{
+				Name:  "tenant, t",
+				Usage: "Name of the tenant to migrate",
+			},
+			cli.BoolFlag{
+				Name:  "with-vms",
+				Usage: "Recreate VMs that are not running",
+			},
+			cli.BoolFlag{
+				Name:  "with-disks",
+				Usage: "Recreate persistent disks",
+			},
+		},
+		Action: func(c *cli.Context) {
+			err := migrate(c, os.Stdout)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+		},
+	}
+	return command
+}
+
+// Summary of a migration, printed at the end of the migrate command.
+type migrationReport struct {
+	Tenant      string   `json:"tenant"`
+	Flavors     []string `json:"flavors"`
+	Projects    []string `json:"projects"`
+	NotMigrated []string `json:"notMigrated"`
+}
+
+// tenantMigration replays the configuration of a tenant from one deployment on another.
+type tenantMigration struct {
+	src       *photon.Client
+	dst       *photon.Client
+	withVMs   bool
+	withDisks bool
+	// Receives one line per object created, may be nil.
+	progress io.Writer
+
+	dstFlavors map[flavorKey]bool
+	images     map[string]string
+	report     migrationReport
+}
+
+// Migrates a tenant between the deployments of two profiles.
+// Returns an error if one occurred
+func migrate(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	from := c.String("from")
+	to := c.String("to")
+	tenantName := c.String("tenant")
+
+	if len(from) == 0 || len(to) == 0 {
+		return fmt.Errorf("Please provide both --from and --to profiles")
+	}
+	if from == to {
+		return fmt.Errorf("The --from and --to profiles must be different")
+	}
+	if len(tenantName) == 0 {
+		return fmt.Errorf("Please provide tenant name")
+	}
+
+	src, err := client.NewProfileClient(from)
+	if err != nil {
+		return err
+	}
+	dst, err := client.NewProfileClient(to)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Printf("\nMigrating tenant '%s' from '%s' to '%s'\n", tenantName, src.Endpoint, dst.Endpoint)
+	}
+	if !confirmed(c) {
+		fmt.Println("OK. Canceled")
+		return nil
+	}
+
+	m := &tenantMigration{
+		src:       src,
+		dst:       dst,
+		withVMs:   c.Bool("with-vms"),
+		withDisks: c.Bool("with-disks"),
+	}
+	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
+		m.progress = os.Stdout
+	}
+
+	// The report is printed even when the migration stops half way, so
+	// that the user knows what has been created already.
+	migrationErr := m.run(tenantName)
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, f := range m.report.Flavors {
+			fmt.Printf("flavor\t%s\n", f)
+		}
+		for _, p := range m.report.Projects {
+			fmt.Printf("project\t%s\n", p)
+		}
+		for _, n := range m.report.NotMigrated {
+			fmt.Printf("not-migrated\t%s\n", n)
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObject(m.report, w, c)
+	} else {
+		fmt.Printf("\nMigrated tenant '%s': %d flavors and %d projects created\n",
+			m.report.Tenant, len(m.report.Flavors), len(m.report.Projects))
+		if len(m.report.NotMigrated) != 0 {
+			fmt.Printf("\nThe following could not be migrated:\n")
+			for _, n := range m.report.NotMigrated {
+				fmt.Printf("  %s\n", n)
+			}
+		}
+	}
+
+	return migrationErr
+}
+
+// Migrates flavors, the tenant and its projects.
+func (m *tenantMigration) run(tenantName string) error {
+	m.report.Tenant = tenantName
+
+	srcTenant, err := findTenantByName(m.src, tenantName)
+	if err != nil {
+		return err
+	}
+	if srcTenant == nil {
+		return fmt.Errorf("Cannot find tenant '%s' on the source deployment", tenantName)
+	}
+
+	err = m.migrateFlavors()
+	if err != nil {
+		return err
+	}
+
+	dstTenantID, err := m.migrateTenant(srcTenant)
+	if err != nil {
+		return err
+	}
+
+	return m.migrateProjects(srcTenant.ID, dstTenantID)
+}
+
+// Flavors are unique per name and kind, a vm flavor and a disk flavor may share a name.
+type flavorKey struct {
+	name string
+	kind string
+}
+
+// Creates the flavors that do not exist on the target yet.
+func (m *tenantMigration) migrateFlavors() error {
+	srcFlavors, err := m.src.Flavors.GetAll(nil)
+	if err != nil {
+		return err
+	}
+	dstFlavors, err := m.dst.Flavors.GetAll(nil)
+	if err != nil {
+		return err
+	}
+
+	m.dstFlavors = map[flavorKey]bool{}
+	for _, f := range dstFlavors.Items {
+		m.dstFlavors[flavorKey{f.Name, f.Kind}] = true
+	}
+
+	for _, f := range srcFlavors.Items {
+		key := flavorKey{f.Name, f.Kind}
+		if m.dstFlavors[key] || f.State == "ERROR" || f.State == "PENDING_DELETE" {
+			continue
+		}
+		task, err := m.dst.Flavors.Create(&photon.FlavorCreateSpec{Name: f.Name, Kind: f.Kind, Cost: f.Cost})
+		if err != nil {
+			return err
+		}
+		_, err = m.dst.Tasks.Wait(task.ID)
+		if err != nil {
+			return err
+		}
+		m.dstFlavors[key] = true
+		m.report.Flavors = append(m.report.Flavors, f.Name)
+		m.logf("Created flavor '%s'\n", f.Name)
+	}
+	return nil
+}
+
+// Creates the tenant on the target unless it already exists.
+// Returns the ID of the tenant on the target.
+func (m *tenantMigration) migrateTenant(srcTenant *photon.Tenant) (string, error) {
+	dstTenant, err := findTenantByName(m.dst, srcTenant.Name)
+	if err != nil {
+		return "", err
+	}
+	if dstTenant != nil {
+		m.notMigrated("tenant '%s' already exists on the target, its settings were left unchanged", srcTenant.Name)
+		return dstTenant.ID, nil
+	}
+
+	spec := &photon.TenantCreateSpec{Name: srcTenant.Name}
+	for _, s := range srcTenant.SecurityGroups {
+		if !s.Inherited {
+			spec.SecurityGroups = append(spec.SecurityGroups, s.Name)
+		}
+	}
+	quota, err := m.src.Tenants.GetQuota(srcTenant.ID)
+	if err != nil {
+		return "", err
+	}
+	if quota != nil {
+		spec.ResourceQuota.QuotaLineItems = photon.QuotaSpec{}
+		for key, item := range quota.QuotaLineItems {
+			spec.ResourceQuota.QuotaLineItems[key] = photon.QuotaStatusLineItem{Unit: item.Unit, Limit: item.Limit}
+		}
+	}
+
+	task, err := m.dst.Tenants.Create(spec)
+	if err != nil {
+		return "", err
+	}
+	task, err = m.dst.Tasks.Wait(task.ID)
+	if err != nil {
+		return "", err
+	}
+	tenantID := task.Entity.ID
+	m.logf("Created tenant '%s' (%s)\n", srcTenant.Name, tenantID)
+
+	policy, err := m.src.Tenants.GetIam(srcTenant.ID)
+	if err != nil {
+		return tenantID, err
+	}
+	if policy != nil && len(*policy) != 0 {
+		task, err = m.dst.Tenants.SetIam(tenantID, policy)
+		if err != nil {
+			return tenantID, err
+		}
+		_, err = m.dst.Tasks.Wait(task.ID)
+		if err != nil {
+			return tenantID, err
+		}
+	}
+	return tenantID, nil
+}
+
+// Recreates every project of the source tenant that does not exist on the target.
+func (m *tenantMigration) migrateProjects(srcTenantID, dstTenantID string) error {
+	srcProjects, err := m.src.Tenants.GetProjects(srcTenantID, nil)
+	if err != nil {
+		return err
+	}
+	dstProjects, err := m.dst.Tenants.GetProjects(dstTenantID, nil)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, p := range dstProjects.Items {
+		existing[p.Name] = true
+	}
+
+	for i := range srcProjects.Items {
+		project := srcProjects.Items[i]
+		if existing[project.Name] {
+			m.notMigrated("project '%s' already exists on the target", project.Name)
+			continue
+		}
+
+		// Network ranges are private to a project, so they are kept as they are.
+		cidrs, _ := newCidrMapper("", "")
+		cloner := &projectCloner{
+			src:            m.src,
+			dst:            m.dst,
+			cidrs:          cidrs,
+			withVMs:        m.withVMs,
+			withDisks:      m.withDisks,
+			skipRunningVMs: true,
+			mapFlavor:      m.mapFlavor,
+			mapImage:       m.mapImage,
+			progress:       m.progress,
+		}
+		_, err = cloner.clone(&project, dstTenantID, project.Name)
+		for _, s := range cloner.skipped {
+			m.notMigrated("project '%s': %s", project.Name, s)
+		}
+		if err != nil {
+			return fmt.Errorf("migrating project '%s': %s", project.Name, err)
+		}
+		m.report.Projects = append(m.report.Projects, project.Name)
+	}
+	return nil
+}
+
+// Flavors are referenced by name, so a flavor of the same kind only has to exist on the target.
+func (m *tenantMigration) mapFlavor(name, kind string) (string, error) {
+	if !m.dstFlavors[flavorKey{name, kind}] {
+		return "", fmt.Errorf("%s flavor '%s' does not exist on the target", kind, name)
+	}
+	return name, nil
+}
+
+// Finds the image on the target with the same name as the source image.
+func (m *tenantMigration) mapImage(id string) (string, error) {
+	if m.images == nil {
+		m.images = map[string]string{}
+	}
+	if dstID, ok := m.images[id]; ok {
+		return dstID, nil
+	}
+
+	image, err := m.src.Images.Get(id)
+	if err != nil {
+		return "", err
+	}
+	candidates, err := m.dst.Images.GetAll(&photon.ImageGetOptions{Name: image.Name})
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range candidates.Items {
+		if candidate.Name == image.Name && candidate.State == "READY" {
+			m.images[id] = candidate.ID
+			return candidate.ID, nil
+		}
+	}
+	return "", fmt.Errorf("image '%s' (%s) must be re-uploaded to the target", image.Name, id)
+}
+
+func (m *tenantMigration) logf(format string, args ...interface{}) {
+	if m.progress != nil {
+		fmt.Fprintf(m.progress, format, args...)
+	}
+}
+
+func (m *tenantMigration) notMigrated(format string, args ...interface{}) {
+	m.report.NotMigrated = append(m.report.NotMigrated, fmt.Sprintf(format, args...))
+}
+
+// Finds a tenant by name, returns nil if there is no such tenant.
+func findTenantByName(api *photon.Client, name string) (*photon.Tenant, error) {
+	tenants, err := api.Tenants.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range tenants.Items {
+		if tenants.Items[i].Name == name {
+			return &tenants.Items[i], nil
+		}
+	}
+	return nil, nil
+}
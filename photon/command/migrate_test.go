@@ -0,0 +1,101 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestMigrateTenant(t *testing.T) {
+	srcServer := mocks.NewTestServer()
+	defer srcServer.Close()
+	dstServer := mocks.NewTestServer()
+	defer dstServer.Close()
+	src := srcServer.URL + rootUrl
+	dst := dstServer.URL + rootUrl
+
+	// Source deployment
+	registerJSONResponder(t, src+"/tenants", photon.Tenants{
+		Items: []photon.Tenant{{Name: "tenant1", ID: "src_tenant_ID"}},
+	})
+	registerJSONResponder(t, src+"/flavors", photon.FlavorList{
+		Items: []photon.Flavor{
+			{Name: "small", Kind: "vm", State: "READY"},
+			{Name: "large", Kind: "vm", State: "READY"},
+			{Name: "disk", Kind: "ephemeral-disk", State: "READY"},
+			{Name: "small", Kind: "ephemeral-disk", State: "READY"},
+		},
+	})
+	registerJSONResponder(t, src+"/tenants/src_tenant_ID/quota", photon.Quota{})
+	registerJSONResponder(t, src+"/tenants/src_tenant_ID/iam", []photon.PolicyEntry{})
+	registerJSONResponder(t, src+"/tenants/src_tenant_ID/projects", photon.ProjectList{
+		Items: []photon.ProjectCompact{{Name: "project1", ID: "src_project_ID"}},
+	})
+	registerJSONResponder(t, src+"/projects/src_project_ID/quota", photon.Quota{})
+	registerJSONResponder(t, src+"/projects/src_project_ID/iam", []photon.PolicyEntry{})
+	registerJSONResponder(t, src+"/projects/src_project_ID/routers", photon.Routers{
+		Items: []photon.Router{{ID: "src_router", Name: "default", PrivateIpCidr: "192.168.0.0/16", IsDefault: true}},
+	})
+	registerJSONResponder(t, src+"/routers/src_router/subnets", photon.Subnets{})
+	registerJSONResponder(t, src+"/projects/src_project_ID/vms", photon.VMs{
+		Items: []photon.VM{
+			{ID: "vm1", Name: "stopped-vm", State: "STOPPED", Flavor: "large", SourceImageID: "src_image"},
+			{ID: "vm2", Name: "running-vm", State: "STARTED", Flavor: "small", SourceImageID: "src_image"},
+		},
+	})
+	registerJSONResponder(t, src+"/images/src_image", photon.Image{ID: "src_image", Name: "ubuntu"})
+
+	// Target deployment
+	registerJSONResponder(t, dst+"/tenants", photon.Tenants{})
+	registerJSONResponder(t, dst+"/flavors", photon.FlavorList{
+		Items: []photon.Flavor{{Name: "small", Kind: "vm", State: "READY"}},
+	})
+	registerJSONResponder(t, dst+"/tenants/dst_tenant_ID/projects", photon.ProjectList{})
+	registerJSONResponder(t, dst+"/projects/dst_project_ID/routers", photon.Routers{
+		Items: []photon.Router{{ID: "dst_router", Name: "default", PrivateIpCidr: "192.168.0.0/16", IsDefault: true}},
+	})
+	registerJSONResponder(t, dst+"/routers/dst_router/subnets", photon.Subnets{})
+	registerJSONResponder(t, dst+"/images?name=ubuntu", photon.Images{})
+	registerCompletedTask(t, dst, dst+"/flavors", "CREATE_FLAVOR", "flavor-task", "dst_flavor_ID")
+	registerCompletedTask(t, dst, dst+"/tenants", "CREATE_TENANT", "tenant-task", "dst_tenant_ID")
+	registerCompletedTask(t, dst, dst+"/tenants/dst_tenant_ID/projects", "CREATE_PROJECT", "project-task", "dst_project_ID")
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+
+	m := &tenantMigration{
+		src:     photon.NewTestClient(srcServer.URL, nil, httpClient),
+		dst:     photon.NewTestClient(dstServer.URL, nil, httpClient),
+		withVMs: true,
+	}
+	err := m.run("tenant1")
+	if err != nil {
+		t.Fatal("Not expecting error migrating tenant: " + err.Error())
+	}
+
+	expected := migrationReport{
+		Tenant:   "tenant1",
+		Flavors:  []string{"large", "disk", "small"},
+		Projects: []string{"project1"},
+		NotMigrated: []string{
+			"project 'project1': VM 'stopped-vm': image 'ubuntu' (src_image) must be re-uploaded to the target",
+			"project 'project1': VM 'running-vm' is running, stop it before migrating it",
+		},
+	}
+	if !reflect.DeepEqual(m.report, expected) {
+		t.Errorf("Unexpected migration report: %+v", m.report)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Default pool and prefix length of router ranges allocated with --auto-cidr,
+// and default prefix length of the blocks proposed by network plan
+const (
+	defaultRouterCidrPool     = "10.0.0.0/8"
+	defaultRouterPrefixLength = 16
+	defaultSubnetPrefixLength = 24
+	defaultPlanPrefixLength   = 24
+)
+
+// Private IP range of a router or subnet.
+type usedCidr struct {
+	Cidr  string `json:"cidr"`
+	Owner string `json:"owner"`
+}
+
+// Blocks of a pool that are used, and the free ones proposed for new routers or subnets.
+type networkPlan struct {
+	Pool         string     `json:"pool"`
+	PrefixLength int        `json:"prefixLength"`
+	Used         []usedCidr `json:"used"`
+	Proposed     []string   `json:"proposed"`
+}
+
+// Proposes free blocks of a pool for new routers or subnets
+func planNetwork(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	pool := c.String("pool")
+	prefixLength := c.Int("prefix-length")
+	count := c.Int("count")
+	routerID := c.String("router")
+
+	if len(pool) == 0 && len(routerID) == 0 {
+		return fmt.Errorf("Please provide a pool or a router")
+	}
+	if len(pool) != 0 {
+		err = checkCidrFromFlag("pool", pool)
+		if err != nil {
+			return err
+		}
+	}
+	if count < 1 {
+		return fmt.Errorf("Invalid --count %d, expected at least 1", count)
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	var used []usedCidr
+	if len(routerID) != 0 {
+		router, err := client.Photonclient.Routers.Get(routerID)
+		if err != nil {
+			return err
+		}
+		if len(pool) == 0 {
+			pool = router.PrivateIpCidr
+		}
+		used, err = getRouterSubnetCidrs(router)
+		if err != nil {
+			return err
+		}
+	} else {
+		used, err = getUsedCidrs()
+		if err != nil {
+			return err
+		}
+	}
+
+	plan, err := planCidrs(pool, prefixLength, count, used)
+	if err != nil {
+		return err
+	}
+
+	if utils.NeedsFormatting(c) {
+		utils.FormatObject(plan, w, c)
+		return nil
+	}
+	if c.GlobalIsSet("non-interactive") {
+		for _, cidr := range plan.Proposed {
+			fmt.Fprintf(w, "%s\n", cidr)
+		}
+		return nil
+	}
+
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Ranges used in %s: %d\n", plan.Pool, len(plan.Used))
+	for _, u := range plan.Used {
+		fmt.Fprintf(tw, "  %s\t%s\n", u.Cidr, u.Owner)
+	}
+	fmt.Fprintf(tw, "\nProposed /%d ranges:\n", plan.PrefixLength)
+	for _, cidr := range plan.Proposed {
+		fmt.Fprintf(tw, "  %s\n", cidr)
+	}
+	return tw.Flush()
+}
+
+// Returns the first count free blocks of the pool with the given prefix length.
+func planCidrs(pool string, prefixLength, count int, used []usedCidr) (*networkPlan, error) {
+	poolNet, err := ipaddr.ParseCIDR(pool)
+	if err != nil {
+		return nil, err
+	}
+	plan := &networkPlan{Pool: poolNet.String(), PrefixLength: prefixLength, Used: []usedCidr{}}
+	poolRange := ipaddr.CIDRRange(poolNet)
+
+	var taken ipaddr.List
+	for _, u := range used {
+		_, network, err := net.ParseCIDR(u.Cidr)
+		if err != nil {
+			continue
+		}
+		r := ipaddr.CIDRRange(network)
+		if r.Overlaps(poolRange) {
+			plan.Used = append(plan.Used, u)
+			taken = append(taken, r)
+		}
+	}
+
+	blocks, err := ipaddr.FreeBlocks(poolNet, prefixLength, count, taken)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		plan.Proposed = append(plan.Proposed, block.String())
+	}
+	return plan, nil
+}
+
+// Returns the private IP ranges of all the routers and subnets.
+func getUsedCidrs() ([]usedCidr, error) {
+	var used []usedCidr
+	seenSubnets := map[string]bool{}
+
+	tenants, err := client.Photonclient.Tenants.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, tenant := range tenants.Items {
+		projects, err := client.Photonclient.Tenants.GetProjects(tenant.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects.Items {
+			routers, err := client.Photonclient.Projects.GetRouters(project.ID, nil)
+			if err != nil {
+				return nil, err
+			}
+			for i := range routers.Items {
+				router := &routers.Items[i]
+				used = append(used, usedCidr{
+					Cidr:  router.PrivateIpCidr,
+					Owner: fmt.Sprintf("router '%s' of project '%s/%s'", router.Name, tenant.Name, project.Name),
+				})
+				subnets, err := client.Photonclient.Routers.GetSubnets(router.ID, nil)
+				if err != nil {
+					return nil, err
+				}
+				for _, subnet := range subnets.Items {
+					seenSubnets[subnet.ID] = true
+					used = append(used, subnetCidr(subnet, router))
+				}
+			}
+		}
+	}
+
+	subnets, err := client.Photonclient.Subnets.GetAll(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, subnet := range subnets.Items {
+		if !seenSubnets[subnet.ID] {
+			used = append(used, usedCidr{Cidr: subnet.PrivateIpCidr, Owner: fmt.Sprintf("subnet '%s'", subnet.Name)})
+		}
+	}
+	return used, nil
+}
+
+// Returns the private IP ranges of the subnets of a router.
+func getRouterSubnetCidrs(router *photon.Router) ([]usedCidr, error) {
+	subnets, err := client.Photonclient.Routers.GetSubnets(router.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	var used []usedCidr
+	for _, subnet := range subnets.Items {
+		used = append(used, subnetCidr(subnet, router))
+	}
+	return used, nil
+}
+
+func subnetCidr(subnet photon.Subnet, router *photon.Router) usedCidr {
+	return usedCidr{
+		Cidr:  subnet.PrivateIpCidr,
+		Owner: fmt.Sprintf("subnet '%s' of router '%s'", subnet.Name, router.Name),
+	}
+}
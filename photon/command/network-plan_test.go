@@ -0,0 +1,112 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestPlanNetworkAndAutoCidr(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.0.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateRouter(projectID, &photon.RouterCreateSpec{Name: "router1", PrivateIpCidr: "10.2.0.0/16"})
+	waitForEntity(t, task, err)
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	set.String("pool", "10.0.0.0/8", "pool")
+	set.Int("prefix-length", 16, "prefix length")
+	set.Int("count", 2, "count")
+	set.String("router", "", "router")
+	var output bytes.Buffer
+	err = planNetwork(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error planning network: " + err.Error())
+	}
+	if output.String() != "10.1.0.0/16\n10.3.0.0/16\n" {
+		t.Errorf("Unexpected proposed ranges:\n%s", output.String())
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("name", "router2", "name")
+	set.String("privateIpCidr", "", "cidr")
+	set.Bool("auto-cidr", true, "auto")
+	set.String("cidr-pool", "10.0.0.0/8", "pool")
+	set.Int("prefix-length", 16, "prefix length")
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", "project1", "project")
+	err = createRouter(cli.NewContext(nil, set, globalCtx), ioutil.Discard)
+	if err != nil {
+		t.Fatal("Not expecting error creating router: " + err.Error())
+	}
+	routers, err := api.Projects.GetRouters(projectID, &photon.RouterGetOptions{Name: "router2"})
+	if err != nil || len(routers.Items) != 1 || routers.Items[0].PrivateIpCidr != "10.1.0.0/16" {
+		t.Fatalf("Expected router2 to use 10.1.0.0/16, got %+v (%v)", routers, err)
+	}
+	routerID := routers.Items[0].ID
+
+	for _, expected := range []string{"10.1.0.0/24", "10.1.1.0/24"} {
+		set = flag.NewFlagSet("test", 0)
+		set.String("name", "subnet-"+expected, "name")
+		set.String("description", "test subnet", "description")
+		set.String("privateIpCidr", "", "cidr")
+		set.Bool("auto-cidr", true, "auto")
+		set.Int("prefix-length", 24, "prefix length")
+		set.String("router", routerID, "router")
+		err = createSubnet(cli.NewContext(nil, set, globalCtx), ioutil.Discard)
+		if err != nil {
+			t.Fatal("Not expecting error creating subnet: " + err.Error())
+		}
+		subnets, err := api.Routers.GetSubnets(routerID, &photon.SubnetGetOptions{Name: "subnet-" + expected})
+		if err != nil || len(subnets.Items) != 1 || subnets.Items[0].PrivateIpCidr != expected {
+			t.Errorf("Expected subnet to use %s, got %+v (%v)", expected, subnets, err)
+		}
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	set.String("pool", "", "pool")
+	set.Int("prefix-length", 24, "prefix length")
+	set.Int("count", 1, "count")
+	set.String("router", routerID, "router")
+	output.Reset()
+	err = planNetwork(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error planning router subnets: " + err.Error())
+	}
+	if output.String() != "10.1.2.0/24\n" {
+		t.Errorf("Unexpected proposed subnet range:\n%s", output.String())
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Tenants, projects, routers, subnets and VMs, as shown by network topology.
+type topologyTenant struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Projects []topologyProject `json:"projects"`
+}
+
+type topologyProject struct {
+	ID      string           `json:"id"`
+	Name    string           `json:"name"`
+	Routers []topologyRouter `json:"routers"`
+
+	// VMs with no connection to a subnet of the project's routers.
+	VMs []topologyVM `json:"vms"`
+}
+
+type topologyRouter struct {
+	ID            string           `json:"id"`
+	Name          string           `json:"name"`
+	PrivateIpCidr string           `json:"privateIpCidr"`
+	Subnets       []topologySubnet `json:"subnets"`
+}
+
+type topologySubnet struct {
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	PrivateIpCidr string       `json:"privateIpCidr"`
+	IsDefault     bool         `json:"isDefault"`
+	VMs           []topologyVM `json:"vms"`
+}
+
+// A VM appears under each subnet it is connected to, with its address on that subnet.
+type topologyVM struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	State      string `json:"state"`
+	IpAddress  string `json:"ipAddress,omitempty"`
+	FloatingIp string `json:"floatingIp,omitempty"`
+}
+
+// Shows the routers, subnets and VMs of projects as a tree, a Graphviz graph or JSON
+func showNetworkTopology(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	tenantName := c.String("tenant")
+	projectName := c.String("project")
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	var tenants []photon.Tenant
+	if len(tenantName) != 0 || len(projectName) != 0 {
+		tenant, err := verifyTenant(tenantName)
+		if err != nil {
+			return err
+		}
+		tenants = append(tenants, photon.Tenant{ID: tenant.ID, Name: tenant.Name})
+	} else {
+		tenantList, err := client.Photonclient.Tenants.GetAll()
+		if err != nil {
+			return err
+		}
+		tenants = tenantList.Items
+	}
+
+	topology := []topologyTenant{}
+	for _, tenant := range tenants {
+		t := topologyTenant{ID: tenant.ID, Name: tenant.Name, Projects: []topologyProject{}}
+		var projects []photon.ProjectCompact
+		if len(projectName) != 0 {
+			project, err := verifyProject(tenant.ID, projectName)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, photon.ProjectCompact{ID: project.ID, Name: project.Name})
+		} else {
+			projectList, err := client.Photonclient.Tenants.GetProjects(tenant.ID, nil)
+			if err != nil {
+				return err
+			}
+			projects = projectList.Items
+		}
+		for _, project := range projects {
+			p, err := getProjectTopology(project.ID, project.Name)
+			if err != nil {
+				return err
+			}
+			t.Projects = append(t.Projects, *p)
+		}
+		topology = append(topology, t)
+	}
+
+	if c.GlobalString("output") == "dot" {
+		return printTopologyDot(topology, w)
+	}
+	if utils.NeedsFormatting(c) {
+		utils.FormatObject(topology, w, c)
+		return nil
+	}
+	if c.GlobalIsSet("non-interactive") {
+		printTopologyRows(topology, w)
+		return nil
+	}
+	printTopologyTree(topology, w)
+	return nil
+}
+
+// Collects the routers, subnets and VMs of a project, placing each VM under the
+// subnets it is connected to.
+func getProjectTopology(id, name string) (*topologyProject, error) {
+	project := &topologyProject{ID: id, Name: name, Routers: []topologyRouter{}, VMs: []topologyVM{}}
+	subnets := map[string]*topologySubnet{}
+
+	routers, err := client.Photonclient.Projects.GetRouters(id, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, router := range routers.Items {
+		r := topologyRouter{ID: router.ID, Name: router.Name, PrivateIpCidr: router.PrivateIpCidr}
+		subnetList, err := client.Photonclient.Routers.GetSubnets(router.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, subnet := range subnetList.Items {
+			r.Subnets = append(r.Subnets, topologySubnet{
+				ID:            subnet.ID,
+				Name:          subnet.Name,
+				PrivateIpCidr: subnet.PrivateIpCidr,
+				IsDefault:     subnet.IsDefault,
+				VMs:           []topologyVM{},
+			})
+		}
+		project.Routers = append(project.Routers, r)
+	}
+	// Pointers are taken once the slices stop growing.
+	for i := range project.Routers {
+		for j := range project.Routers[i].Subnets {
+			subnet := &project.Routers[i].Subnets[j]
+			subnets[subnet.ID] = subnet
+		}
+	}
+
+	vms, err := client.Photonclient.Projects.GetVMs(id, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range vms.Items {
+		connections, err := fetchVMNetworks(vm.ID, false)
+		if err != nil {
+			return nil, err
+		}
+		attached := false
+		for _, connection := range connections {
+			subnet := findConnectionSubnet(connection, subnets)
+			if subnet == nil {
+				continue
+			}
+			subnet.VMs = append(subnet.VMs, topologyVM{
+				ID:         vm.ID,
+				Name:       vm.Name,
+				State:      vm.State,
+				IpAddress:  connection.IpAddress,
+				FloatingIp: vm.FloatingIp,
+			})
+			attached = true
+		}
+		if !attached {
+			project.VMs = append(project.VMs, topologyVM{
+				ID:         vm.ID,
+				Name:       vm.Name,
+				State:      vm.State,
+				FloatingIp: vm.FloatingIp,
+			})
+		}
+	}
+	return project, nil
+}
+
+// Returns the subnet a connection reports, or the subnet whose range holds its address.
+func findConnectionSubnet(connection VMNetwork, subnets map[string]*topologySubnet) *topologySubnet {
+	if subnet, ok := subnets[connection.Network]; ok {
+		return subnet
+	}
+	ip, err := ipaddr.ParseIP(connection.IpAddress)
+	if err != nil {
+		return nil
+	}
+	for _, subnet := range subnets {
+		network, err := ipaddr.ParseCIDR(subnet.PrivateIpCidr)
+		if err == nil && network.Contains(ip) {
+			return subnet
+		}
+	}
+	return nil
+}
+
+func printTopologyTree(topology []topologyTenant, w io.Writer) {
+	for _, t := range topology {
+		fmt.Fprintf(w, "Tenant %s (%s)\n", t.Name, t.ID)
+		for _, p := range t.Projects {
+			fmt.Fprintf(w, "  Project %s (%s)\n", p.Name, p.ID)
+			for _, r := range p.Routers {
+				fmt.Fprintf(w, "    Router %s (%s) %s\n", r.Name, r.ID, r.PrivateIpCidr)
+				for _, s := range r.Subnets {
+					defaultFlag := ""
+					if s.IsDefault {
+						defaultFlag = " [default]"
+					}
+					fmt.Fprintf(w, "      Subnet %s (%s) %s%s\n", s.Name, s.ID, s.PrivateIpCidr, defaultFlag)
+					for _, vm := range s.VMs {
+						fmt.Fprintf(w, "        VM %s\n", formatTopologyVM(vm))
+					}
+				}
+			}
+			if len(p.VMs) != 0 {
+				fmt.Fprintf(w, "    Not connected to a subnet\n")
+				for _, vm := range p.VMs {
+					fmt.Fprintf(w, "      VM %s\n", formatTopologyVM(vm))
+				}
+			}
+		}
+	}
+}
+
+func formatTopologyVM(vm topologyVM) string {
+	s := fmt.Sprintf("%s (%s) %s", vm.Name, vm.ID, vm.State)
+	if len(vm.IpAddress) != 0 {
+		s += " ip " + vm.IpAddress
+	}
+	if len(vm.FloatingIp) != 0 {
+		s += " floating " + vm.FloatingIp
+	}
+	return s
+}
+
+// Prints one line per entity with the ID of its parent, for scripts.
+func printTopologyRows(topology []topologyTenant, w io.Writer) {
+	for _, t := range topology {
+		fmt.Fprintf(w, "tenant\t%s\t%s\t-\n", t.ID, t.Name)
+		for _, p := range t.Projects {
+			fmt.Fprintf(w, "project\t%s\t%s\t%s\n", p.ID, p.Name, t.ID)
+			for _, r := range p.Routers {
+				fmt.Fprintf(w, "router\t%s\t%s\t%s\t%s\n", r.ID, r.Name, p.ID, r.PrivateIpCidr)
+				for _, s := range r.Subnets {
+					fmt.Fprintf(w, "subnet\t%s\t%s\t%s\t%s\t%t\n", s.ID, s.Name, r.ID, s.PrivateIpCidr, s.IsDefault)
+					for _, vm := range s.VMs {
+						fmt.Fprintf(w, "vm\t%s\t%s\t%s\t%s\t%s\n", vm.ID, vm.Name, s.ID,
+							dashIfEmpty(vm.IpAddress), dashIfEmpty(vm.FloatingIp))
+					}
+				}
+			}
+			for _, vm := range p.VMs {
+				fmt.Fprintf(w, "vm\t%s\t%s\t%s\t-\t%s\n", vm.ID, vm.Name, p.ID, dashIfEmpty(vm.FloatingIp))
+			}
+		}
+	}
+}
+
+func dashIfEmpty(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
+
+// Prints the topology as a Graphviz digraph, e.g. for 'photon -o dot network topology | dot -Tsvg'.
+// VMs connected to several subnets are a single node with an edge from each subnet.
+func printTopologyDot(topology []topologyTenant, w io.Writer) error {
+	node := func(id, shape string, label ...string) {
+		fmt.Fprintf(w, "  %q [shape=%s, label=%q];\n", id, shape, strings.Join(label, "\n"))
+	}
+	edge := func(from, to, label string) {
+		if len(label) == 0 {
+			fmt.Fprintf(w, "  %q -> %q;\n", from, to)
+		} else {
+			fmt.Fprintf(w, "  %q -> %q [label=%q];\n", from, to, label)
+		}
+	}
+	vmNode := func(vm topologyVM) {
+		label := []string{"vm " + vm.Name, vm.State}
+		if len(vm.FloatingIp) != 0 {
+			label = append(label, "floating "+vm.FloatingIp)
+		}
+		node(vm.ID, "ellipse", label...)
+	}
+
+	fmt.Fprintf(w, "digraph topology {\n")
+	fmt.Fprintf(w, "  rankdir=LR;\n")
+	seen := map[string]bool{}
+	for _, t := range topology {
+		node(t.ID, "folder", "tenant "+t.Name)
+		for _, p := range t.Projects {
+			node(p.ID, "folder", "project "+p.Name)
+			edge(t.ID, p.ID, "")
+			for _, r := range p.Routers {
+				node(r.ID, "box", "router "+r.Name, r.PrivateIpCidr)
+				edge(p.ID, r.ID, "")
+				for _, s := range r.Subnets {
+					label := []string{"subnet " + s.Name, s.PrivateIpCidr}
+					if s.IsDefault {
+						label = append(label, "default")
+					}
+					node(s.ID, "box", label...)
+					edge(r.ID, s.ID, "")
+					for _, vm := range s.VMs {
+						if !seen[vm.ID] {
+							vmNode(vm)
+							seen[vm.ID] = true
+						}
+						edge(s.ID, vm.ID, vm.IpAddress)
+					}
+				}
+			}
+			for _, vm := range p.VMs {
+				vmNode(vm)
+				edge(p.ID, vm.ID, "")
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
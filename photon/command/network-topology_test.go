@@ -0,0 +1,126 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestShowNetworkTopology(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm1", Flavor: "small", SourceImageID: imageID})
+	vm1ID := waitForEntity(t, task, err)
+	task, err = api.VMs.Start(vm1ID)
+	waitForEntity(t, task, err)
+	task, err = api.VMs.AcquireFloatingIp(vm1ID, &photon.VmFloatingIpSpec{})
+	waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm2", Flavor: "small", SourceImageID: imageID})
+	waitForEntity(t, task, err)
+	vm1, err := api.VMs.Get(vm1ID)
+	if err != nil || len(vm1.FloatingIp) == 0 {
+		t.Fatalf("Expected vm1 to have a floating IP, got %+v (%v)", vm1, err)
+	}
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.String("output", "json", "output")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", "", "project")
+	var output bytes.Buffer
+	err = showNetworkTopology(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error showing topology: " + err.Error())
+	}
+
+	var topology []topologyTenant
+	err = json.Unmarshal(output.Bytes(), &topology)
+	if err != nil {
+		t.Fatal("Not expecting error decoding topology: " + err.Error())
+	}
+	if len(topology) != 1 || len(topology[0].Projects) != 1 || len(topology[0].Projects[0].Routers) != 1 {
+		t.Fatalf("Expected one tenant, project and router, got %+v", topology)
+	}
+	router := topology[0].Projects[0].Routers[0]
+	if router.PrivateIpCidr != "10.1.0.0/16" || len(router.Subnets) != 1 || !router.Subnets[0].IsDefault {
+		t.Fatalf("Expected the default router and subnet, got %+v", router)
+	}
+	subnet := router.Subnets[0]
+	if len(subnet.VMs) != 2 {
+		t.Fatalf("Expected both VMs on the default subnet, got %+v", subnet.VMs)
+	}
+	var vm topologyVM
+	for _, v := range subnet.VMs {
+		if v.ID == vm1ID {
+			vm = v
+		} else if len(v.IpAddress) != 0 {
+			t.Errorf("Expected the stopped VM to have no address, got %+v", v)
+		}
+	}
+	network, _ := ipaddr.ParseCIDR(subnet.PrivateIpCidr)
+	ip, err := ipaddr.ParseIP(vm.IpAddress)
+	if err != nil || !network.Contains(ip) || vm.FloatingIp != vm1.FloatingIp {
+		t.Errorf("Expected vm1 to have an address in %s and floating IP %s, got %+v",
+			subnet.PrivateIpCidr, vm1.FloatingIp, vm)
+	}
+
+	globalSet = flag.NewFlagSet("test", 0)
+	globalSet.String("output", "dot", "output")
+	globalCtx = cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--output=dot"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	output.Reset()
+	err = showNetworkTopology(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error showing topology: " + err.Error())
+	}
+	dot := output.String()
+	expected := []string{
+		"digraph topology {",
+		fmt.Sprintf("%q -> %q;", tenantID, projectID),
+		fmt.Sprintf("%q -> %q [label=%q];", subnet.ID, vm1ID, vm.IpAddress),
+	}
+	for _, e := range expected {
+		if !strings.Contains(dot, e) {
+			t.Errorf("Expected graph to contain '%s', got:\n%s", e, dot)
+		}
+	}
+}
@@ -11,6 +11,8 @@ package command
 
 import (
 	"errors"
+	"log"
+	"os"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
 
@@ -23,6 +25,84 @@ const (
 	NOT_AVAILABLE    = "NOT_AVAILABLE"
 )
 
+// Creates a cli.Command for network
+// Subcommands: plan;     Usage: network plan [<options>]
+//              topology; Usage: network topology [<options>]
+func GetNetworksCommand() cli.Command {
+	command := cli.Command{
+		Name:  "network",
+		Usage: "options for network",
+		Subcommands: []cli.Command{
+			{
+				Name:      "plan",
+				Usage:     "Propose free private IP ranges for new routers or subnets",
+				ArgsUsage: " ",
+				Description: "Collect the private IP ranges of all routers and subnets and propose the next\n" +
+					"   blocks of the pool that none of them use. With --router, the pool defaults to the\n" +
+					"   range of the router and only its subnets are taken into account.\n\n" +
+					"   Example: \n" +
+					"   photon network plan --pool 10.0.0.0/8 --prefix-length 16 --count 2 \n" +
+					"   photon network plan --router 4f9caq234 --prefix-length 24 \n",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "pool",
+						Usage: "Root CIDR to propose blocks from, e.g.: 10.0.0.0/8",
+					},
+					cli.IntFlag{
+						Name:  "prefix-length",
+						Value: defaultPlanPrefixLength,
+						Usage: "Prefix length of the proposed blocks",
+					},
+					cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "Number of blocks to propose",
+					},
+					cli.StringFlag{
+						Name:  "router",
+						Usage: "ID of the router to propose subnet ranges for",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := planNetwork(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "topology",
+				Usage:     "Show the routers, subnets and VMs of projects",
+				ArgsUsage: " ",
+				Description: "Show a tree of tenants, projects, routers and subnets, with the VMs connected to\n" +
+					"   each subnet and their IP and floating IP addresses. All tenants are shown unless\n" +
+					"   --tenant or --project is given. Use the global '-o dot' option for a Graphviz graph\n" +
+					"   or '-o json' for JSON.\n\n" +
+					"   Example: \n" +
+					"   photon network topology -t cloud-dev -p cloud-dev-staging \n" +
+					"   photon -o dot network topology | dot -Tsvg > topology.svg \n",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "tenant, t",
+						Usage: "Tenant name",
+					},
+					cli.StringFlag{
+						Name:  "project, p",
+						Usage: "Project name",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := showNetworkTopology(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+		},
+	}
+	return command
+}
+
 func isSoftwareDefinedNetwork(c *cli.Context) (sdnEnabled bool, err error) {
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
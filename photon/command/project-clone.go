@@ -0,0 +1,664 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Creates a cli.Command for project clone
+// Usage: project clone <project-id> --name <name> [<options>]
+func getProjectCloneCommand() cli.Command {
+	cloneCommand := cli.Command{
+		Name:      "clone",
+		Usage:     "Create a new project that mirrors an existing one",
+		ArgsUsage: "<project-id>",
+		Description: "Create a new project with the same quota limits, security groups, IAM policy and\n" +
+			"   router/subnet layout as an existing project. Only system administrators can clone projects.\n\n" +
+			"   Router and subnet CIDRs are remapped as follows:\n" +
+			"     - a CIDR listed in --cidr-map is replaced by its mapping\n" +
+			"     - otherwise, if --cidr-pool is given, each router gets a free block of the same size from\n" +
+			"       the pool and its subnets keep their offset inside the new router range\n" +
+			"     - otherwise the original CIDR is kept\n\n" +
+			"   VMs and persistent disks are only recreated when --with-vms or --with-disks is given.\n" +
+			"   Recreated VMs are left stopped and are attached to the default subnet.\n\n" +
+			"   Example:\n" +
+			"      photon project clone 3f78619d-20b1-4b86-a7a6-5a9f09e59ef6 --name project2 \\\n" +
+			"             --cidr-map '192.168.0.0/16=10.1.0.0/16' --with-vms --with-disks\n" +
+			"      photon project clone 3f78619d-20b1-4b86-a7a6-5a9f09e59ef6 --name project3 \\\n" +
+			"             --tenant tenant2 --cidr-pool 10.0.0.0/8",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "name, n",
+				Usage: "Name of the new project",
+			},
+			cli.StringFlag{
+				Name:  "tenant, t",
+				Usage: "Tenant name for the new project",
+			},
+			cli.StringFlag{
+				Name:  "cidr-map, m",
+				Usage: "Comma separated list of <old-cidr>=<new-cidr> mappings",
+			},
+			cli.StringFlag{
+				Name:  "cidr-pool, p",
+				Usage: "CIDR from which router ranges that are not mapped are allocated",
+			},
+			cli.BoolFlag{
+				Name:  "with-vms",
+				Usage: "Recreate the VMs of the source project",
+			},
+			cli.BoolFlag{
+				Name:  "with-disks",
+				Usage: "Recreate the persistent disks of the source project",
+			},
+		},
+		Action: func(c *cli.Context) {
+			err := cloneProject(c, os.Stdout)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+		},
+	}
+	return cloneCommand
+}
+
+// Clones the project with the specified id into a new project.
+// Returns an error if one occurred
+func cloneProject(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	sourceID := c.Args().First()
+	name := c.String("name")
+	tenantName := c.String("tenant")
+
+	cidrs, err := newCidrMapper(c.String("cidr-map"), c.String("cidr-pool"))
+	if err != nil {
+		return err
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		name, err = askForInput("New project name: ", name)
+		if err != nil {
+			return err
+		}
+	}
+	if len(name) == 0 {
+		return fmt.Errorf("Please provide the name of the new project")
+	}
+
+	tenant, err := verifyTenant(tenantName)
+	if err != nil {
+		return err
+	}
+
+	source, err := client.Photonclient.Projects.Get(sourceID)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Printf("\nCloning project '%s' into project '%s' of tenant '%s'\n", source.Name, name, tenant.Name)
+	}
+	if !confirmed(c) {
+		fmt.Println("OK. Canceled")
+		return nil
+	}
+
+	cloner := &projectCloner{
+		src:       client.Photonclient,
+		dst:       client.Photonclient,
+		cidrs:     cidrs,
+		withVMs:   c.Bool("with-vms"),
+		withDisks: c.Bool("with-disks"),
+	}
+	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
+		cloner.progress = os.Stdout
+	}
+
+	id, err := cloner.clone(source, tenant.ID, name)
+	if err != nil {
+		if len(id) == 0 {
+			return err
+		}
+		if c.GlobalIsSet("non-interactive") {
+			cloner.printSkipped(os.Stderr)
+		} else {
+			cloner.printSkipped(os.Stdout)
+		}
+		return fmt.Errorf("project '%s' (%s) was created but cloning failed: %s", name, id, err)
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		fmt.Println(id)
+		cloner.printSkipped(os.Stderr)
+	} else if utils.NeedsFormatting(c) {
+		project, err := client.Photonclient.Projects.Get(id)
+		if err != nil {
+			return err
+		}
+		utils.FormatObject(project, w, c)
+	} else {
+		fmt.Printf("Project '%s' cloned to '%s' with ID %s\n", source.Name, name, id)
+		cloner.printSkipped(os.Stdout)
+	}
+
+	return nil
+}
+
+// projectCloner recreates the settings of a source project in a new project.
+// The source and destination clients may point to different deployments.
+type projectCloner struct {
+	src       *photon.Client
+	dst       *photon.Client
+	cidrs     *cidrMapper
+	withVMs   bool
+	withDisks bool
+	// Report running VMs instead of recreating them.
+	skipRunningVMs bool
+
+	// Resolves a source flavor name and kind to the name used on the destination.
+	// When nil flavors are used as they are.
+	mapFlavor func(name, kind string) (string, error)
+	// Resolves a source image ID to the image ID used on the destination.
+	// When nil images are used as they are.
+	mapImage func(id string) (string, error)
+
+	// Receives one line per object created, may be nil.
+	progress io.Writer
+	// Things that could not be cloned, reported to the user at the end.
+	skipped []string
+}
+
+// Creates a project named name in the destination tenant that mirrors source.
+// Returns the ID of the new project.
+func (pc *projectCloner) clone(source *photon.ProjectCompact, tenantID, name string) (string, error) {
+	quota, err := pc.src.Projects.GetQuota(source.ID)
+	if err != nil {
+		return "", err
+	}
+	policy, err := pc.src.Projects.GetIam(source.ID)
+	if err != nil {
+		return "", err
+	}
+	routers, err := pc.src.Projects.GetRouters(source.ID, nil)
+	if err != nil {
+		return "", err
+	}
+
+	spec := photon.ProjectCreateSpec{Name: name}
+	for _, s := range source.SecurityGroups {
+		if !s.Inherited {
+			spec.SecurityGroups = append(spec.SecurityGroups, s.Name)
+		}
+	}
+	if quota != nil {
+		spec.ResourceQuota.QuotaLineItems = photon.QuotaSpec{}
+		for key, item := range quota.QuotaLineItems {
+			spec.ResourceQuota.QuotaLineItems[key] = photon.QuotaStatusLineItem{Unit: item.Unit, Limit: item.Limit}
+		}
+	}
+
+	// The default router is created together with the project, so its CIDR
+	// has to be known up front.
+	routerCidrs := map[string]string{}
+	for _, r := range routers.Items {
+		cidr, err := pc.cidrs.mapRouter(r.PrivateIpCidr)
+		if err != nil {
+			return "", fmt.Errorf("router '%s': %s", r.Name, err)
+		}
+		routerCidrs[r.ID] = cidr
+		if r.IsDefault {
+			spec.DefaultRouterPrivateIpCidr = cidr
+		}
+	}
+
+	task, err := pc.dst.Tenants.CreateProject(tenantID, &spec)
+	if err != nil {
+		return "", err
+	}
+	projectID, err := pc.wait(task)
+	if err != nil {
+		return "", err
+	}
+	pc.logf("Created project '%s' (%s)\n", name, projectID)
+
+	if policy != nil && len(*policy) != 0 {
+		task, err = pc.dst.Projects.SetIam(projectID, policy)
+		if err != nil {
+			return projectID, err
+		}
+		_, err = pc.wait(task)
+		if err != nil {
+			return projectID, err
+		}
+		pc.logf("Copied IAM policy (%d entries)\n", len(*policy))
+	}
+
+	err = pc.cloneRouters(routers.Items, routerCidrs, projectID)
+	if err != nil {
+		return projectID, err
+	}
+
+	var diskIDs map[string]string
+	if pc.withDisks {
+		diskIDs, err = pc.cloneDisks(source.ID, projectID)
+		if err != nil {
+			return projectID, err
+		}
+	}
+	if pc.withVMs {
+		err = pc.cloneVMs(source.ID, projectID, diskIDs)
+		if err != nil {
+			return projectID, err
+		}
+	}
+
+	return projectID, nil
+}
+
+// Recreates routers and their subnets in the project with the given ID.
+func (pc *projectCloner) cloneRouters(routers []photon.Router, routerCidrs map[string]string, projectID string) error {
+	created, err := pc.dst.Projects.GetRouters(projectID, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range routers {
+		var newRouterID string
+		if r.IsDefault {
+			for _, candidate := range created.Items {
+				if candidate.IsDefault {
+					newRouterID = candidate.ID
+				}
+			}
+			if len(newRouterID) == 0 {
+				pc.skip("default router '%s': the new project has no default router", r.Name)
+				continue
+			}
+		} else {
+			spec := &photon.RouterCreateSpec{Name: r.Name, PrivateIpCidr: routerCidrs[r.ID]}
+			task, err := pc.dst.Projects.CreateRouter(projectID, spec)
+			if err != nil {
+				return err
+			}
+			newRouterID, err = pc.wait(task)
+			if err != nil {
+				return err
+			}
+			pc.logf("Created router '%s' (%s)\n", r.Name, spec.PrivateIpCidr)
+		}
+
+		err = pc.cloneSubnets(r, routerCidrs[r.ID], newRouterID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recreates the subnets of a source router on the new router.
+func (pc *projectCloner) cloneSubnets(router photon.Router, routerCidr, newRouterID string) error {
+	subnets, err := pc.src.Routers.GetSubnets(router.ID, nil)
+	if err != nil {
+		return err
+	}
+	existing, err := pc.dst.Routers.GetSubnets(newRouterID, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subnets.Items {
+		var newSubnetID string
+		for _, e := range existing.Items {
+			if e.Name == s.Name {
+				newSubnetID = e.ID
+			}
+		}
+
+		if len(newSubnetID) == 0 {
+			cidr, err := pc.cidrs.mapSubnet(s.PrivateIpCidr, router.PrivateIpCidr, routerCidr)
+			if err != nil {
+				return fmt.Errorf("subnet '%s': %s", s.Name, err)
+			}
+			spec := &photon.SubnetCreateSpec{
+				Name:               s.Name,
+				Description:        s.Description,
+				PrivateIpCidr:      cidr,
+				Type:               "NAT",
+				DnsServerAddresses: s.DnsServerAddresses,
+			}
+			task, err := pc.dst.Routers.CreateSubnet(newRouterID, spec)
+			if err != nil {
+				return err
+			}
+			newSubnetID, err = pc.wait(task)
+			if err != nil {
+				return err
+			}
+			pc.logf("Created subnet '%s' (%s) on router '%s'\n", s.Name, cidr, router.Name)
+		}
+
+		if s.IsDefault {
+			task, err := pc.dst.Subnets.SetDefault(newSubnetID)
+			if err != nil {
+				return err
+			}
+			_, err = pc.wait(task)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Recreates the persistent disks of the source project.
+// Returns a map from source disk ID to new disk ID.
+func (pc *projectCloner) cloneDisks(sourceID, projectID string) (map[string]string, error) {
+	disks, err := pc.src.Projects.GetDisks(sourceID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, d := range disks.Items {
+		flavor, err := pc.flavor(d.Flavor, d.Kind)
+		if err != nil {
+			pc.skip("disk '%s': %s", d.Name, err)
+			continue
+		}
+		spec := &photon.DiskCreateSpec{
+			Name:       d.Name,
+			Flavor:     flavor,
+			Kind:       d.Kind,
+			CapacityGB: d.CapacityGB,
+			Tags:       d.Tags,
+		}
+		task, err := pc.dst.Projects.CreateDisk(projectID, spec)
+		if err != nil {
+			return nil, err
+		}
+		ids[d.ID], err = pc.wait(task)
+		if err != nil {
+			return nil, err
+		}
+		pc.logf("Created disk '%s'\n", d.Name)
+	}
+	return ids, nil
+}
+
+// Recreates the VMs of the source project. Persistent disks that were cloned are
+// attached to the new VMs the same way they were attached to the source VMs.
+func (pc *projectCloner) cloneVMs(sourceID, projectID string, diskIDs map[string]string) error {
+	vms, err := pc.src.Projects.GetVMs(sourceID, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vms.Items {
+		if pc.skipRunningVMs && vm.State == "STARTED" {
+			pc.skip("VM '%s' is running, stop it before migrating it", vm.Name)
+			continue
+		}
+		spec, err := pc.vmCreateSpec(vm)
+		if err != nil {
+			pc.skip("VM '%s': %s", vm.Name, err)
+			continue
+		}
+		task, err := pc.dst.Projects.CreateVM(projectID, spec)
+		if err != nil {
+			return err
+		}
+		vmID, err := pc.wait(task)
+		if err != nil {
+			return err
+		}
+		pc.logf("Created VM '%s' (%s)\n", vm.Name, vmID)
+
+		if len(vm.Metadata) != 0 {
+			task, err = pc.dst.VMs.SetMetadata(vmID, &photon.VmMetadata{Metadata: vm.Metadata})
+			if err != nil {
+				return err
+			}
+			_, err = pc.wait(task)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, d := range vm.AttachedDisks {
+			if d.Kind != "persistent-disk" {
+				continue
+			}
+			newDiskID, ok := diskIDs[d.ID]
+			if !ok {
+				pc.skip("VM '%s': persistent disk '%s' was not cloned", vm.Name, d.Name)
+				continue
+			}
+			task, err = pc.dst.VMs.AttachDisk(vmID, &photon.VmDiskOperation{DiskID: newDiskID})
+			if err != nil {
+				return err
+			}
+			_, err = pc.wait(task)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, iso := range vm.AttachedISOs {
+			pc.skip("VM '%s': attached ISO '%s'", vm.Name, iso.Name)
+		}
+		if len(vm.FloatingIp) != 0 {
+			pc.skip("VM '%s': floating IP %s", vm.Name, vm.FloatingIp)
+		}
+	}
+	return nil
+}
+
+// Builds the create spec for a copy of vm.
+func (pc *projectCloner) vmCreateSpec(vm photon.VM) (*photon.VmCreateSpec, error) {
+	flavor, err := pc.flavor(vm.Flavor, "vm")
+	if err != nil {
+		return nil, err
+	}
+	image := vm.SourceImageID
+	if pc.mapImage != nil {
+		image, err = pc.mapImage(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spec := &photon.VmCreateSpec{
+		Name:          vm.Name,
+		Flavor:        flavor,
+		SourceImageID: image,
+		Tags:          vm.Tags,
+	}
+	for _, d := range vm.AttachedDisks {
+		if d.Kind != "ephemeral-disk" {
+			continue
+		}
+		diskFlavor, err := pc.flavor(d.Flavor, d.Kind)
+		if err != nil {
+			return nil, err
+		}
+		spec.AttachedDisks = append(spec.AttachedDisks, photon.AttachedDisk{
+			Name:       d.Name,
+			Kind:       d.Kind,
+			Flavor:     diskFlavor,
+			CapacityGB: d.CapacityGB,
+			BootDisk:   d.BootDisk,
+		})
+	}
+	return spec, nil
+}
+
+func (pc *projectCloner) flavor(name, kind string) (string, error) {
+	if pc.mapFlavor == nil {
+		return name, nil
+	}
+	return pc.mapFlavor(name, kind)
+}
+
+// Waits for a task on the destination and returns the ID of its entity.
+func (pc *projectCloner) wait(task *photon.Task) (string, error) {
+	task, err := pc.dst.Tasks.Wait(task.ID)
+	if err != nil {
+		return "", err
+	}
+	return task.Entity.ID, nil
+}
+
+func (pc *projectCloner) logf(format string, args ...interface{}) {
+	if pc.progress != nil {
+		fmt.Fprintf(pc.progress, format, args...)
+	}
+}
+
+func (pc *projectCloner) skip(format string, args ...interface{}) {
+	pc.skipped = append(pc.skipped, fmt.Sprintf(format, args...))
+}
+
+func (pc *projectCloner) printSkipped(w io.Writer) {
+	if len(pc.skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nThe following were not cloned:\n")
+	for _, s := range pc.skipped {
+		fmt.Fprintf(w, "  %s\n", s)
+	}
+}
+
+// cidrMapper decides the CIDR of each cloned router and subnet.
+type cidrMapper struct {
+	mapping   map[string]string
+	pool      *net.IPNet
+	allocated ipaddr.List
+}
+
+// Creates a cidrMapper from a comma separated list of <old>=<new> mappings
+// and an optional pool to allocate unmapped router ranges from.
+func newCidrMapper(mapping, pool string) (*cidrMapper, error) {
+	m := &cidrMapper{mapping: map[string]string{}}
+
+	if len(strings.TrimSpace(mapping)) != 0 {
+		for _, entry := range regexp.MustCompile(`\s*,\s*`).Split(strings.TrimSpace(mapping), -1) {
+			parts := strings.Split(entry, "=")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid CIDR mapping '%s', expected <old-cidr>=<new-cidr>", entry)
+			}
+			oldCidr, newCidr := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			for _, cidr := range []string{oldCidr, newCidr} {
+				if _, err := ipaddr.ParseCIDR(cidr); err != nil {
+					return nil, fmt.Errorf("Invalid mapping '%s': %s", entry, err)
+				}
+			}
+			m.mapping[oldCidr] = newCidr
+		}
+	}
+
+	if len(pool) != 0 {
+		ipNet, err := ipaddr.ParseCIDR(pool)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR pool: %s", err)
+		}
+		m.pool = ipNet
+		for _, newCidr := range m.mapping {
+			ipNet, _ := ipaddr.ParseCIDR(newCidr)
+			m.allocated = append(m.allocated, ipaddr.CIDRRange(ipNet))
+		}
+	}
+	return m, nil
+}
+
+// Returns the CIDR for the copy of a router that uses cidr.
+func (m *cidrMapper) mapRouter(cidr string) (string, error) {
+	if mapped, ok := m.mapping[cidr]; ok {
+		return mapped, nil
+	}
+	if m.pool == nil {
+		return cidr, nil
+	}
+	ipNet, err := ipaddr.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, _ := ipNet.Mask.Size()
+	return m.allocate(ones)
+}
+
+// Returns the CIDR for the copy of a subnet that uses cidr inside a router whose
+// range moved from oldRouterCidr to newRouterCidr. The subnet keeps its offset
+// inside the router range.
+func (m *cidrMapper) mapSubnet(cidr, oldRouterCidr, newRouterCidr string) (string, error) {
+	if mapped, ok := m.mapping[cidr]; ok {
+		return mapped, nil
+	}
+	if oldRouterCidr == newRouterCidr {
+		return cidr, nil
+	}
+
+	subnetNet, err := ipaddr.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	oldNet, err := ipaddr.ParseCIDR(oldRouterCidr)
+	if err != nil {
+		return "", err
+	}
+	newNet, err := ipaddr.ParseCIDR(newRouterCidr)
+	if err != nil {
+		return "", err
+	}
+	if !oldNet.Contains(subnetNet.IP) {
+		return "", fmt.Errorf("%s is not inside the router range %s, please map it with --cidr-map", cidr, oldRouterCidr)
+	}
+
+	ip := ipaddr.Add(newNet.IP, ipaddr.Distance(oldNet.IP, subnetNet.IP))
+	ones, _ := subnetNet.Mask.Size()
+	if ip == nil || len(ip) != len(subnetNet.IP) ||
+		!newNet.Contains(ip) || !newNet.Contains(ipaddr.Last(&net.IPNet{IP: ip, Mask: subnetNet.Mask})) {
+		return "", fmt.Errorf("%s does not fit in the new router range %s, please map it with --cidr-map", cidr, newRouterCidr)
+	}
+	return fmt.Sprintf("%s/%d", ip, ones), nil
+}
+
+// Returns the first block with the given prefix length in the pool that does not
+// overlap an already allocated block.
+func (m *cidrMapper) allocate(ones int) (string, error) {
+	blocks, err := ipaddr.FreeBlocks(m.pool, ones, 1, m.allocated)
+	if err != nil {
+		return "", err
+	}
+	m.allocated = append(m.allocated, ipaddr.CIDRRange(blocks[0]))
+	return blocks[0].String(), nil
+}
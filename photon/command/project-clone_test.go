@@ -0,0 +1,351 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Registers a POST responder that starts a task, and a GET responder that reports it completed.
+// Returns the bodies of the POST requests received.
+func registerCompletedTask(t *testing.T, base string, url string, operation string, taskID string, entityID string) *[]string {
+	queued, err := json.Marshal(photon.Task{ID: taskID, Operation: operation, State: "QUEUED"})
+	if err != nil {
+		t.Error("Not expecting error serializing queued task")
+	}
+	completed, err := json.Marshal(photon.Task{ID: taskID, Operation: operation, State: "COMPLETED",
+		Entity: photon.Entity{ID: entityID}})
+	if err != nil {
+		t.Error("Not expecting error serializing completed task")
+	}
+	bodies := []string{}
+	respond := mocks.CreateResponder(200, string(queued))
+	mocks.RegisterResponder("POST", url, func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Error("Not expecting error reading request body")
+			}
+			bodies = append(bodies, string(body))
+		}
+		return respond(req)
+	})
+	mocks.RegisterResponder("GET", base+"/tasks/"+taskID, mocks.CreateResponder(200, string(completed)))
+	return &bodies
+}
+
+func registerJSONResponder(t *testing.T, url string, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		t.Error("Not expecting error serializing response")
+	}
+	mocks.RegisterResponder("GET", url, mocks.CreateResponder(200, string(response)))
+}
+
+func TestCloneProject(t *testing.T) {
+	server = mocks.NewTestServer()
+	defer server.Close()
+	base := server.URL + rootUrl
+
+	registerJSONResponder(t, base+"/tenants", photon.Tenants{
+		Items: []photon.Tenant{{Name: "fake_tenant_name", ID: "fake_tenant_ID"}},
+	})
+	registerJSONResponder(t, base+"/projects/src_project_ID", photon.ProjectCompact{
+		Name: "src_project",
+		ID:   "src_project_ID",
+		SecurityGroups: []photon.SecurityGroup{
+			{Name: "tenant\\group", Inherited: true},
+			{Name: "project\\group", Inherited: false},
+		},
+	})
+	registerJSONResponder(t, base+"/projects/src_project_ID/quota", photon.Quota{
+		QuotaLineItems: photon.QuotaSpec{"vm.count": {Limit: 10, Usage: 3, Unit: "COUNT"}},
+	})
+	registerJSONResponder(t, base+"/projects/src_project_ID/iam", []photon.PolicyEntry{
+		{Principal: "user@photon.local", Roles: []string{"owner"}},
+	})
+	registerJSONResponder(t, base+"/projects/src_project_ID/routers", photon.Routers{
+		Items: []photon.Router{
+			{ID: "src_router_default", Name: "default", PrivateIpCidr: "192.168.0.0/16", IsDefault: true},
+			{ID: "src_router_2", Name: "router2", PrivateIpCidr: "172.16.0.0/16"},
+		},
+	})
+	registerJSONResponder(t, base+"/routers/src_router_default/subnets", photon.Subnets{
+		Items: []photon.Subnet{{ID: "src_subnet", Name: "subnet1", PrivateIpCidr: "192.168.4.0/24", IsDefault: true}},
+	})
+	registerJSONResponder(t, base+"/routers/src_router_2/subnets", photon.Subnets{})
+	registerJSONResponder(t, base+"/projects/new_project_ID/routers", photon.Routers{
+		Items: []photon.Router{{ID: "new_router_default", Name: "default", PrivateIpCidr: "10.1.0.0/16", IsDefault: true}},
+	})
+	registerJSONResponder(t, base+"/routers/new_router_default/subnets", photon.Subnets{})
+	registerJSONResponder(t, base+"/routers/new_router_2/subnets", photon.Subnets{})
+
+	projectBodies := registerCompletedTask(t, base, base+"/tenants/fake_tenant_ID/projects", "CREATE_PROJECT", "project-task", "new_project_ID")
+	iamBodies := registerCompletedTask(t, base, base+"/projects/new_project_ID/iam", "SET_IAM_POLICY", "iam-task", "new_project_ID")
+	routerBodies := registerCompletedTask(t, base, base+"/projects/new_project_ID/routers", "CREATE_ROUTER", "router-task", "new_router_2")
+	subnetBodies := registerCompletedTask(t, base, base+"/routers/new_router_default/subnets", "CREATE_SUBNET", "subnet-task", "new_subnet")
+	defaultBodies := registerCompletedTask(t, base, base+"/subnets/new_subnet/set_default", "SET_DEFAULT_SUBNET", "default-task", "new_subnet")
+
+	mocks.Activate(true)
+	httpClient := &http.Client{Transport: mocks.DefaultMockTransport}
+	client.Photonclient = photon.NewTestClient(server.URL, nil, httpClient)
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "new_project", "name")
+	set.String("tenant", "fake_tenant_name", "tenant name")
+	set.String("cidr-map", "192.168.0.0/16=10.1.0.0/16", "cidr map")
+	set.String("cidr-pool", "172.20.0.0/14", "cidr pool")
+	set.Bool("with-vms", false, "vms")
+	set.Bool("with-disks", false, "disks")
+	err = set.Parse([]string{"src_project_ID"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	err = cloneProject(cxt, os.Stdout)
+	if err != nil {
+		t.Fatal("Not expecting error cloning project: " + err.Error())
+	}
+
+	var project photon.ProjectCreateSpec
+	if len(*projectBodies) != 1 || json.Unmarshal([]byte((*projectBodies)[0]), &project) != nil {
+		t.Fatalf("Expected one project to be created, got %v", *projectBodies)
+	}
+	if project.Name != "new_project" || project.DefaultRouterPrivateIpCidr != "10.1.0.0/16" {
+		t.Errorf("Unexpected project spec: %+v", project)
+	}
+	if len(project.SecurityGroups) != 1 || project.SecurityGroups[0] != "project\\group" {
+		t.Errorf("Expected only the project security group to be cloned, got %v", project.SecurityGroups)
+	}
+	if item := project.ResourceQuota.QuotaLineItems["vm.count"]; item.Limit != 10 || item.Usage != 0 {
+		t.Errorf("Expected the quota limit without usage, got %+v", item)
+	}
+
+	var policy []photon.PolicyEntry
+	if len(*iamBodies) != 1 || json.Unmarshal([]byte((*iamBodies)[0]), &policy) != nil ||
+		len(policy) != 1 || policy[0].Principal != "user@photon.local" {
+		t.Errorf("Expected the IAM policy to be copied, got %v", *iamBodies)
+	}
+
+	var router photon.RouterCreateSpec
+	if len(*routerBodies) != 1 || json.Unmarshal([]byte((*routerBodies)[0]), &router) != nil {
+		t.Fatalf("Expected one router to be created, got %v", *routerBodies)
+	}
+	if router.Name != "router2" || router.PrivateIpCidr != "172.20.0.0/16" {
+		t.Errorf("Unexpected router spec: %+v", router)
+	}
+
+	var subnet photon.SubnetCreateSpec
+	if len(*subnetBodies) != 1 || json.Unmarshal([]byte((*subnetBodies)[0]), &subnet) != nil {
+		t.Fatalf("Expected one subnet to be created, got %v", *subnetBodies)
+	}
+	if subnet.Name != "subnet1" || subnet.PrivateIpCidr != "10.1.4.0/24" {
+		t.Errorf("Unexpected subnet spec: %+v", subnet)
+	}
+	if len(*defaultBodies) != 1 {
+		t.Errorf("Expected the cloned subnet to be made the default, got %v", *defaultBodies)
+	}
+}
+
+func TestCloneProjectPartialFailure(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "src_project",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateRouter(projectID, &photon.RouterCreateSpec{Name: "router2", PrivateIpCidr: "172.16.0.0/16"})
+	waitForEntity(t, task, err)
+
+	sim.InjectFault(simulator.Fault{
+		Method:   "POST",
+		Path:     "/projects/*/routers",
+		Error:    photon.ApiError{Code: "NetworkError", Message: "no free range"},
+		FailTask: true,
+	})
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "new_project", "name")
+	set.String("tenant", "tenant1", "tenant name")
+	set.String("cidr-map", "10.1.0.0/16=10.2.0.0/16", "cidr map")
+	set.String("cidr-pool", "172.20.0.0/14", "cidr pool")
+	set.Bool("with-vms", false, "vms")
+	set.Bool("with-disks", false, "disks")
+	err = set.Parse([]string{projectID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	err = cloneProject(cxt, os.Stdout)
+	if err == nil {
+		t.Fatal("Expected cloning to fail when a router cannot be created")
+	}
+
+	projects, e := api.Tenants.GetProjects(tenantID, &photon.ProjectGetOptions{Name: "new_project"})
+	if e != nil || len(projects.Items) != 1 {
+		t.Fatalf("Expected the partially cloned project, got %+v (%v)", projects, e)
+	}
+	expected := fmt.Sprintf("project 'new_project' (%s) was created but cloning failed", projects.Items[0].ID)
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected the error to report the partial project, got: %s", err)
+	}
+}
+
+func TestCloneProjectWithVMs(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "src_project",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateRouter(projectID, &photon.RouterCreateSpec{Name: "router2", PrivateIpCidr: "172.16.0.0/16"})
+	waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm1", Flavor: "small", SourceImageID: imageID})
+	waitForEntity(t, task, err)
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "new_project", "name")
+	set.String("tenant", "tenant1", "tenant name")
+	set.String("cidr-map", "10.1.0.0/16=10.2.0.0/16", "cidr map")
+	set.String("cidr-pool", "172.20.0.0/14", "cidr pool")
+	set.Bool("with-vms", true, "vms")
+	set.Bool("with-disks", false, "disks")
+	err = set.Parse([]string{projectID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	err = cloneProject(cxt, os.Stdout)
+	if err != nil {
+		t.Fatal("Not expecting error cloning project: " + err.Error())
+	}
+
+	projects, err := api.Tenants.GetProjects(tenantID, &photon.ProjectGetOptions{Name: "new_project"})
+	if err != nil || len(projects.Items) != 1 {
+		t.Fatalf("Expected the cloned project, got %+v (%v)", projects, err)
+	}
+	newProjectID := projects.Items[0].ID
+	routers, err := api.Projects.GetRouters(newProjectID, nil)
+	if err != nil || len(routers.Items) != 2 {
+		t.Fatalf("Expected two routers in the cloned project, got %+v (%v)", routers, err)
+	}
+	if routers.Items[0].PrivateIpCidr != "10.2.0.0/16" || routers.Items[1].PrivateIpCidr != "172.20.0.0/16" {
+		t.Errorf("Unexpected cloned router ranges: %+v", routers.Items)
+	}
+	vms, err := api.Projects.GetVMs(newProjectID, nil)
+	if err != nil || len(vms.Items) != 1 || vms.Items[0].Name != "vm1" || vms.Items[0].SourceImageID != imageID {
+		t.Errorf("Expected the VM to be cloned, got %+v (%v)", vms, err)
+	}
+}
+
+func waitForEntity(t *testing.T, task *photon.Task, err error) string {
+	if err != nil {
+		t.Fatal("Not expecting error starting task: " + err.Error())
+	}
+	task, err = client.Photonclient.Tasks.Wait(task.ID)
+	if err != nil {
+		t.Fatal("Not expecting error waiting for task: " + err.Error())
+	}
+	return task.Entity.ID
+}
+
+func TestCidrMapper(t *testing.T) {
+	m, err := newCidrMapper("192.168.0.0/16 = 10.1.0.0/16", "172.16.0.0/12")
+	if err != nil {
+		t.Fatal("Not expecting error creating cidr mapper: " + err.Error())
+	}
+
+	cidr, err := m.mapRouter("192.168.0.0/16")
+	if err != nil || cidr != "10.1.0.0/16" {
+		t.Errorf("Expected mapped router cidr 10.1.0.0/16, got %s (%v)", cidr, err)
+	}
+	cidr, err = m.mapSubnet("192.168.4.0/24", "192.168.0.0/16", "10.1.0.0/16")
+	if err != nil || cidr != "10.1.4.0/24" {
+		t.Errorf("Expected subnet to keep its offset, got %s (%v)", cidr, err)
+	}
+
+	first, err := m.mapRouter("192.168.0.0/24")
+	if err != nil || first != "172.16.0.0/24" {
+		t.Errorf("Expected first pool allocation 172.16.0.0/24, got %s (%v)", first, err)
+	}
+	second, err := m.mapRouter("192.168.0.0/16")
+	if err != nil {
+		t.Error("Not expecting error allocating from pool: " + err.Error())
+	}
+	if second != "10.1.0.0/16" {
+		t.Errorf("Expected explicit mapping to win over the pool, got %s", second)
+	}
+	third, err := m.mapRouter("10.0.0.0/16")
+	if err != nil || third != "172.17.0.0/16" {
+		t.Errorf("Expected allocation to skip used ranges, got %s (%v)", third, err)
+	}
+
+	_, err = m.mapSubnet("192.168.4.0/24", "192.168.0.0/16", "172.16.0.0/24")
+	if err == nil {
+		t.Error("Expected an error when the subnet does not fit in the new router range")
+	}
+
+	_, err = newCidrMapper("192.168.0.0/16", "")
+	if err == nil {
+		t.Error("Expected an error for a mapping without a new cidr")
+	}
+}
@@ -35,6 +35,7 @@ import (
 //              list;   Usage: project list [<options>]
 //              tasks;  Usage: project tasks <id> [<options>]
 //              quota;  Usage: project quota <operation> <name> [<options>]
+//              clone;  Usage: project clone <id> --name <name> [<options>]
 func GetProjectsCommand() cli.Command {
 	command := cli.Command{
 		Name:  "project",
@@ -76,7 +77,9 @@ func GetProjectsCommand() cli.Command {
 					"                    storage.VSAN 1000 COUNT,\n" +
 					"                    sdn.floatingip.size 1000 COUNT'\n\n" +
 					"      Set project quota to 30% of its tenant quota:\n" +
-					"        photon project create project2 --tenant tenant1 --percent 30",
+					"        photon project create project2 --tenant tenant1 --percent 30\n\n" +
+					"      Create a project from a spec file, the name given as argument overrides the one of the file:\n" +
+					"        photon project create --tenant tenant1 --spec-file project3.yaml",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "limits, l",
@@ -98,6 +101,7 @@ func GetProjectsCommand() cli.Command {
 						Name:  "default-router-private-ip-cidr, c",
 						Usage: "Private IP range of the default router in CIDR format. Default value: 192.168.0.0/16",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createProject(c, os.Stdout)
@@ -292,6 +296,8 @@ func GetProjectsCommand() cli.Command {
 			},
 			// Load Project Quota related logic from separated file.
 			getProjectQuotaCommand(),
+			// Load Project clone related logic from separated file.
+			getProjectCloneCommand(),
 		},
 	}
 	return command
@@ -300,20 +306,33 @@ func GetProjectsCommand() cli.Command {
 // Sends a create project task to client based on the cli.Context
 // Returns an error if one occurred
 func createProject(c *cli.Context, w io.Writer) error {
-	err := checkArgCount(c, 1)
+	spec := photon.ProjectCreateSpec{}
+	err := readSpecFileFlag(c, &spec)
 	if err != nil {
 		return err
 	}
-	name := c.Args().First()
+	// The name may be left out when the spec file gives it
+	if len(spec.Name) == 0 || len(c.Args()) != 0 {
+		err = checkArgCount(c, 1)
+		if err != nil {
+			return err
+		}
+	}
+	name := stringOrSpec(c.Args().First(), spec.Name)
 	tenantName := c.String("tenant")
 	limits := c.String("limits")
 	percent := c.Float64("percent") / 100.0
-	securityGroups := c.String("security-groups")
-	defaultRouterPrivateIpCidr := c.String("default-router-private-ip-cidr")
+	securityGroups := stringOrSpec(c.String("security-groups"), strings.Join(spec.SecurityGroups, ","))
+	defaultRouterPrivateIpCidr := stringOrSpec(c.String("default-router-private-ip-cidr"),
+		spec.DefaultRouterPrivateIpCidr)
 
 	if len(defaultRouterPrivateIpCidr) == 0 {
 		defaultRouterPrivateIpCidr = "192.168.0.0/16"
 	}
+	err = checkCidrFromFlag("default-router-private-ip-cidr", defaultRouterPrivateIpCidr)
+	if err != nil {
+		return err
+	}
 
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
@@ -350,9 +369,11 @@ func createProject(c *cli.Context, w io.Writer) error {
 			return err
 		}
 
-		limitsList, err = askForLimitList(limitsList)
-		if err != nil {
-			return err
+		if len(spec.ResourceQuota.QuotaLineItems) == 0 {
+			limitsList, err = askForLimitList(limitsList)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -392,6 +413,8 @@ func createProject(c *cli.Context, w io.Writer) error {
 		} else if limitsList != nil {
 			quotaSpec := convertQuotaSpecFromQuotaLineItems(limitsList)
 			projectQuota.QuotaLineItems = quotaSpec
+		} else {
+			projectQuota = spec.ResourceQuota
 		}
 
 		projectSpec.ResourceQuota = projectQuota
@@ -42,7 +42,8 @@ func GetRoutersCommand() cli.Command {
 					"   The private IP range of router will be sub-divided into smaller CIDRs for each subnet \n" +
 					"   created under this router \n\n" +
 					"   Example: \n" +
-					"   photon router create -n router-1 -i 192.168.0.0/16 -t cloud-dev -p cloud-dev-staging \n",
+					"   photon router create -n router-1 -i 192.168.0.0/16 -t cloud-dev -p cloud-dev-staging \n" +
+					"   photon router create -n router-2 --auto-cidr -t cloud-dev -p cloud-dev-staging \n",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -52,6 +53,20 @@ func GetRoutersCommand() cli.Command {
 						Name:  "privateIpCidr, i",
 						Usage: "The private IP range of router in CIDR format, e.g.: 192.168.0.0/16",
 					},
+					cli.BoolFlag{
+						Name:  "auto-cidr",
+						Usage: "Use the first range of --cidr-pool that no router or subnet uses",
+					},
+					cli.StringFlag{
+						Name:  "cidr-pool",
+						Value: defaultRouterCidrPool,
+						Usage: "Pool of private IP ranges to pick from with --auto-cidr",
+					},
+					cli.IntFlag{
+						Name:  "prefix-length",
+						Value: defaultRouterPrefixLength,
+						Usage: "Prefix length of the range picked with --auto-cidr",
+					},
 					cli.StringFlag{
 						Name:  "tenant, t",
 						Usage: "Tenant name",
@@ -159,14 +174,48 @@ func createRouter(c *cli.Context, w io.Writer) error {
 
 	name := c.String("name")
 	privateIpCidr := c.String("privateIpCidr")
+	autoCidr := c.Bool("auto-cidr")
 	tenantName := c.String("tenant")
 	projectName := c.String("project")
 
+	if autoCidr {
+		if len(privateIpCidr) != 0 {
+			return fmt.Errorf("Please provide either privateIpCidr or --auto-cidr")
+		}
+		err = checkCidrFromFlag("cidr-pool", c.String("cidr-pool"))
+		if err != nil {
+			return err
+		}
+	}
+
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
 	}
 
+	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
+		name, err = askForInput("Router name: ", name)
+		if err != nil {
+			return err
+		}
+		if !autoCidr {
+			privateIpCidr, err = askForInput("Router privateIpCidr: ", privateIpCidr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(name) == 0 || (len(privateIpCidr) == 0 && !autoCidr) {
+		return fmt.Errorf("Please provide name and privateIpCidr")
+	}
+	if !autoCidr {
+		err = checkCidrFromFlag("privateIpCidr", privateIpCidr)
+		if err != nil {
+			return err
+		}
+	}
+
 	tenant, err := verifyTenant(tenantName)
 	if err != nil {
 		return err
@@ -177,19 +226,16 @@ func createRouter(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
-	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
-		name, err = askForInput("Router name: ", name)
+	if autoCidr {
+		used, err := getUsedCidrs()
 		if err != nil {
 			return err
 		}
-		privateIpCidr, err = askForInput("Router privateIpCidr: ", privateIpCidr)
+		plan, err := planCidrs(c.String("cidr-pool"), c.Int("prefix-length"), 1, used)
 		if err != nil {
 			return err
 		}
-	}
-
-	if len(name) == 0 || len(privateIpCidr) == 0 {
-		return fmt.Errorf("Please provide name and privateIpCidr")
+		privateIpCidr = plan.Proposed[0]
 	}
 
 	routerSpec := photon.RouterCreateSpec{}
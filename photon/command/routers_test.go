@@ -110,7 +110,7 @@ func TestCreateDeleteRouter(t *testing.T) {
 	}
 	set := flag.NewFlagSet("test", 0)
 	set.String("name", "fake_router_name", "Router name")
-	set.String("privateIpCidr", "fake_router_privateIpCidr", "Router privateIpCidr")
+	set.String("privateIpCidr", "192.168.0.0/16", "Router privateIpCidr")
 	set.String("tenant", "fake_tenant_name", "tenant name")
 	set.String("project", "fake_project_name", "project name")
 	cxt := cli.NewContext(nil, set, globalCtx)
@@ -0,0 +1,207 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Roles of the VMs of a service, in the order nodes are listed
+var serviceNodeRoles = []string{"master", "etcd", "load-balancer", "worker"}
+
+// A VM of a service with the roles it has in the service.
+type serviceNode struct {
+	Roles     []string `json:"roles"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	State     string   `json:"state"`
+	Host      string   `json:"host"`
+	IPAddress string   `json:"ipAddress"`
+	Flavor    string   `json:"flavor"`
+}
+
+// Lists the VMs of a service with their roles, and reports when the number of
+// workers is not the worker count of the service.
+func listServiceNodes(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	service, err := client.Photonclient.Services.Get(id)
+	if err != nil {
+		return err
+	}
+	vms, err := client.Photonclient.Services.GetVMs(id)
+	if err != nil {
+		return err
+	}
+
+	nodes := []serviceNode{}
+	workers := 0
+	for _, vm := range vms.Items {
+		// VMs that are not started may not report their networks
+		networks, _ := fetchVMNetworks(vm.ID, false)
+		node := serviceNode{
+			Roles:     getServiceNodeRoles(service, vm, networks),
+			ID:        vm.ID,
+			Name:      vm.Name,
+			State:     vm.State,
+			Host:      vm.Host,
+			IPAddress: primaryIP(networks, ""),
+			Flavor:    vm.Flavor,
+		}
+		for _, role := range node.Roles {
+			if role == "worker" {
+				workers++
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		ri, rj := serviceNodeRoleOrder(nodes[i]), serviceNodeRoleOrder(nodes[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	var workerCountError string
+	if service.Type == "KUBERNETES" && workers != service.WorkerCount {
+		workerCountError = fmt.Sprintf("Service %s has %d workers, its worker count is %d",
+			service.ID, workers, service.WorkerCount)
+	}
+
+	if utils.NeedsFormatting(c) {
+		utils.FormatObjects(nodes, w, c)
+		if len(workerCountError) != 0 {
+			fmt.Fprintln(os.Stderr, "Warning: "+workerCountError)
+		}
+		return nil
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, node := range nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", strings.Join(node.Roles, ","), node.ID, node.Name,
+				node.State, node.Host, node.IPAddress, node.Flavor)
+		}
+		if len(workerCountError) != 0 {
+			fmt.Fprintln(os.Stderr, "Warning: "+workerCountError)
+		}
+		return nil
+	}
+
+	notStarted := 0
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Role\tVM ID\tName\tState\tHost\tIP\tFlavor\n")
+	for _, node := range nodes {
+		state := node.State
+		if state != "STARTED" {
+			state += " *"
+			notStarted++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", dashIfEmpty(strings.Join(node.Roles, ",")), node.ID,
+			node.Name, state, dashIfEmpty(node.Host), dashIfEmpty(node.IPAddress), node.Flavor)
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nTotal: %d nodes, %d workers\n", len(nodes), workers)
+	if notStarted != 0 {
+		fmt.Fprintf(w, "* %d nodes are not STARTED\n", notStarted)
+	}
+	if len(workerCountError) != 0 {
+		fmt.Fprintln(w, "Warning: "+workerCountError)
+	}
+	return nil
+}
+
+// Returns the roles of a VM of a service. The service tags its VMs with
+// service:<id>:<role>, and the IPs of the masters, etcd members and load balancer
+// are in its extended properties.
+func getServiceNodeRoles(service *photon.Service, vm photon.VM, networks []VMNetwork) []string {
+	roles := map[string]bool{}
+	prefix := "service:" + service.ID + ":"
+	for _, tag := range vm.Tags {
+		if strings.HasPrefix(tag, prefix) {
+			roles[strings.ToLower(strings.TrimPrefix(tag, prefix))] = true
+		}
+	}
+	if role, ok := vm.Metadata["role"]; ok {
+		roles[strings.ToLower(role)] = true
+	}
+
+	properties := service.ExtendedProperties
+	roleIPs := map[string][]string{
+		"master": {properties[photon.ExtendedPropertyMasterIP], properties[photon.ExtendedPropertyMasterIP2]},
+		"etcd": {properties[photon.ExtendedPropertyETCDIP1], properties[photon.ExtendedPropertyETCDIP2],
+			properties[photon.ExtendedPropertyETCDIP3]},
+		"load-balancer": {properties[photon.ExtendedPropertyLoadBalancerIP]},
+	}
+	if len(properties[photon.ExtendedPropertyMasterIPs]) != 0 {
+		roleIPs["master"] = append(roleIPs["master"], strings.Split(properties[photon.ExtendedPropertyMasterIPs], ",")...)
+	}
+	for role, ips := range roleIPs {
+		for _, ip := range ips {
+			ip = strings.TrimSpace(ip)
+			for _, network := range networks {
+				if len(ip) != 0 && network.IpAddress == ip {
+					roles[role] = true
+				}
+			}
+		}
+	}
+
+	result := []string{}
+	for _, role := range serviceNodeRoles {
+		if roles[role] {
+			result = append(result, role)
+			delete(roles, role)
+		}
+	}
+	// Roles of other kinds of services, such as the master of Harbor, come last
+	others := []string{}
+	for role := range roles {
+		others = append(others, role)
+	}
+	sort.Strings(others)
+	return append(result, others...)
+}
+
+func serviceNodeRoleOrder(node serviceNode) int {
+	if len(node.Roles) == 0 {
+		return len(serviceNodeRoles) + 1
+	}
+	for i, role := range serviceNodeRoles {
+		if node.Roles[0] == role {
+			return i
+		}
+	}
+	return len(serviceNodeRoles)
+}
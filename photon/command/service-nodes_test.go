@@ -0,0 +1,118 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func runServiceNodes(t *testing.T, id string, globalArgs []string) string {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", false, "doc")
+	globalSet.String("output", "", "output")
+	err := globalSet.Parse(globalArgs)
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{id})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = listServiceNodes(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	if err != nil {
+		t.Fatal("Not expecting error listing service nodes: " + err.Error())
+	}
+	return output.String()
+}
+
+func TestListServiceNodes(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	imageID := sim.AddImage("kubernetes", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{Name: "project1"})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateService(projectID, &photon.ServiceCreateSpec{
+		Name:        "k8s",
+		Type:        "KUBERNETES",
+		VMFlavor:    "small",
+		ImageID:     imageID,
+		WorkerCount: 3,
+		ExtendedProperties: map[string]string{
+			photon.ExtendedPropertyNumberOfETCDs:   "1",
+			photon.ExtendedPropertyNumberOfMasters: "1",
+		},
+	})
+	serviceID := waitForEntity(t, task, err)
+
+	// One worker is stopped and another one is gone
+	vms, err := api.Services.GetVMs(serviceID)
+	if err != nil {
+		t.Fatal("Not expecting error listing service VMs: " + err.Error())
+	}
+	var workerIDs []string
+	for _, vm := range vms.Items {
+		if strings.HasPrefix(vm.Name, "worker-") {
+			workerIDs = append(workerIDs, vm.ID)
+		}
+	}
+	if len(workerIDs) != 3 {
+		t.Fatalf("Expected 3 workers, got %+v", vms.Items)
+	}
+	task, err = api.VMs.Stop(workerIDs[1])
+	waitForEntity(t, task, err)
+	task, err = api.VMs.Stop(workerIDs[2])
+	waitForEntity(t, task, err)
+	task, err = api.VMs.Delete(workerIDs[2])
+	waitForEntity(t, task, err)
+
+	var nodes []serviceNode
+	output := runServiceNodes(t, serviceID, []string{"--output=json"})
+	err = json.Unmarshal([]byte(output), &nodes)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding nodes: %s\n%s", err, output)
+	}
+	roles := []string{}
+	for _, node := range nodes {
+		roles = append(roles, strings.Join(node.Roles, ","))
+		if (node.State == "STARTED" && len(node.IPAddress) == 0) || node.Flavor != "small" {
+			t.Errorf("Expected node %s to have an IP and the flavor of the service, got %+v", node.Name, node)
+		}
+	}
+	if strings.Join(roles, " ") != "master,load-balancer etcd worker worker" {
+		t.Errorf("Expected the masters, etcd members and workers in order, got %v", roles)
+	}
+
+	output = runServiceNodes(t, serviceID, nil)
+	for _, line := range []string{"Role  ", "STOPPED *", "Total: 4 nodes, 2 workers",
+		"* 1 nodes are not STARTED", "Warning: Service " + serviceID + " has 2 workers, its worker count is 3"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output to contain '%s', got:\n%s", line, output)
+		}
+	}
+}
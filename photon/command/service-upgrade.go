@@ -0,0 +1,220 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Delay between two polls of the upgrade status of a service
+var serviceUpgradePollDelay = 5 * time.Second
+
+// Width of the progress bar of service upgrade
+const upgradeBarWidth = 30
+
+// Result of a check made before upgrading a service. Error is empty when the
+// check passed.
+type upgradeCheck struct {
+	Description string
+	Error       string
+}
+
+// Checks that a service can be upgraded to an image, upgrades it and shows the
+// progress of the upgrade node by node until it ends. Returns an error when a
+// check fails or the upgrade fails.
+func upgradeService(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	serviceID := c.Args().First()
+	imageID := c.String("image")
+
+	if !c.GlobalIsSet("non-interactive") {
+		imageID, err = askForInput("New image ID for service: ", imageID)
+		if err != nil {
+			return err
+		}
+	}
+	if len(imageID) == 0 {
+		return fmt.Errorf("Please provide the image ID to upgrade the service to")
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	service, err := client.Photonclient.Services.Get(serviceID)
+	if err != nil {
+		return err
+	}
+	checks, err := checkServiceUpgrade(service, imageID)
+	if err != nil {
+		return err
+	}
+	interactive := !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c)
+	var failed []string
+	for _, check := range checks {
+		if len(check.Error) != 0 {
+			failed = append(failed, check.Error)
+		}
+		if interactive {
+			result := "OK"
+			if len(check.Error) != 0 {
+				result = "FAILED: " + check.Error
+			}
+			fmt.Fprintf(w, "  %-50s %s\n", check.Description, result)
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("Service %s cannot be upgraded: %s", serviceID, strings.Join(failed, "; "))
+	}
+
+	if !confirmed(c) {
+		fmt.Fprintln(w, "Cancelled")
+		return nil
+	}
+
+	task, err := client.Photonclient.Services.ChangeVersion(serviceID,
+		&photon.ServiceChangeVersionOperation{NewImageID: imageID})
+	if err != nil {
+		return err
+	}
+
+	// The service keeps upgrading its nodes after the task completes, until it
+	// leaves the UPGRADING state.
+	taskDone := false
+	for {
+		if !taskDone {
+			task, err = client.Photonclient.Tasks.Get(task.ID)
+			// A failed task is reported with the result of the upgrade below
+			if _, failed := err.(photon.TaskError); err != nil && !failed {
+				return err
+			}
+			taskDone = task.State == "COMPLETED" || task.State == "ERROR"
+		}
+		service, err = client.Photonclient.Services.Get(serviceID)
+		if err != nil {
+			return err
+		}
+		if interactive {
+			// Redraw the progress bar on the same line.
+			fmt.Fprintf(w, "\r%s\033[K", formatUpgradeProgress(service.UpgradeStatus))
+		}
+		if task.State == "ERROR" || service.State == "ERROR" ||
+			taskDone && strings.ToUpper(service.State) != "UPGRADING" {
+			break
+		}
+		time.Sleep(serviceUpgradePollDelay)
+	}
+	if interactive {
+		fmt.Fprintln(w, "")
+	}
+
+	result := ""
+	if service.UpgradeStatus != nil {
+		result = service.UpgradeStatus.UpgradeResultMessage
+	}
+	if task.State == "ERROR" || service.State == "ERROR" {
+		if len(result) == 0 {
+			result = fmt.Sprintf("task %s is %s and service is %s", task.ID, task.State, service.State)
+		}
+		return fmt.Errorf("Upgrade of service %s failed: %s", serviceID, result)
+	}
+
+	if utils.NeedsFormatting(c) {
+		utils.FormatObject(service, w, c)
+	} else if c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", service.ID, service.State, result)
+	} else {
+		fmt.Fprintf(w, "Service %s is %s: %s\n", service.ID, service.State, result)
+	}
+	return nil
+}
+
+// Checks that the image exists, is READY and is enabled for the type of the
+// service, that the service is READY and that all its nodes are STARTED.
+func checkServiceUpgrade(service *photon.Service, imageID string) ([]upgradeCheck, error) {
+	checks := []upgradeCheck{}
+
+	imageCheck := upgradeCheck{Description: fmt.Sprintf("Image %s is READY", imageID)}
+	image, err := client.Photonclient.Images.Get(imageID)
+	if err != nil {
+		imageCheck.Error = fmt.Sprintf("image %s cannot be found: %s", imageID, err)
+	} else if image.State != "READY" {
+		imageCheck.Error = fmt.Sprintf("image %s is %s", imageID, image.State)
+	}
+	checks = append(checks, imageCheck)
+
+	info, err := client.Photonclient.System.GetSystemInfo()
+	if err != nil {
+		return nil, err
+	}
+	configCheck := upgradeCheck{Description: fmt.Sprintf("Image is enabled for %s services", service.Type)}
+	enabled := false
+	for _, configuration := range info.ServiceConfigurations {
+		if strings.EqualFold(configuration.Type, service.Type) && configuration.ImageID == imageID {
+			enabled = true
+		}
+	}
+	if !enabled {
+		configCheck.Error = fmt.Sprintf("image %s is not enabled for %s services", imageID, service.Type)
+	}
+	checks = append(checks, configCheck)
+
+	stateCheck := upgradeCheck{Description: "Service is READY"}
+	if strings.ToUpper(service.State) != "READY" {
+		stateCheck.Error = fmt.Sprintf("service %s is %s", service.ID, service.State)
+	}
+	checks = append(checks, stateCheck)
+
+	vms, err := client.Photonclient.Services.GetVMs(service.ID)
+	if err != nil {
+		return nil, err
+	}
+	nodesCheck := upgradeCheck{Description: fmt.Sprintf("All %d nodes are STARTED", len(vms.Items))}
+	var notStarted []string
+	for _, vm := range vms.Items {
+		if vm.State != "STARTED" {
+			notStarted = append(notStarted, fmt.Sprintf("%s (%s)", vm.Name, vm.State))
+		}
+	}
+	if len(notStarted) != 0 {
+		nodesCheck.Error = "nodes are not STARTED: " + strings.Join(notStarted, ", ")
+	}
+	checks = append(checks, nodesCheck)
+
+	return checks, nil
+}
+
+// Formats the upgrade status of a service as
+// "[##########--------------------]  1/3 nodes  Upgrading service nodes".
+func formatUpgradeProgress(status *photon.ServiceUpgradeStatus) string {
+	if status == nil || status.TotalNodes == 0 {
+		return fmt.Sprintf("[%s]  waiting for the upgrade to start", strings.Repeat("-", upgradeBarWidth))
+	}
+	upgraded := status.NumNodesUpgraded
+	if upgraded > status.TotalNodes {
+		upgraded = status.TotalNodes
+	}
+	filled := upgraded * upgradeBarWidth / status.TotalNodes
+	return fmt.Sprintf("[%s%s] %2d/%d nodes  %s", strings.Repeat("#", filled),
+		strings.Repeat("-", upgradeBarWidth-filled), upgraded, status.TotalNodes, status.UpgradeMessage)
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func runServiceUpgrade(t *testing.T, id, imageID string) (string, error) {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("image", "", "image")
+	err = set.Parse([]string{"-image=" + imageID, id})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = upgradeService(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	return output.String(), err
+}
+
+func TestUpgradeService(t *testing.T) {
+	serviceUpgradePollDelay = 0
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	imageID := sim.AddImage("kubernetes-1.6", 1024)
+	newImageID := sim.AddImage("kubernetes-1.7", 1024)
+	task, err := api.System.EnableServiceType(&photon.ServiceConfigurationSpec{Type: "KUBERNETES", ImageID: newImageID})
+	waitForEntity(t, task, err)
+	task, err = api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{Name: "project1"})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateService(projectID, &photon.ServiceCreateSpec{
+		Name:        "k8s",
+		Type:        "KUBERNETES",
+		ImageID:     imageID,
+		WorkerCount: 2,
+	})
+	serviceID := waitForEntity(t, task, err)
+
+	// The image the service runs is not enabled for Kubernetes services
+	_, err = runServiceUpgrade(t, serviceID, imageID)
+	if err == nil || !strings.Contains(err.Error(), "is not enabled for KUBERNETES services") {
+		t.Errorf("Expected the upgrade to an image that is not enabled to fail, got %v", err)
+	}
+	_, err = runServiceUpgrade(t, serviceID, "missing-image")
+	if err == nil || !strings.Contains(err.Error(), "image missing-image cannot be found") {
+		t.Errorf("Expected the upgrade to a missing image to fail, got %v", err)
+	}
+
+	vms, err := api.Services.GetVMs(serviceID)
+	if err != nil || len(vms.Items) == 0 {
+		t.Fatalf("Expected the VMs of the service, got %v, %v", vms, err)
+	}
+	stopped := vms.Items[len(vms.Items)-1]
+	task, err = api.VMs.Stop(stopped.ID)
+	waitForEntity(t, task, err)
+	_, err = runServiceUpgrade(t, serviceID, newImageID)
+	if err == nil || !strings.Contains(err.Error(), "nodes are not STARTED: "+stopped.Name+" (STOPPED)") {
+		t.Errorf("Expected the upgrade with a stopped node to fail, got %v", err)
+	}
+	task, err = api.VMs.Start(stopped.ID)
+	waitForEntity(t, task, err)
+
+	output, err := runServiceUpgrade(t, serviceID, newImageID)
+	if err != nil {
+		t.Fatal("Not expecting error upgrading service: " + err.Error())
+	}
+	if output != serviceID+"\tREADY\tUpgrade completed\n" {
+		t.Errorf("Expected the result of the upgrade, got '%s'", output)
+	}
+	service, err := api.Services.Get(serviceID)
+	if err != nil || service.ImageID != newImageID || service.UpgradeStatus.NumNodesUpgraded != len(vms.Items) {
+		t.Errorf("Expected the service to be upgraded, got %+v, %v", service, err)
+	}
+
+	// A failed upgrade returns the result of the upgrade
+	sim.InjectFault(simulator.Fault{
+		Method:   "POST",
+		Path:     "/services/*/change_version",
+		Error:    photon.ApiError{Code: "UpgradeFailed", Message: "node worker-1 did not start"},
+		FailTask: true,
+		Times:    1,
+	})
+	_, err = runServiceUpgrade(t, serviceID, newImageID)
+	if err == nil || err.Error() != "Upgrade of service "+serviceID+" failed: node worker-1 did not start" {
+		t.Errorf("Expected the upgrade to fail with the result message, got %v", err)
+	}
+}
+
+func TestFormatUpgradeProgress(t *testing.T) {
+	progress := formatUpgradeProgress(&photon.ServiceUpgradeStatus{
+		UpgradeMessage:   "Upgrading service nodes",
+		TotalNodes:       3,
+		NumNodesUpgraded: 1,
+	})
+	expected := "[" + strings.Repeat("#", 10) + strings.Repeat("-", 20) + "]  1/3 nodes  Upgrading service nodes"
+	if progress != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, progress)
+	}
+	if !strings.Contains(formatUpgradeProgress(nil), "waiting for the upgrade to start") {
+		t.Errorf("Expected an empty progress bar before the upgrade starts")
+	}
+}
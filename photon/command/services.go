@@ -11,11 +11,12 @@ package command
 
 import (
 	"bufio"
+	"crypto/sha1"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
@@ -25,14 +26,17 @@ import (
 	"unicode"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
+	cf "github.com/vmware/photon-controller-cli/photon/configuration"
 	"github.com/vmware/photon-controller-cli/photon/utils"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon"
 
+	"encoding/base64"
 	"encoding/pem"
 	"github.com/vmware/photon-controller-go-sdk/photon/lightwave"
 	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
 )
 
 // Creates a cli.Command for services
@@ -40,10 +44,14 @@ import (
 //              show;                Usage: service show <id>
 //              list;                Usage: service list [<options>]
 //              list_vms;            Usage: service list_vms <id>
+//              nodes;               Usage: service nodes <id>
 //              resize;              Usage: service resize <id> <new worker count> [<options>]
 //              delete;              Usage: service delete <id>
 //              trigger-maintenance; Usage: service trigger-maintenance <id>
 //              cert-to-file;        Usage: service cert-to-file <id> <file_path>
+//              upgrade;             Usage: service upgrade <id> [<options>]
+//              get-kubectl-auth;    Usage: service get-kubectl-auth <id> [<options>]
+//              kubectl-credential;  Usage: service kubectl-credential <id> [<options>]
 
 func GetServiceCommand() cli.Command {
 	command := cli.Command{
@@ -59,7 +67,9 @@ func GetServiceCommand() cli.Command {
 					"   photon service create -n k8-service -k KUBERNETES --dns 10.0.0.1 \\ \n" +
 					"     --gateway 192.0.2.1 --netmask 255.255.255.0 --master-ip 192.0.2.20 \\ \n" +
 					"     --container-network 10.2.0.0/16 --etcd1 192.0.2.21 \\ \n" +
-					"     -c 1 -v cluster-vm -d small-disk --ssh-key ~/.ssh/id_dsa.pub",
+					"     -c 1 -v cluster-vm -d small-disk --ssh-key ~/.ssh/id_dsa.pub\n\n" +
+					"   The spec can also be given in a file, with the extended properties of the service:\n" +
+					"   photon service create -t tenant1 -p project1 --spec-file k8-service.yaml -c 3",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "tenant, t",
@@ -177,6 +187,7 @@ func GetServiceCommand() cli.Command {
 						Name:  "wait-for-ready",
 						Usage: "Wait synchronously for the service to become ready and expanded fully",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createService(c, os.Stdout)
@@ -254,6 +265,21 @@ func GetServiceCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "nodes",
+				Usage:     "List the VMs of a service with their roles",
+				ArgsUsage: "service-id",
+				Description: "List the VMs of a service with their role (master, etcd, load-balancer or worker), \n" +
+					"   state, host, IP and flavor. Nodes that are not STARTED are marked with *, and a warning \n" +
+					"   is printed when the number of workers is not the worker count of the service. \n\n" +
+					"   Example: photon service nodes 9b159e92-9495-49a4-af58-53ad4764f616",
+				Action: func(c *cli.Context) {
+					err := listServiceNodes(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:      "resize",
 				Usage:     "Resize a service",
@@ -344,6 +370,28 @@ func GetServiceCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "upgrade",
+				Usage:     "Upgrade the service to an image, showing the progress of its nodes",
+				ArgsUsage: "service-id",
+				Description: "Checks that the image is READY and enabled for the type of the service, that \n" +
+					"   the service is READY and that all its nodes are STARTED, then upgrades the service \n" +
+					"   and shows the progress of the upgrade node by node until it ends. \n\n" +
+					"   Example: photon service upgrade 9b159e92-9495-49a4-af58-53ad4764f616 " +
+					"-i 2aeaf034-3b02-4873-a6fc-f92615dca849",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "image, i",
+						Usage: "ID of the image to upgrade the service to",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := upgradeService(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:      "get-kubectl-auth",
 				Usage:     "Generate the kubectl command for authentication",
@@ -354,7 +402,14 @@ func GetServiceCommand() cli.Command {
 					"9b159e92-9495-49a4-af58-53ad4764f616 \n" +
 					"To directly set the user, cluster and context for kubectl config \n" +
 					"Please run: eval \"$(photon service get-kubectl-auth -u admin -p password " +
-					"9b159e92-9495-49a4-af58-53ad4764f616)\"",
+					"9b159e92-9495-49a4-af58-53ad4764f616)\" \n" +
+					"To write the user, cluster and context into $KUBECONFIG or ~/.kube/config instead \n" +
+					"Please run: photon service get-kubectl-auth -u admin -p password --write-kubeconfig " +
+					"9b159e92-9495-49a4-af58-53ad4764f616 \n" +
+					"When writing a kubeconfig, the CA of the cluster is read from --cluster-ca, or else fetched \n" +
+					"from its load balancer once its fingerprint is confirmed, so that kubectl verifies it. In \n" +
+					"non-interactive mode, --cluster-ca or --insecure-skip-tls-verify is required. The printed \n" +
+					"commands skip the verification of its certificate.",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "username, u",
@@ -364,9 +419,55 @@ func GetServiceCommand() cli.Command {
 						Name:  "password, p",
 						Usage: "Password used for Photon Controller login",
 					},
+					cli.BoolFlag{
+						Name:  "write-kubeconfig, w",
+						Usage: "Write the user, cluster and context into $KUBECONFIG or ~/.kube/config",
+					},
+					cli.StringFlag{
+						Name:  "kubeconfig",
+						Usage: "Kubeconfig file to write the user, cluster and context into",
+					},
+					cli.StringFlag{
+						Name:  "cluster-ca",
+						Usage: "PEM file with the CA of the cluster, instead of fetching it from the cluster",
+					},
+					cli.BoolFlag{
+						Name:  "insecure-skip-tls-verify",
+						Usage: "Do not fetch the CA of the cluster and skip the verification of its certificate",
+					},
+					cli.BoolFlag{
+						Name:  "exec-credential",
+						Usage: "Cache the tokens and make kubectl get them from 'photon service kubectl-credential', which refreshes them",
+					},
 				},
 				Action: func(c *cli.Context) {
-					err := getKubectlAuth(c)
+					err := getKubectlAuth(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "kubectl-credential",
+				Usage:     "Print the credential of a user for kubectl",
+				ArgsUsage: "service-id",
+				Description: "Print the id token of a user for a Kubernetes service as a client-go ExecCredential, \n" +
+					"for kubectl to run as exec credential plugin. The tokens are cached and refreshed when \n" +
+					"they expire, with the refresh token or else with the password of the user. \n" +
+					"To configure kubectl to use it, please run: photon service get-kubectl-auth -u admin " +
+					"--write-kubeconfig --exec-credential 9b159e92-9495-49a4-af58-53ad4764f616",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "username, u",
+						Usage: "Username used for Photon Controller login, when the cached tokens expired",
+					},
+					cli.StringFlag{
+						Name:  "password, p",
+						Usage: "Password used for Photon Controller login, when the cached tokens expired",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := getKubectlCredential(c, os.Stdout)
 					if err != nil {
 						log.Fatal("Error: ", err)
 					}
@@ -389,31 +490,39 @@ func createService(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
+	spec := photon.ServiceCreateSpec{}
+	err = readSpecFileFlag(c, &spec)
+	if err != nil {
+		return err
+	}
+	properties := spec.ExtendedProperties
+
 	tenantName := c.String("tenant")
 	projectName := c.String("project")
-	name := c.String("name")
-	service_type := c.String("type")
-	vm_flavor := c.String("vm_flavor")
-	master_vm_flavor := c.String("master-vm-flavor")
-	worker_vm_flavor := c.String("worker-vm-flavor")
-	disk_flavor := c.String("disk_flavor")
-	subnet_id := c.String("subnet_id")
-	image_id := c.String("image-id")
-	worker_count := c.Int("worker_count")
-	dns := c.String("dns")
-	gateway := c.String("gateway")
-	netmask := c.String("netmask")
-	masterIP := c.String("master-ip")
-	masterIP2 := c.String("master-ip2")
-	loadBalancerIP := c.String("load-balancer-ip")
-	container_network := c.String("container-network")
-	etcd1 := c.String("etcd1")
-	etcd2 := c.String("etcd2")
-	etcd3 := c.String("etcd3")
-	batch_size := c.Int("batchSize")
+	name := stringOrSpec(c.String("name"), spec.Name)
+	service_type := stringOrSpec(c.String("type"), spec.Type)
+	vm_flavor := stringOrSpec(c.String("vm_flavor"), spec.VMFlavor)
+	master_vm_flavor := stringOrSpec(c.String("master-vm-flavor"), spec.MasterVmFlavor)
+	worker_vm_flavor := stringOrSpec(c.String("worker-vm-flavor"), spec.WorkerVmFlavor)
+	disk_flavor := stringOrSpec(c.String("disk_flavor"), spec.DiskFlavor)
+	subnet_id := stringOrSpec(c.String("subnet_id"), spec.SubnetId)
+	image_id := stringOrSpec(c.String("image-id"), spec.ImageID)
+	worker_count := intOrSpec(c.Int("worker_count"), spec.WorkerCount)
+	dns := stringOrSpec(c.String("dns"), properties[photon.ExtendedPropertyDNS])
+	gateway := stringOrSpec(c.String("gateway"), properties[photon.ExtendedPropertyGateway])
+	netmask := stringOrSpec(c.String("netmask"), properties[photon.ExtendedPropertyNetMask])
+	masterIP := stringOrSpec(c.String("master-ip"), properties[photon.ExtendedPropertyMasterIP])
+	masterIP2 := stringOrSpec(c.String("master-ip2"), properties[photon.ExtendedPropertyMasterIP2])
+	loadBalancerIP := stringOrSpec(c.String("load-balancer-ip"), properties[photon.ExtendedPropertyLoadBalancerIP])
+	container_network := stringOrSpec(c.String("container-network"),
+		properties[photon.ExtendedPropertyContainerNetwork])
+	etcd1 := stringOrSpec(c.String("etcd1"), properties[photon.ExtendedPropertyETCDIP1])
+	etcd2 := stringOrSpec(c.String("etcd2"), properties[photon.ExtendedPropertyETCDIP2])
+	etcd3 := stringOrSpec(c.String("etcd3"), properties[photon.ExtendedPropertyETCDIP3])
+	batch_size := intOrSpec(c.Int("batchSize"), spec.BatchSizeWorker)
 	ssh_key := c.String("ssh-key")
 	ca_cert := c.String("registry-ca-cert")
-	admin_password := c.String("admin-password")
+	admin_password := stringOrSpec(c.String("admin-password"), properties[photon.ExtendedPropertyAdminPassword])
 
 	if admin_password != "" {
 		result := validateHarborPassword(admin_password)
@@ -496,7 +605,12 @@ func createService(c *cli.Context, w io.Writer) error {
 		return fmt.Errorf("Provide a valid DNS, gateway, and netmask")
 	}
 
+	// The properties of the spec file that have no option, such as the content of
+	// the ssh key, are kept as they are.
 	extended_properties := make(map[string]string)
+	for key, value := range properties {
+		extended_properties[key] = value
+	}
 	extended_properties[photon.ExtendedPropertyDNS] = dns
 	extended_properties[photon.ExtendedPropertyGateway] = gateway
 	extended_properties[photon.ExtendedPropertyNetMask] = netmask
@@ -529,8 +643,10 @@ func createService(c *cli.Context, w io.Writer) error {
 		}
 		extended_properties[photon.ExtendedPropertyContainerNetwork] = container_network
 		if sdn {
-			etcdCount := c.Int("number-of-etcds")
-			masterCount := c.Int("number-of-masters")
+			specEtcdCount, _ := strconv.Atoi(properties[photon.ExtendedPropertyNumberOfETCDs])
+			specMasterCount, _ := strconv.Atoi(properties[photon.ExtendedPropertyNumberOfMasters])
+			etcdCount := intOrSpec(c.Int("number-of-etcds"), specEtcdCount)
+			masterCount := intOrSpec(c.Int("number-of-masters"), specMasterCount)
 			if !c.GlobalIsSet("non-interactive") {
 				etcdCount, err = askForInputInt("Number of Etcd instances: ", etcdCount)
 				if err != nil {
@@ -1076,7 +1192,9 @@ func changeVersion(c *cli.Context, w io.Writer) error {
 	return nil
 }
 
-func getKubectlAuth(c *cli.Context) error {
+// Prints the kubectl commands, or writes the kubeconfig entries, that give kubectl
+// access to a Kubernetes service with the OIDC tokens of a user.
+func getKubectlAuth(c *cli.Context, w io.Writer) error {
 	err := checkArgCount(c, 1)
 	if err != nil {
 		return err
@@ -1143,61 +1261,123 @@ func getKubectlAuth(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	var lightwaveCA []byte
+	for _, cert := range certs {
+		lightwaveCA = append(lightwaveCA, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
 
-	certFileName := "lw-ca-cert-" + generateRandomString(4) + ".pem"
-	certFile, err := ioutil.TempFile(os.TempDir(), certFileName)
-
+	options, err := client.Photonclient.Auth.GetClientTokensByPassword(username, password, service.ClientID)
 	if err != nil {
 		return err
 	}
 
-	for _, cert := range certs {
-		_, err := certFile.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Headers: nil, Bytes: cert.Raw}))
+	loadBalancerIp := service.ExtendedProperties[photon.ExtendedPropertyLoadBalancerIP]
+	auth := &kubectlAuth{
+		ClusterName: service.Name,
+		Server:      fmt.Sprintf("https://%s:6443", loadBalancerIp),
+		UserName:    username,
+		AuthProvider: yaml.MapSlice{
+			{Key: "idp-issuer-url", Value: fmt.Sprintf("https://%s/openidconnect/%s", authInfo.Endpoint, authInfo.Domain)},
+			{Key: "client-id", Value: service.ClientID},
+			{Key: "client-secret", Value: service.ClientID},
+			{Key: "refresh-token", Value: options.RefreshToken},
+			{Key: "id-token", Value: options.IdToken},
+			{Key: "idp-certificate-authority-data", Value: base64.StdEncoding.EncodeToString(lightwaveCA)},
+		},
+	}
+	if c.Bool("exec-credential") {
+		auth.ExecServiceID = service.ID
+		// Cached for service kubectl-credential
+		err = cf.SaveServiceTokens(service.ID, &cf.ServiceTokens{
+			ClientID:     service.ClientID,
+			Username:     username,
+			IdToken:      options.IdToken,
+			RefreshToken: options.RefreshToken,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	err = certFile.Close()
-	if err != nil {
-		return err
+	kubeconfig := c.String("kubeconfig")
+	if len(kubeconfig) == 0 && c.Bool("write-kubeconfig") {
+		kubeconfig = defaultKubeconfigPath()
+	}
+	if len(kubeconfig) == 0 {
+		if !c.Bool("insecure-skip-tls-verify") {
+			fmt.Fprintf(os.Stderr, "Warning: the certificate of the cluster is not verified, "+
+				"use --write-kubeconfig to fetch its CA\n")
+		}
+		printKubectlCommands(auth, w)
+		return nil
 	}
 
-	options, err := client.Photonclient.Auth.GetClientTokensByPassword(username, password, service.ClientID)
+	if !c.Bool("insecure-skip-tls-verify") {
+		auth.ClusterCA, err = getClusterCA(c, fmt.Sprintf("%s:6443", loadBalancerIp))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeKubeconfig(kubeconfig, auth)
 	if err != nil {
 		return err
 	}
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "Context '%s' of user '%s' written to %s and set as current context\n",
+			auth.contextName(), auth.UserName, kubeconfig)
+	}
+	return nil
+}
 
-	// Command for create the user in the kubectl config
-	fmt.Printf("kubectl config set-credentials %s \\\n", username)
-	fmt.Printf("    --auth-provider=oidc \\\n")
-	fmt.Printf("    --auth-provider-arg=idp-issuer-url=https://%s/openidconnect/%s \\\n", authInfo.Endpoint, authInfo.Domain)
-	fmt.Printf("    --auth-provider-arg=client-id=%s \\\n", service.ClientID)
-	fmt.Printf("    --auth-provider-arg=client-secret=%s \\\n", service.ClientID)
-	fmt.Printf("    --auth-provider-arg=refresh-token=%s \\\n", options.RefreshToken)
-	fmt.Printf("    --auth-provider-arg=id-token=%s \\\n", options.IdToken)
-	fmt.Printf("    --auth-provider-arg=idp-certificate-authority=%s \n", certFile.Name())
-	fmt.Println("")
-
-	loadBalancerIp := service.ExtendedProperties[photon.ExtendedPropertyLoadBalancerIP]
-	clusterName := service.Name
-
-	// Command for create the cluster in the kubectl config
-	fmt.Printf("kubectl config set-cluster %s \\\n", clusterName)
-	fmt.Printf("    --server=https://%s:6443 \\\n", loadBalancerIp)
-	fmt.Printf("    --insecure-skip-tls-verify=true \n")
-	fmt.Println("")
-
-	// Command for create the context in the kubectl config
-	fmt.Printf("kubectl config set-context %s-context \\\n", clusterName)
-	fmt.Printf("    --cluster %s \\\n", clusterName)
-	fmt.Printf("    --user=%s \n", username)
-	fmt.Println("")
+// Returns the PEM encoded CA of the cluster, read from --cluster-ca or fetched from
+// its load balancer. A fetched certificate is only trusted once the user confirms
+// its fingerprint, so it is refused in non-interactive mode.
+func getClusterCA(c *cli.Context, server string) ([]byte, error) {
+	if caFile := c.String("cluster-ca"); len(caFile) != 0 {
+		return readClusterCA(caFile)
+	}
+	if c.GlobalIsSet("non-interactive") {
+		return nil, fmt.Errorf("Could not establish trust with the cluster %s, use --cluster-ca to provide "+
+			"its CA or --insecure-skip-tls-verify to skip the verification of its certificate", server)
+	}
 
-	// Command for use the kubectl text just created as default
-	fmt.Printf("kubectl config use-context %s-context \n", clusterName)
+	clusterCA, err := getServerCACert(server)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch the CA of the cluster, use --cluster-ca to provide it or "+
+			"--insecure-skip-tls-verify to skip the verification of its certificate: %s", err)
+	}
+	kind := "CA certificate"
+	if !clusterCA.IsCA {
+		kind = "certificate (not a CA)"
+	}
+	fmt.Printf("The %s presented by the cluster (%s) isn't trusted.\nSubject = %s\nSHA1  = %X\n",
+		kind, server, clusterCA.Subject, sha1.Sum(clusterCA.Raw))
+	trustCA, err := askForInput("Do you trust this certificate for the cluster? (yes/no): ", "")
+	if err != nil {
+		return nil, err
+	}
+	if trustCA != "yes" {
+		return nil, fmt.Errorf("The certificate of the cluster is not trusted, use --cluster-ca to provide its CA")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clusterCA.Raw}), nil
+}
 
-	return nil
+// Reads a PEM encoded CA certificate from a file.
+func readClusterCA(caFile string) ([]byte, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s is not a PEM encoded certificate", caFile)
+	}
+	_, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid certificate: %s", caFile, err)
+	}
+	return data, nil
 }
 
 // Helper routine which waits for a service to enter the READY state.
@@ -1379,18 +1559,3 @@ func validateHarborPassword(password string) bool {
 	}
 	return correct && number && upper && lower && (count >= 7)
 }
-
-func generateRandomString(length int) string {
-	const asciiA = 65
-	const asciiZ = 90
-	rand.Seed(time.Now().UTC().UnixNano())
-	bytes := make([]byte, length)
-	for i := 0; i < length; i++ {
-		bytes[i] = byte(randInt(asciiA, asciiZ))
-	}
-	return string(bytes)
-}
-
-func randInt(min int, max int) int {
-	return min + rand.Intn(max-min)
-}
@@ -0,0 +1,172 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// Flag giving the spec of a create command in a file. Options given on the command
+// line override the fields of the file.
+var specFileFlag = cli.StringFlag{
+	Name:  "spec-file",
+	Usage: "Create spec in YAML or JSON, or - for stdin. Options override the fields of the file",
+}
+
+// Reads the file of --spec-file into spec, if the flag is given.
+func readSpecFileFlag(c *cli.Context, spec interface{}) error {
+	path := c.String("spec-file")
+	if len(path) == 0 {
+		return nil
+	}
+	return readSpecFile(path, spec)
+}
+
+// Returns the value of an option if it is given, or the value of the spec file otherwise.
+func stringOrSpec(value, specValue string) string {
+	if len(value) != 0 {
+		return value
+	}
+	return specValue
+}
+
+// Returns the value of an option if it is not zero, or the value of the spec file otherwise.
+func intOrSpec(value, specValue int) int {
+	if value != 0 {
+		return value
+	}
+	return specValue
+}
+
+// Reads a spec file in JSON or YAML, or stdin if path is "-", into spec. The fields
+// of the file are the JSON fields of spec, and unknown fields are errors.
+func readSpecFile(path string, spec interface{}) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	// YAML is a superset of JSON, the document is converted to JSON so that the
+	// JSON tags of the SDK types apply.
+	var document interface{}
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return fmt.Errorf("Invalid spec file '%s': %s", path, err)
+	}
+	data, err = json.Marshal(yamlToJSON(document))
+	if err != nil {
+		return fmt.Errorf("Invalid spec file '%s': %s", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(spec)
+	if err != nil {
+		return fmt.Errorf("Invalid spec file '%s': %s", path, err)
+	}
+	return nil
+}
+
+// Converts the maps of a YAML document, which have interface{} keys, to maps
+// with string keys that can be encoded in JSON.
+func yamlToJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = yamlToJSON(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlToJSON(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Prints a spec in JSON with --output json, or in YAML otherwise. The YAML
+// leaves out empty fields and keeps the order of the JSON fields.
+func printSpec(spec interface{}, w io.Writer, c *cli.Context) error {
+	if utils.NeedsFormatting(c) {
+		utils.FormatObject(spec, w, c)
+		return nil
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	var document yaml.MapSlice
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return err
+	}
+	data, err = yaml.Marshal(pruneSpec(document))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Removes the empty strings, lists and maps from a YAML document.
+func pruneSpec(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		pruned := yaml.MapSlice{}
+		for _, item := range v {
+			item.Value = pruneSpec(item.Value)
+			if !isEmptySpecValue(item.Value) {
+				pruned = append(pruned, item)
+			}
+		}
+		return pruned
+	case []interface{}:
+		for i, item := range v {
+			v[i] = pruneSpec(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isEmptySpecValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	case yaml.MapSlice:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
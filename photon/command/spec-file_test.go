@@ -0,0 +1,142 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func writeSpecFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func specFileContext(t *testing.T, set *flag.FlagSet, args []string) *cli.Context {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	err := globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = set.Parse(args)
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	return cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil))
+}
+
+func TestCreateFromSpecFile(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+
+	// The name of the project comes from the file, the CIDR from the command line
+	path := writeSpecFile(t, "name: project1\n"+
+		"defaultRouterPrivateIpCidr: 10.1.0.0/16\n"+
+		"quota:\n"+
+		"  quotaItems:\n"+
+		"    vm.count: {limit: 10, unit: COUNT}\n")
+	defer func(path string) {
+		_ = os.Remove(path)
+	}(path)
+	set := flag.NewFlagSet("test", 0)
+	for _, name := range []string{"limits", "security-groups", "default-router-private-ip-cidr"} {
+		set.String(name, "", name)
+	}
+	set.Float64("percent", 0, "percent")
+	set.String("tenant", "tenant1", "tenant")
+	set.String("spec-file", path, "spec file")
+	err = createProject(specFileContext(t, set, []string{"--default-router-private-ip-cidr=10.2.0.0/16"}),
+		ioutil.Discard)
+	if err != nil {
+		t.Fatal("Not expecting error creating project from spec: " + err.Error())
+	}
+	projects, err := api.Tenants.GetProjects(tenantID, &photon.ProjectGetOptions{Name: "project1"})
+	if err != nil || len(projects.Items) != 1 {
+		t.Fatalf("Expected the project to be created with the name of the spec file, got %v, %v", projects, err)
+	}
+	project, err := api.Projects.Get(projects.Items[0].ID)
+	if err != nil {
+		t.Fatal("Not expecting error getting project: " + err.Error())
+	}
+	routers, err := api.Projects.GetRouters(project.ID, nil)
+	if err != nil || len(routers.Items) != 1 || routers.Items[0].PrivateIpCidr != "10.2.0.0/16" {
+		t.Errorf("Expected the option to override the CIDR of the spec file, got %v, %v", routers, err)
+	}
+	if project.ResourceQuota.QuotaLineItems["vm.count"].Limit != 10 {
+		t.Errorf("Expected the quota of the spec file, got %+v", project.ResourceQuota)
+	}
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "disk-small", Kind: "persistent-disk"})
+	path = writeSpecFile(t, `{"name": "data", "flavor": "disk-small", "capacityGb": 20, "tags": ["backup"]}`)
+	defer func(path string) {
+		_ = os.Remove(path)
+	}(path)
+	set = flag.NewFlagSet("test", 0)
+	for _, name := range []string{"name", "flavor", "affinities", "tags"} {
+		set.String(name, "", name)
+	}
+	set.Int("capacityGB", 0, "capacity")
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", "project1", "project")
+	set.String("spec-file", path, "spec file")
+	err = createDisk(specFileContext(t, set, []string{"--name=logs"}), ioutil.Discard)
+	if err != nil {
+		t.Fatal("Not expecting error creating disk from spec: " + err.Error())
+	}
+	disks, err := api.Projects.GetDisks(project.ID, &photon.DiskGetOptions{Name: "logs"})
+	if err != nil || len(disks.Items) != 1 {
+		t.Fatalf("Expected the disk to be created with the name given on the command line, got %v, %v", disks, err)
+	}
+	disk := disks.Items[0]
+	if disk.Flavor != "disk-small" || disk.CapacityGB != 20 || disk.Kind != "persistent-disk" ||
+		!reflect.DeepEqual(disk.Tags, []string{"backup"}) {
+		t.Errorf("Expected the disk to match the spec, got %+v", disk)
+	}
+
+	path = writeSpecFile(t, "name: data\ncapacity: 20\n")
+	defer func(path string) {
+		_ = os.Remove(path)
+	}(path)
+	err = set.Set("spec-file", path)
+	if err != nil {
+		t.Error("Not expecting setting the spec file to fail")
+	}
+	err = createDisk(specFileContext(t, set, nil), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), `unknown field "capacity"`) {
+		t.Errorf("Expected unknown field to be rejected, got %v", err)
+	}
+}
@@ -62,3 +62,20 @@ func getServerCert(server string) (*x509.Certificate, error) {
 	fmt.Println(err)
 	return nil, err
 }
+
+// Returns the last certificate of the chain a server presents, the one closest to
+// its root CA, so that it can be trusted to verify the server.
+func getServerCACert(server string) (*x509.Certificate, error) {
+	config := tls.Config{InsecureSkipVerify: true}
+	conn, err := tls.Dial("tcp", server, &config)
+	if err != nil {
+		return nil, err
+	}
+	chain := conn.ConnectionState().PeerCertificates
+	_ = conn.Close()
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("Server %s presented no certificate", server)
+	}
+	return chain[len(chain)-1], nil
+}
@@ -15,9 +15,11 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
 	"github.com/vmware/photon-controller-cli/photon/utils"
 
 	"github.com/urfave/cli"
@@ -44,8 +46,11 @@ func GetSubnetsCommand() cli.Command {
 					"   Example: \n" +
 					"    Virtual Subnet:\n" +
 					"      photon subnet create -n test -d \"Testing Subnet\" -i 192.168.0.0/16 -r id -s 172.10.0.1\n" +
+					"      photon subnet create -n test -d \"Testing Subnet\" --auto-cidr -r id -s 172.10.0.1\n" +
 					"    Physical Subnet:\n" +
-					"      photon subnet create -n test -d \"Testing Subnet\" -p port1,port2 \n",
+					"      photon subnet create -n test -d \"Testing Subnet\" -p port1,port2 \n" +
+					"    From a spec file, the options override the fields of the file:\n" +
+					"      photon subnet create --spec-file subnet.yaml -r id -n test\n",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -59,6 +64,15 @@ func GetSubnetsCommand() cli.Command {
 						Name:  "privateIpCidr, i",
 						Usage: "The private IP range of subnet in CIDR format, e.g.: 192.168.0.0/16",
 					},
+					cli.BoolFlag{
+						Name:  "auto-cidr",
+						Usage: "Use the first range of the router that no other subnet of the router uses",
+					},
+					cli.IntFlag{
+						Name:  "prefix-length",
+						Value: defaultSubnetPrefixLength,
+						Usage: "Prefix length of the range picked with --auto-cidr",
+					},
 					cli.StringFlag{
 						Name:  "router, r",
 						Usage: "The id of the router on which subnet is to be created",
@@ -75,6 +89,7 @@ func GetSubnetsCommand() cli.Command {
 						Name:  "dns-server-addresses, s",
 						Usage: "Comma-separated list of DNS server addresses (Max allowed addresses: 2)",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createSubnet(c, os.Stdout)
@@ -178,33 +193,42 @@ func GetSubnetsCommand() cli.Command {
 func createSubnet(c *cli.Context, w io.Writer) error {
 	routerID := c.String("router")
 
+	spec := photon.SubnetCreateSpec{}
+	err := readSpecFileFlag(c, &spec)
+	if err != nil {
+		return err
+	}
+
 	if len(routerID) == 0 {
-		return createPhysicalSubnet(c, w)
+		return createPhysicalSubnet(c, w, spec)
 	} else {
-		return createVirtualSubnet(c, w, routerID)
+		return createVirtualSubnet(c, w, routerID, spec)
 	}
 }
 
 // Creates a virtual subnet under a router
 // Returns an error if one occurred
-func createVirtualSubnet(c *cli.Context, w io.Writer, routerId string) error {
+func createVirtualSubnet(c *cli.Context, w io.Writer, routerId string, spec photon.SubnetCreateSpec) error {
 	err := checkArgCount(c, 0)
 	if err != nil {
 		return err
 	}
 
-	name := c.String("name")
-	description := c.String("description")
+	name := stringOrSpec(c.String("name"), spec.Name)
+	description := stringOrSpec(c.String("description"), spec.Description)
+	autoCidr := c.Bool("auto-cidr")
 	privateIpCidr := c.String("privateIpCidr")
-	subnetType := c.String("type")
-	dnsServerAddresses := c.String("dns-server-addresses")
+	if !autoCidr {
+		privateIpCidr = stringOrSpec(privateIpCidr, spec.PrivateIpCidr)
+	}
+	subnetType := stringOrSpec(c.String("type"), spec.Type)
+	dnsServerAddresses := stringOrSpec(c.String("dns-server-addresses"), strings.Join(spec.DnsServerAddresses, ","))
 
-	client.Photonclient, err = client.GetClient(c)
-	if err != nil {
-		return err
+	if autoCidr && len(privateIpCidr) != 0 {
+		return fmt.Errorf("Please provide either privateIpCidr or --auto-cidr")
 	}
 
-	router, err := client.Photonclient.Routers.Get(routerId)
+	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
 	}
@@ -218,9 +242,11 @@ func createVirtualSubnet(c *cli.Context, w io.Writer, routerId string) error {
 		if err != nil {
 			return err
 		}
-		privateIpCidr, err = askForInput("Subnet privateIpCidr: ", privateIpCidr)
-		if err != nil {
-			return err
+		if !autoCidr {
+			privateIpCidr, err = askForInput("Subnet privateIpCidr: ", privateIpCidr)
+			if err != nil {
+				return err
+			}
 		}
 		subnetType, err = askForInput("Subnet type (NAT, NO_NAT or PROVIDER. Default is NAT): ", subnetType)
 		if err != nil {
@@ -232,17 +258,46 @@ func createVirtualSubnet(c *cli.Context, w io.Writer, routerId string) error {
 		}
 	}
 
-	if len(name) == 0 || len(description) == 0 || len(privateIpCidr) == 0 {
+	if len(name) == 0 || len(description) == 0 || (len(privateIpCidr) == 0 && !autoCidr) {
 		return fmt.Errorf("Please provide name, description and privateIpCidr")
 	}
 
+	if !autoCidr {
+		err = checkCidrFromFlag("privateIpCidr", privateIpCidr)
+		if err != nil {
+			return err
+		}
+	}
+	dnsServerAddressList, err := parseIpListFromFlag("dns-server-addresses", dnsServerAddresses)
+	if err != nil {
+		return err
+	}
+
 	if len(subnetType) == 0 {
 		subnetType = "NAT"
 	}
 
-	dnsServerAddressList := []string{}
-	if dnsServerAddresses != "" {
-		dnsServerAddressList = regexp.MustCompile(`\s*,\s*`).Split(dnsServerAddresses, -1)
+	router, err := client.Photonclient.Routers.Get(routerId)
+	if err != nil {
+		return err
+	}
+	if autoCidr {
+		used, err := getRouterSubnetCidrs(router)
+		if err != nil {
+			return err
+		}
+		plan, err := planCidrs(router.PrivateIpCidr, c.Int("prefix-length"), 1, used)
+		if err != nil {
+			return err
+		}
+		privateIpCidr = plan.Proposed[0]
+	}
+	if routerRange, err := ipaddr.ParseRange(router.PrivateIpCidr); err == nil {
+		subnetRange, _ := ipaddr.ParseRange(privateIpCidr)
+		if !routerRange.Contains(subnetRange.Start) || !routerRange.Contains(subnetRange.End) {
+			return fmt.Errorf("Subnet range %s is not inside the range %s of router '%s'",
+				privateIpCidr, router.PrivateIpCidr, router.Name)
+		}
 	}
 
 	subnetSpec := photon.SubnetCreateSpec{}
@@ -280,15 +335,15 @@ func createVirtualSubnet(c *cli.Context, w io.Writer, routerId string) error {
 
 // Creates a PORT_GROUP type subnet
 // Returns an error if one occurred
-func createPhysicalSubnet(c *cli.Context, w io.Writer) error {
+func createPhysicalSubnet(c *cli.Context, w io.Writer, spec photon.SubnetCreateSpec) error {
 	err := checkArgCount(c, 0)
 	if err != nil {
 		return err
 	}
 
-	name := c.String("name")
-	description := c.String("description")
-	portGroups := c.String("portgroups")
+	name := stringOrSpec(c.String("name"), spec.Name)
+	description := stringOrSpec(c.String("description"), spec.Description)
+	portGroups := stringOrSpec(c.String("portgroups"), strings.Join(spec.PortGroups.Names, ","))
 
 	if !c.GlobalIsSet("non-interactive") {
 		name, err = askForInput("Subnet name: ", name)
@@ -13,12 +13,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
 	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon"
@@ -91,9 +94,9 @@ func TestCreateDeleteVirtualSubnet(t *testing.T) {
 	set := flag.NewFlagSet("test", 0)
 	set.String("name", "fake_subnet_name", "Subnet name")
 	set.String("description", "test subnet", "Subnet description")
-	set.String("privateIpCidr", "fake_subnet_privateIpCidr", "Subnet privateIpCidr")
+	set.String("privateIpCidr", "192.168.1.0/24", "Subnet privateIpCidr")
 	set.String("router", "fake_router_ID", "Router id")
-	set.String("dns-server-addresses", "dnsServerAddress1,dnsServerAddress2", "Comma separated DNS server "+
+	set.String("dns-server-addresses", "10.0.0.2,10.0.0.3", "Comma separated DNS server "+
 		"addresses")
 
 	cxt := cli.NewContext(nil, set, globalCtx)
@@ -147,6 +150,58 @@ func TestCreateDeleteVirtualSubnet(t *testing.T) {
 	}
 }
 
+func TestCreateSubnetChecksAddresses(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{Name: "project1"})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateRouter(projectID, &photon.RouterCreateSpec{Name: "router1", PrivateIpCidr: "10.1.0.0/16"})
+	routerID := waitForEntity(t, task, err)
+
+	counter := &changeCounter{}
+	options := &photon.ClientOptions{TaskPollDelay: time.Millisecond}
+	client.Photonclient = photon.NewTestClient(sim.URL, options, &http.Client{Transport: counter})
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	cases := []struct {
+		cidr, dnsServers, expected string
+		requests                   int
+	}{
+		{"10.1.4.5/24", "10.0.0.2", "Invalid --privateIpCidr: CIDR '10.1.4.5/24' has host bits set, did you mean 10.1.4.0/24?", 0},
+		{"10.1.4.0", "10.0.0.2", "Invalid --privateIpCidr: bad CIDR '10.1.4.0', expected an address and a prefix length such as 10.0.0.0/24", 0},
+		{"10.1.4.0/24", "10.0.0.2, dns1", "Invalid --dns-server-addresses: bad IP address 'dns1'", 0},
+		{"10.2.4.0/24", "", "Subnet range 10.2.4.0/24 is not inside the range 10.1.0.0/16 of router 'router1'", 1},
+	}
+	for _, tc := range cases {
+		counter.requests = 0
+		set := flag.NewFlagSet("test", 0)
+		set.String("name", "subnet1", "Subnet name")
+		set.String("description", "test subnet", "Subnet description")
+		set.String("privateIpCidr", tc.cidr, "Subnet privateIpCidr")
+		set.String("router", routerID, "Router id")
+		set.String("dns-server-addresses", tc.dnsServers, "Comma separated DNS server addresses")
+
+		err = createSubnet(cli.NewContext(nil, set, globalCtx), ioutil.Discard)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("Expected error '%s' creating subnet %s, got %v", tc.expected, tc.cidr, err)
+		}
+		if counter.requests != tc.requests {
+			t.Errorf("Expected %d request(s) creating subnet %s, got %d", tc.requests, tc.cidr, counter.requests)
+		}
+	}
+}
+
 func TestCreateDeletePhysicalSubnet(t *testing.T) {
 	queuedTask := &photon.Task{
 		Operation: "CREATE_SUBNET",
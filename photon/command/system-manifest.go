@@ -0,0 +1,396 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+	"github.com/vmware/photon-controller-cli/photon/manifest"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+	"gopkg.in/yaml.v2"
+)
+
+// Configure the system from the deployment section of a host file, then add its hosts.
+// Settings that already match the system are left unchanged.
+func applyManifest(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	file := c.Args().First()
+	dcMap, err := manifest.ValidateInstallation(file)
+	if err != nil {
+		return err
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+	info, err := client.Photonclient.System.GetSystemInfo()
+	if err != nil {
+		return err
+	}
+
+	err = applyImageDatastores(w, dcMap, info)
+	if err != nil {
+		return err
+	}
+	err = applyNsxConfiguration(w, dcMap, info)
+	if err != nil {
+		return err
+	}
+	err = applySecurityGroups(w, dcMap, info)
+	if err != nil {
+		return err
+	}
+
+	return createHostsInBatch(dcMap, file, 0, w, !c.GlobalIsSet("non-interactive"))
+}
+
+func applyImageDatastores(w io.Writer, dcMap *manifest.Installation, info *photon.SystemInfo) error {
+	datastores := []string(dcMap.Deployment.ImageDatastores)
+	if len(datastores) == 0 {
+		return nil
+	}
+	if sameItems(datastores, info.ImageDatastores) {
+		fmt.Fprintf(w, "Image datastores are up to date\n")
+		return nil
+	}
+
+	task, err := client.Photonclient.Infra.SetImageDatastores(&photon.ImageDatastores{Items: datastores})
+	if err != nil {
+		return err
+	}
+	_, err = pollTask(task.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Image datastores set to %s\n", strings.Join(datastores, ", "))
+	return nil
+}
+
+func applyNsxConfiguration(w io.Writer, dcMap *manifest.Installation, info *photon.SystemInfo) error {
+	if !dcMap.Deployment.SdnEnabled {
+		return nil
+	}
+	spec, err := nsxConfigurationSpec(dcMap)
+	if err != nil {
+		return err
+	}
+	if nsxConfigured(spec, info.NetworkConfiguration) {
+		fmt.Fprintf(w, "NSX configuration is up to date\n")
+		return nil
+	}
+
+	task, err := client.Photonclient.System.ConfigureNsx(spec)
+	if err != nil {
+		return err
+	}
+	_, err = pollTask(task.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "NSX configured with network manager '%s'\n", spec.NsxAddress)
+	return nil
+}
+
+func applySecurityGroups(w io.Writer, dcMap *manifest.Installation, info *photon.SystemInfo) error {
+	groups := dcMap.Deployment.AuthSecurityGroups
+	if len(groups) == 0 {
+		return nil
+	}
+	if info.Auth != nil && sameItems(groups, info.Auth.SecurityGroups) {
+		fmt.Fprintf(w, "Security groups are up to date\n")
+		return nil
+	}
+
+	task, err := client.Photonclient.System.SetSecurityGroups(&photon.SecurityGroupsSpec{Items: groups})
+	if err != nil {
+		return err
+	}
+	_, err = pollTask(task.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Security groups set to %s\n", strings.Join(groups, ", "))
+	return nil
+}
+
+// Returns the NSX configuration described by the network fields of the deployment section.
+func nsxConfigurationSpec(dcMap *manifest.Installation) (*photon.NsxConfigurationSpec, error) {
+	d := dcMap.Deployment
+	floatingIpRange, err := parseIpRange(d.NetworkExternalIpRange)
+	if err != nil {
+		return nil, err
+	}
+	return &photon.NsxConfigurationSpec{
+		NsxAddress:             d.NetworkManagerAddress,
+		NsxUsername:            d.NetworkManagerUsername,
+		NsxPassword:            d.NetworkManagerPassword,
+		FloatingIpRootRange:    floatingIpRange,
+		T0RouterId:             d.NetworkTopRouterId,
+		OverlayTransportZoneId: d.NetworkZoneId,
+		TunnelIpPoolId:         d.NetworkEdgeIpPoolId,
+		HostUplinkPnic:         d.NetworkHostUplinkPnic,
+	}, nil
+}
+
+// Returns true if the system network configuration matches the spec. The
+// password is not reported by the system, so it is not compared.
+func nsxConfigured(spec *photon.NsxConfigurationSpec, config *photon.NetworkConfiguration) bool {
+	if config == nil || !config.Enabled {
+		return false
+	}
+	floatingIpRange := photon.IpRange{}
+	if config.FloatingIpRange != nil {
+		floatingIpRange = *config.FloatingIpRange
+	}
+	return config.Address == spec.NsxAddress &&
+		config.Username == spec.NsxUsername &&
+		config.NetworkZoneId == spec.OverlayTransportZoneId &&
+		config.TopRouterId == spec.T0RouterId &&
+		config.EdgeIpPoolId == spec.TunnelIpPoolId &&
+		config.HostUplinkPnic == spec.HostUplinkPnic &&
+		floatingIpRange == spec.FloatingIpRootRange
+}
+
+// Parses an address range given either as a CIDR or as "start-end".
+func parseIpRange(ipRange string) (photon.IpRange, error) {
+	if len(ipRange) == 0 {
+		return photon.IpRange{}, nil
+	}
+	r, err := ipaddr.ParseRange(ipRange)
+	if err != nil {
+		return photon.IpRange{}, err
+	}
+	return photon.IpRange{Start: r.Start.String(), End: r.End.String()}, nil
+}
+
+// Returns true if both lists hold the same items, in any order.
+func sameItems(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Write a host file describing the hosts and the configuration of the system
+func exportManifest(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	info, err := client.Photonclient.System.GetSystemInfo()
+	if err != nil {
+		return err
+	}
+	hosts, err := client.Photonclient.InfraHosts.GetHosts()
+	if err != nil {
+		return err
+	}
+	zones, err := client.Photonclient.Zones.GetAll()
+	if err != nil {
+		return err
+	}
+	zoneNames := make(map[string]string)
+	for _, zone := range zones.Items {
+		zoneNames[zone.ID] = zone.Name
+	}
+
+	dcMap := &manifest.Installation{
+		Deployment: exportDeployment(info),
+		Hosts:      exportHosts(hosts.Items, zoneNames),
+	}
+	buf, err := yaml.Marshal(dcMap)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# Exported from %s, replace the %s placeholders before using this file\n",
+		client.Photonclient.Endpoint, manifest.PasswordPlaceholder)
+	_, err = w.Write(buf)
+	return err
+}
+
+// Returns the deployment section describing the system configuration.
+func exportDeployment(info *photon.SystemInfo) manifest.Deployment {
+	d := manifest.Deployment{
+		ImageDatastores:         info.ImageDatastores,
+		UseImageDatastoreForVms: info.UseImageDatastoreForVms,
+		LoadBalancerEnabled:     strconv.FormatBool(info.LoadBalancerEnabled),
+	}
+	if len(info.SyslogEndpoint) != 0 {
+		d.SyslogEndpoint = info.SyslogEndpoint
+	}
+	if len(info.NTPEndpoint) != 0 {
+		d.NTPEndpoint = info.NTPEndpoint
+	}
+	if stats := info.Stats; stats != nil {
+		d.StatsEnabled = stats.Enabled
+		d.StatsStoreEndpoint = stats.StoreEndpoint
+		d.StatsPort = stats.StorePort
+	}
+	if auth := info.Auth; auth != nil {
+		d.AuthEnabled = len(auth.Endpoint) != 0
+		d.AuthEndpoint = auth.Endpoint
+		d.AuthPort = auth.Port
+		d.AuthUsername = auth.Username
+		d.AuthTenant = auth.Domain
+		d.AuthSecurityGroups = auth.SecurityGroups
+		if d.AuthEnabled {
+			d.AuthPassword = manifest.PasswordPlaceholder
+		}
+	}
+	if network := info.NetworkConfiguration; network != nil && network.Enabled {
+		d.SdnEnabled = true
+		d.NetworkManagerAddress = network.Address
+		d.NetworkManagerUsername = network.Username
+		d.NetworkManagerPassword = manifest.PasswordPlaceholder
+		d.NetworkZoneId = network.NetworkZoneId
+		d.NetworkTopRouterId = network.TopRouterId
+		d.NetworkEdgeIpPoolId = network.EdgeIpPoolId
+		d.NetworkHostUplinkPnic = network.HostUplinkPnic
+		d.NetworkIpRange = network.IpRange
+		if r := network.FloatingIpRange; r != nil && len(r.Start) != 0 {
+			d.NetworkExternalIpRange = r.Start + "-" + r.End
+		}
+	}
+	return d
+}
+
+// Returns the hosts grouped by zone, username, tags and metadata, with the
+// addresses of each group collapsed into ranges. The management network
+// addresses that add-hosts sets on each host are collapsed back into
+// MANAGEMENT_VM_IPS, in the same order as the hosts.
+func exportHosts(hosts []photon.Host, zoneNames map[string]string) []manifest.Host {
+	sorted := append([]photon.Host{}, hosts...)
+	sort.Sort(hostsByAddress(sorted))
+
+	type group struct {
+		host          manifest.Host
+		addresses     []string
+		managementIps []string
+	}
+	var groups []*group
+	groupsByKey := make(map[string]*group)
+	for _, host := range sorted {
+		metadata := make(map[string]string)
+		for key, value := range host.Metadata {
+			if key != "MANAGEMENT_NETWORK_IP" {
+				metadata[key] = value
+			}
+		}
+		managementIp, hasManagementIp := host.Metadata["MANAGEMENT_NETWORK_IP"]
+		zone, ok := zoneNames[host.Zone]
+		if !ok {
+			zone = host.Zone
+		}
+
+		key, err := json.Marshal([]interface{}{zone, host.Username, host.Tags, metadata, hasManagementIp})
+		if err != nil {
+			continue
+		}
+		g, ok := groupsByKey[string(key)]
+		if !ok {
+			g = &group{host: manifest.Host{
+				Username:         host.Username,
+				Password:         manifest.PasswordPlaceholder,
+				AvailabilityZone: zone,
+				Tags:             host.Tags,
+			}}
+			if len(metadata) != 0 {
+				g.host.Metadata = metadata
+			}
+			groups = append(groups, g)
+			groupsByKey[string(key)] = g
+		}
+		g.addresses = append(g.addresses, host.Address)
+		if hasManagementIp {
+			g.managementIps = append(g.managementIps, managementIp)
+		}
+	}
+
+	var res []manifest.Host
+	for _, g := range groups {
+		g.host.IpRanges = formatIpRanges(g.addresses)
+		if len(g.managementIps) != 0 {
+			g.host.Metadata["MANAGEMENT_VM_IPS"] = formatIpRanges(g.managementIps)
+		}
+		res = append(res, g.host)
+	}
+	return res
+}
+
+// Returns the addresses as a comma separated list, with consecutive
+// addresses collapsed into ranges such as "10.0.0.1-10.0.0.4".
+func formatIpRanges(addresses []string) string {
+	var ranges []string
+	for i := 0; i < len(addresses); {
+		j := i + 1
+		for j < len(addresses) && nextIp(addresses[j-1]) == addresses[j] {
+			j++
+		}
+		if j-i > 1 {
+			ranges = append(ranges, addresses[i]+"-"+addresses[j-1])
+		} else {
+			ranges = append(ranges, addresses[i])
+		}
+		i = j
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// Returns the address following an IP address, or an empty string.
+func nextIp(address string) string {
+	ip, err := ipaddr.ParseIP(address)
+	if err != nil {
+		return ""
+	}
+	next := ipaddr.Next(ip)
+	if next == nil {
+		return ""
+	}
+	return next.String()
+}
+
+type hostsByAddress []photon.Host
+
+func (h hostsByAddress) Len() int      { return len(h) }
+func (h hostsByAddress) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h hostsByAddress) Less(i, j int) bool {
+	a, errA := ipaddr.ParseIP(h[i].Address)
+	b, errB := ipaddr.ParseIP(h[j].Address)
+	if errA != nil || errB != nil {
+		return h[i].Address < h[j].Address
+	}
+	return ipaddr.Compare(a, b) < 0
+}
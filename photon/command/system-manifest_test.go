@@ -0,0 +1,226 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/manifest"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+	"gopkg.in/yaml.v2"
+)
+
+// Counts the requests, and those that change the system.
+type changeCounter struct {
+	requests int
+	changes  int
+}
+
+func (c *changeCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	if req.Method != "GET" {
+		c.changes++
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestApplyManifest(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	counter := &changeCounter{}
+	options := &photon.ClientOptions{TaskPollDelay: time.Millisecond}
+	client.Photonclient = photon.NewTestClient(sim.URL, options, &http.Client{Transport: counter})
+
+	file, err := ioutil.TempFile("", "apply-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+	_, err = file.WriteString(`deployment:
+  image_datastores: ds1, ds2
+  sdn_enabled: true
+  network_manager_address: 10.0.0.5
+  network_manager_username: admin
+  network_manager_password: secret
+  network_zone_id: zone-id
+  network_top_router_id: router-id
+  network_edge_ip_pool_id: pool-id
+  network_host_uplink_pnic: vmnic1
+  network_external_ip_range: 192.168.0.0/24
+  oauth_security_groups:
+    - tenant\admins
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{file.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	var output bytes.Buffer
+	err = applyManifest(cxt, &output)
+	if err != nil {
+		t.Fatal("Not expecting error applying manifest: " + err.Error())
+	}
+	if counter.changes != 3 {
+		t.Errorf("Expected 3 changes, got %d:\n%s", counter.changes, output.String())
+	}
+
+	info, err := client.Photonclient.System.GetSystemInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.ImageDatastores, []string{"ds1", "ds2"}) {
+		t.Errorf("Unexpected image datastores %v", info.ImageDatastores)
+	}
+	if info.NetworkConfiguration == nil || info.NetworkConfiguration.Address != "10.0.0.5" ||
+		info.NetworkConfiguration.FloatingIpRange.End != "192.168.0.255" {
+		t.Errorf("Unexpected network configuration %+v", info.NetworkConfiguration)
+	}
+	if !reflect.DeepEqual(info.Auth.SecurityGroups, []string{`tenant\admins`}) {
+		t.Errorf("Unexpected security groups %v", info.Auth.SecurityGroups)
+	}
+
+	// Applying the same manifest again changes nothing.
+	counter.changes = 0
+	output.Reset()
+	err = applyManifest(cxt, &output)
+	if err != nil {
+		t.Fatal("Not expecting error applying manifest again: " + err.Error())
+	}
+	if counter.changes != 0 || strings.Count(output.String(), "up to date") != 3 {
+		t.Errorf("Expected no changes, got %d:\n%s", counter.changes, output.String())
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+	defer func(delay time.Duration) { hostTaskPollDelay = delay }(hostTaskPollDelay)
+	hostTaskPollDelay = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "export-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, "hosts.yml")
+	err = ioutil.WriteFile(file, []byte(`deployment:
+  image_datastores: ds1
+hosts:
+  - address_ranges: 10.0.0.3, 10.0.0.1-10.0.0.2
+    username: root
+    password: vmware
+    availability_zone: zone1
+    usage_tags:
+      - MGMT
+    metadata:
+      MANAGEMENT_PORTGROUP: VM Network
+      MANAGEMENT_VM_IPS: 10.0.0.13, 10.0.0.11-10.0.0.12
+  - address_ranges: 10.0.0.5, 10.0.0.7
+    username: root
+    password: vmware
+    usage_tags:
+      - CLOUD
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{file})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = applyManifest(cli.NewContext(nil, set, nil), ioutil.Discard)
+	if err != nil {
+		t.Fatal("Not expecting error applying manifest: " + err.Error())
+	}
+
+	var output bytes.Buffer
+	err = exportManifest(cli.NewContext(nil, flag.NewFlagSet("test", 0), nil), &output)
+	if err != nil {
+		t.Fatal("Not expecting error exporting manifest: " + err.Error())
+	}
+	exported := filepath.Join(dir, "exported.yml")
+	err = ioutil.WriteFile(exported, output.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The placeholders must be replaced before the exported file can be applied.
+	_, err = manifest.ValidateInstallation(exported)
+	if err == nil || strings.Count(err.Error(), "replace the <password> placeholder") != 2 {
+		t.Errorf("Expected the password placeholders to be reported, got %v", err)
+	}
+	dcMap, err := manifest.LoadInstallation(exported)
+	if err != nil {
+		t.Fatalf("Not expecting error loading exported manifest: %s\n%s", err, output.String())
+	}
+	expected := []manifest.Host{
+		{
+			IpRanges:         "10.0.0.1-10.0.0.3",
+			Username:         "root",
+			Password:         manifest.PasswordPlaceholder,
+			AvailabilityZone: "zone1",
+			Tags:             []string{"MGMT"},
+			Metadata: map[string]string{
+				"MANAGEMENT_PORTGROUP": "VM Network",
+				"MANAGEMENT_VM_IPS":    "10.0.0.11-10.0.0.13",
+			},
+		},
+		{
+			IpRanges: "10.0.0.5, 10.0.0.7",
+			Username: "root",
+			Password: manifest.PasswordPlaceholder,
+			Tags:     []string{"CLOUD"},
+		},
+	}
+	if !reflect.DeepEqual(dcMap.Hosts, expected) {
+		t.Errorf("Unexpected hosts %+v", dcMap.Hosts)
+	}
+	if !reflect.DeepEqual([]string(dcMap.Deployment.ImageDatastores), []string{"ds1"}) {
+		t.Errorf("Unexpected image datastores %v", dcMap.Deployment.ImageDatastores)
+	}
+
+	buf, err := yaml.Marshal(dcMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(output.String(), "\n"+string(buf)) {
+		t.Errorf("Expected exported manifest to round-trip, got:\n%s\nand:\n%s", output.String(), buf)
+	}
+}
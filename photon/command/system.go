@@ -10,23 +10,26 @@
 package command
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
-	"net"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
 	"github.com/vmware/photon-controller-cli/photon/manifest"
 	"github.com/vmware/photon-controller-cli/photon/utils"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon"
+	"gopkg.in/yaml.v2"
 	"sort"
 )
 
@@ -63,6 +66,13 @@ func GetSystemCommand() cli.Command {
 				Name:      "add-hosts",
 				Usage:     "Add multiple hosts",
 				ArgsUsage: "<host-file>",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: defaultHostParallelism,
+						Usage: "Number of hosts to add at the same time",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := addHosts(c)
 					if err != nil {
@@ -70,12 +80,62 @@ func GetSystemCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "validate-manifest",
+				Usage:     "Validate a host file",
+				ArgsUsage: "<host-file>",
+				Description: "Check a host file for unknown keys, bad addresses, overlapping hosts,\n" +
+					"   missing credentials and inconsistent deployment settings.\n" +
+					"   The same checks are run by add-hosts before it adds any host.",
+				Action: func(c *cli.Context) {
+					err := validateManifest(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "apply-manifest",
+				Usage:     "Configure the system and add the hosts of a host file",
+				ArgsUsage: "<host-file>",
+				Description: "Configure the image datastores, NSX and security groups from the deployment\n" +
+					"   section of a host file, then add its hosts.\n" +
+					"   Settings that already match the system are left unchanged.",
+				Action: func(c *cli.Context) {
+					err := applyManifest(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "export-manifest",
+				Usage:     "Write a host file describing the system",
+				ArgsUsage: " ",
+				Description: "Write a host file with the hosts and the deployment configuration of the\n" +
+					"   system, which add-hosts or apply-manifest can use to recreate it.\n" +
+					"   Passwords are not reported by the system and are written as placeholders,\n" +
+					"   which must be replaced before the file is used.",
+				Action: func(c *cli.Context) {
+					err := exportManifest(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Hidden:      true,
 				Name:        "addHosts",
 				Usage:       "Add multiple hosts",
 				ArgsUsage:   "<host-file>",
 				Description: "Deprecated, use add-hosts instead",
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "parallel",
+						Value: defaultHostParallelism,
+						Usage: "Number of hosts to add at the same time",
+					},
+				},
 				Action: func(c *cli.Context) {
 					err := addHosts(c)
 					if err != nil {
@@ -174,7 +234,8 @@ func GetSystemCommand() cli.Command {
 				ArgsUsage: " ",
 				Description: "Configure NSX for the deployment. This is a one-time operatino and may not be repeated\n" +
 					"If you deploy Photon Controller with the installer, you should not need to run this command.\n" +
-					"If you deploy Photon Controller with ovftool, you probably need to run this command.",
+					"If you deploy Photon Controller with ovftool, you probably need to run this command.\n" +
+					"The configuration can also be given in a file, e.g. photon system configure-nsx --spec-file nsx.yaml",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "nsx-address",
@@ -228,6 +289,7 @@ func GetSystemCommand() cli.Command {
 						Name:  "dns-server-addresses",
 						Usage: "Comma-separated list of DNS server addresses",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := configureNSX(c)
@@ -357,15 +419,9 @@ func showSystemInfo(c *cli.Context, w io.Writer) error {
 			if err != nil {
 				return err
 			}
-			ipAddr := "N/A"
-			for _, nt := range networks {
-				network := nt.(map[string]interface{})
-				if len(network) != 0 && network["network"] != nil {
-					if val, ok := network["ipAddress"]; ok && val != nil {
-						ipAddr = val.(string)
-						break
-					}
-				}
+			ipAddr := primaryIP(networks, "")
+			if len(ipAddr) == 0 {
+				ipAddr = "N/A"
 			}
 			data = append(data, VM_NetworkIPs{vm, ipAddr})
 		}
@@ -613,18 +669,24 @@ func configureNSX(c *cli.Context) error {
 		return err
 	}
 
-	nsxAddress := c.String("nsx-address")
-	nsxUsername := c.String("nsx-username")
-	nsxPassword := c.String("nsx-password")
-	floatingIpRootRangeStart := c.String("floating-ip-root-range-start")
-	floatingIpRootRangeEnd := c.String("floating-ip-root-range-end")
-	t0RouterId := c.String("t0-router-id")
-	edgeClusterId := c.String("edge-cluster-id")
-	overlayTransportZoneId := c.String("overlay-transport-zone-id")
-	tunnelIpPoolId := c.String("tunnel-ip-pool-id")
-	hostUplinkPnic := c.String("host-uplink-pnic")
-	hostUplinkVlanId := c.Int("host-uplink-vlan-id")
-	dnsServerAddresses := c.String("dns-server-addresses")
+	spec := photon.NsxConfigurationSpec{}
+	err = readSpecFileFlag(c, &spec)
+	if err != nil {
+		return err
+	}
+
+	nsxAddress := stringOrSpec(c.String("nsx-address"), spec.NsxAddress)
+	nsxUsername := stringOrSpec(c.String("nsx-username"), spec.NsxUsername)
+	nsxPassword := stringOrSpec(c.String("nsx-password"), spec.NsxPassword)
+	floatingIpRootRangeStart := stringOrSpec(c.String("floating-ip-root-range-start"), spec.FloatingIpRootRange.Start)
+	floatingIpRootRangeEnd := stringOrSpec(c.String("floating-ip-root-range-end"), spec.FloatingIpRootRange.End)
+	t0RouterId := stringOrSpec(c.String("t0-router-id"), spec.T0RouterId)
+	edgeClusterId := stringOrSpec(c.String("edge-cluster-id"), spec.EdgeClusterId)
+	overlayTransportZoneId := stringOrSpec(c.String("overlay-transport-zone-id"), spec.OverlayTransportZoneId)
+	tunnelIpPoolId := stringOrSpec(c.String("tunnel-ip-pool-id"), spec.TunnelIpPoolId)
+	hostUplinkPnic := stringOrSpec(c.String("host-uplink-pnic"), spec.HostUplinkPnic)
+	hostUplinkVlanId := intOrSpec(c.Int("host-uplink-vlan-id"), spec.HostUplinkVlanId)
+	dnsServerAddresses := stringOrSpec(c.String("dns-server-addresses"), strings.Join(spec.DnsServerAddresses, ","))
 
 	if len(nsxAddress) == 0 {
 		return fmt.Errorf("Please provide IP address of NSX")
@@ -660,9 +722,13 @@ func configureNSX(c *cli.Context) error {
 		return fmt.Errorf("Please provide list of the DNS server addresses")
 	}
 
-	dnsServerAddressList := []string{}
-	if dnsServerAddresses != "" {
-		dnsServerAddressList = regexp.MustCompile(`\s*,\s*`).Split(dnsServerAddresses, -1)
+	floatingIpRootRange, err := ipaddr.ParseRange(floatingIpRootRangeStart + "-" + floatingIpRootRangeEnd)
+	if err != nil {
+		return fmt.Errorf("Invalid floating IP root range: %s", err)
+	}
+	dnsServerAddressList, err := parseIpListFromFlag("dns-server-addresses", dnsServerAddresses)
+	if err != nil {
+		return err
 	}
 
 	if confirmed(c) {
@@ -670,7 +736,7 @@ func configureNSX(c *cli.Context) error {
 			NsxAddress:             nsxAddress,
 			NsxUsername:            nsxUsername,
 			NsxPassword:            nsxPassword,
-			FloatingIpRootRange:    photon.IpRange{Start: floatingIpRootRangeStart, End: floatingIpRootRangeEnd},
+			FloatingIpRootRange:    photon.IpRange{Start: floatingIpRootRange.Start.String(), End: floatingIpRootRange.End.String()},
 			T0RouterId:             t0RouterId,
 			EdgeClusterId:          edgeClusterId,
 			OverlayTransportZoneId: overlayTransportZoneId,
@@ -678,6 +744,7 @@ func configureNSX(c *cli.Context) error {
 			HostUplinkPnic:         hostUplinkPnic,
 			HostUplinkVlanId:       hostUplinkVlanId,
 			DnsServerAddresses:     dnsServerAddressList,
+			DhcpServerAddresses:    spec.DhcpServerAddresses,
 		}
 
 		task, err := client.Photonclient.System.ConfigureNsx(nsxConfigSpec)
@@ -819,18 +886,23 @@ func printStatus(status *photon.Status) error {
 
 // Add most hosts in batch mode
 func addHosts(c *cli.Context) error {
-	err := checkArgCount(c, 0)
+	err := checkArgCount(c, 1)
 	if err != nil {
 		return err
 	}
 	file := c.Args().First()
-	dcMap, err := manifest.LoadInstallation(file)
+	dcMap, err := manifest.ValidateInstallation(file)
+	if err != nil {
+		return err
+	}
+
+	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
 	}
 
 	// Create Hosts
-	err = createHostsInBatch(dcMap)
+	err = createHostsInBatch(dcMap, file, c.Int("parallel"), os.Stdout, !c.GlobalIsSet("non-interactive"))
 	if err != nil {
 		return err
 	}
@@ -838,6 +910,24 @@ func addHosts(c *cli.Context) error {
 	return nil
 }
 
+// Check a host file without adding any host
+func validateManifest(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	file := c.Args().First()
+	_, err = manifest.ValidateInstallation(file)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Fprintf(w, "Host file '%s' is valid\n", file)
+	}
+	return nil
+}
+
 func contains(list []string, value string) bool {
 	for _, item := range list {
 		if item == value {
@@ -847,8 +937,59 @@ func contains(list []string, value string) bool {
 	return false
 }
 
+// Number of hosts added at the same time by default
+const defaultHostParallelism = 4
+
+// Most addresses the address ranges of a host file entry can expand to
+const maxHostAddresses = 4096
+
+// Delay between two polls of a host creation task
+var hostTaskPollDelay = 500 * time.Millisecond
+
+// Results of adding the hosts of a host file. It is saved next to the host
+// file when some hosts fail, so that running add-hosts again only retries them.
+type addHostsState struct {
+	Hosts map[string]hostResult `yaml:"hosts"`
+}
+
+type hostResult struct {
+	Status string `yaml:"status"`
+	ID     string `yaml:"id,omitempty"`
+	Error  string `yaml:"error,omitempty"`
+}
+
+func loadAddHostsState(path string) (*addHostsState, error) {
+	state := &addHostsState{}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		state.Hosts = map[string]hostResult{}
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(buf, state)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading state file '%s': %s", path, err)
+	}
+	if state.Hosts == nil {
+		state.Hosts = map[string]hostResult{}
+	}
+	return state, nil
+}
+
+// Returns the IDs of the availability zones of the hosts by name, creating
+// those that do not exist yet.
 func createZonesFromDcMap(dcMap *manifest.Installation) (map[string]string, error) {
+	zones, err := client.Photonclient.Zones.GetAll()
+	if err != nil {
+		return nil, err
+	}
 	zoneNameToIdMap := make(map[string]string)
+	for _, zone := range zones.Items {
+		zoneNameToIdMap[zone.Name] = zone.ID
+	}
+
 	for _, host := range dcMap.Hosts {
 		if len(host.AvailabilityZone) > 0 {
 			if _, present := zoneNameToIdMap[host.AvailabilityZone]; !present {
@@ -861,7 +1002,7 @@ func createZonesFromDcMap(dcMap *manifest.Installation) (map[string]string, erro
 					return nil, err
 				}
 
-				task, err := pollTask(createZoneTask.ID)
+				task, err := client.Photonclient.Tasks.Wait(createZoneTask.ID)
 				if err != nil {
 					return nil, err
 				}
@@ -872,44 +1013,202 @@ func createZonesFromDcMap(dcMap *manifest.Installation) (map[string]string, erro
 	return zoneNameToIdMap, nil
 }
 
-func createHostsInBatch(dcMap *manifest.Installation) error {
-	hostSpecs, err := createHostSpecs(dcMap)
+// Adds the hosts of the host file that do not exist yet, at most parallel at
+// a time, and returns an error if any of them fails. Hosts are skipped when a
+// host with the same address exists; the state file of a previous run is only
+// used to report which of them that run created.
+func createHostsInBatch(dcMap *manifest.Installation, file string, parallel int, w io.Writer, interactive bool) error {
+	if len(dcMap.Hosts) == 0 {
+		return nil
+	}
+	stateFile := file + ".state"
+	state, err := loadAddHostsState(stateFile)
+	if err != nil {
+		return err
+	}
+	zoneNameToIdMap, err := createZonesFromDcMap(dcMap)
+	if err != nil {
+		return err
+	}
+	hostSpecs, err := createHostSpecs(dcMap, zoneNameToIdMap)
+	if err != nil {
+		return err
+	}
+	hosts, err := client.Photonclient.InfraHosts.GetHosts()
 	if err != nil {
 		return err
 	}
+	existing := make(map[string]string)
+	for _, host := range hosts.Items {
+		existing[host.Address] = host.ID
+	}
 
-	createTaskMap := make(map[string]*photon.Task)
-	var creationErrors []error
-	var pollErrors []error
+	table := newHostStatusTable(w, interactive, hostSpecs)
+	var pending []photon.HostCreateSpec
+	present := 0
 	for _, spec := range hostSpecs {
-		createHostTask, err := client.Photonclient.InfraHosts.Create(&spec)
-		if err != nil {
-			creationErrors = append(creationErrors, err)
-			fmt.Printf("Creation of Host document with ip '%s' failed: with err '%s'\n",
-				spec.Address, err)
+		id, ok := existing[spec.Address]
+		if !ok {
+			pending = append(pending, spec)
+			continue
+		}
+		if result := state.Hosts[spec.Address]; result.Status == "created" && result.ID == id {
+			table.update(spec.Address, "created in a previous run: "+id, true)
 		} else {
-			createTaskMap[spec.Address] = createHostTask
+			table.update(spec.Address, "exists: "+id, true)
+		}
+		state.Hosts[spec.Address] = hostResult{Status: "created", ID: id}
+		present++
+	}
+
+	if parallel <= 0 {
+		parallel = defaultHostParallelism
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for i := range pending {
+		wg.Add(1)
+		go func(spec *photon.HostCreateSpec) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result := createHostFromSpec(spec, table)
+			mu.Lock()
+			state.Hosts[spec.Address] = result
+			mu.Unlock()
+		}(&pending[i])
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, spec := range pending {
+		if state.Hosts[spec.Address].Status != "created" {
+			failed = append(failed, spec.Address)
 		}
 	}
-
-	for address, createTask := range createTaskMap {
-		task, err := pollTask(createTask.ID)
+	fmt.Fprintf(w, "\nHosts added: %d, already present: %d, failed: %d\n",
+		len(pending)-len(failed), present, len(failed))
+	if len(failed) != 0 {
+		buf, err := yaml.Marshal(state)
 		if err != nil {
-			pollErrors = append(pollErrors, err)
-			fmt.Printf("Creation of Host with ip '%s' failed: ID = %s with err '%s'\n\n",
-				address, task.ID, err)
-		} else {
-			fmt.Printf("Host with ip '%s' created: ID = %s\n\n", address, task.Entity.ID)
+			return err
+		}
+		err = ioutil.WriteFile(stateFile, buf, 0644)
+		if err != nil {
+			return err
 		}
+		return fmt.Errorf("Failed to add %d of %d hosts: %s\n"+
+			"Run add-hosts again to retry them, the results are saved in '%s'",
+			len(failed), len(hostSpecs), strings.Join(failed, ", "), stateFile)
 	}
-	return nil
+	err = os.Remove(stateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return table.err
 }
 
-func createHostSpecs(dcMap *manifest.Installation) ([]photon.HostCreateSpec, error) {
-	zoneNameToIdMap, err := createZonesFromDcMap(dcMap)
+// Creates the host and waits for it to be provisioned, reporting progress in the table.
+func createHostFromSpec(spec *photon.HostCreateSpec, table *hostStatusTable) hostResult {
+	table.update(spec.Address, "creating", false)
+	task, err := client.Photonclient.InfraHosts.Create(spec)
+	if task != nil {
+		task, err = waitForHostTask(task, func(status string) {
+			table.update(spec.Address, status, false)
+		})
+	}
 	if err != nil {
-		return nil, err
+		table.update(spec.Address, "failed: "+err.Error(), true)
+		return hostResult{Status: "failed", Error: err.Error()}
+	}
+	table.update(spec.Address, "created: "+task.Entity.ID, true)
+	return hostResult{Status: "created", ID: task.Entity.ID}
+}
+
+// Polls the task until it completes. Unlike pollTask, it does not animate
+// the progress, so it can be used for several tasks at the same time.
+func waitForHostTask(task *photon.Task, progress func(string)) (*photon.Task, error) {
+	start := time.Now()
+	for time.Since(start) < 30*time.Minute {
+		switch task.State {
+		case "COMPLETED":
+			return task, nil
+		case "ERROR":
+			apiErrors := getTaskAPIErrorList(task)
+			if len(apiErrors) != 0 {
+				return task, errors.New(apiErrors[0].Message)
+			}
+			return task, fmt.Errorf("Task '%s' failed", task.ID)
+		}
+		if step := findStartedStep(task); step != nil {
+			progress(fmt.Sprintf("%s %d/%d", step.Operation, step.Sequence+1, len(task.Steps)))
+		}
+
+		time.Sleep(hostTaskPollDelay)
+		var err error
+		task, err = client.Photonclient.Tasks.Get(task.ID)
+		if err != nil && (task == nil || task.State != "ERROR") {
+			return task, err
+		}
 	}
+	return task, photon.TaskTimeoutError{ID: task.ID}
+}
+
+// Status of each host being added. In interactive mode the table is redrawn
+// in place as statuses change, otherwise the final status of each host is
+// printed on its own line.
+type hostStatusTable struct {
+	w           io.Writer
+	interactive bool
+	addresses   []string
+
+	mu     sync.Mutex
+	status map[string]string
+	drawn  bool
+	// First error writing the table, updates are not drawn after it.
+	err error
+}
+
+func newHostStatusTable(w io.Writer, interactive bool, hostSpecs []photon.HostCreateSpec) *hostStatusTable {
+	table := &hostStatusTable{w: w, interactive: interactive, status: map[string]string{}}
+	for _, spec := range hostSpecs {
+		table.addresses = append(table.addresses, spec.Address)
+		table.status[spec.Address] = "pending"
+	}
+	return table
+}
+
+func (t *hostStatusTable) update(address string, status string, final bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status[address] = status
+	if t.err != nil {
+		return
+	}
+	if !t.interactive {
+		if final {
+			fmt.Fprintf(t.w, "%s\t%s\n", address, status)
+		}
+		return
+	}
+
+	if t.drawn {
+		// Move the cursor back to the first line of the table.
+		fmt.Fprintf(t.w, "\033[%dA", len(t.addresses)+1)
+	}
+	t.drawn = true
+	w := new(tabwriter.Writer)
+	w.Init(t.w, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Host\tStatus\033[K\n")
+	for _, address := range t.addresses {
+		fmt.Fprintf(w, "%s\t%s\033[K\n", address, t.status[address])
+	}
+	t.err = w.Flush()
+}
+
+func createHostSpecs(dcMap *manifest.Installation, zoneNameToIdMap map[string]string) ([]photon.HostCreateSpec, error) {
 	var hostSpecs []photon.HostCreateSpec
 	var managementNetworkIps []string
 	for _, host := range dcMap.Hosts {
@@ -961,40 +1260,21 @@ func createHostSpecs(dcMap *manifest.Installation) ([]photon.HostCreateSpec, err
 }
 
 func parseIpRanges(ipRanges string) ([]string, error) {
+	list, err := ipaddr.Parse(ipRanges)
+	if err != nil {
+		return nil, fmt.Errorf("Bad address range defined in DC Map: %s", err)
+	}
+	ips, err := list.Addresses(maxHostAddresses)
+	if err != nil {
+		return nil, fmt.Errorf("Bad address range defined in DC Map: %s", err)
+	}
 	var ipList []string
-	for _, ipRange := range regexp.MustCompile(`\s*,\s*`).Split(ipRanges, -1) {
-		ips := regexp.MustCompile(`\s*-\s*`).Split(ipRange, -1)
-		if len(ips) == 1 {
-			ip := net.ParseIP(ips[0]).To4()
-			if ip == nil {
-				return nil, errors.New("Bad IP Address defined in DC Map")
-			}
-			ipList = append(ipList, ips[0])
-		} else if len(ips) == 2 {
-			ip0 := net.ParseIP(ips[0]).To4()
-			ip1 := net.ParseIP(ips[1]).To4()
-			if ip0 == nil || ip1 == nil {
-				return nil, errors.New("Bad IP Address defined in DC Map")
-			}
-			for ip := ip0; bytes.Compare(ip, ip1) <= 0; inc(ip) {
-				ipList = append(ipList, ip.String())
-			}
-		} else {
-			return nil, errors.New("Bad Address Range defined in DC Map")
-		}
+	for _, ip := range ips {
+		ipList = append(ipList, ip.String())
 	}
 	return ipList, nil
 }
 
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
-
 func systemInfoJsonHelper(c *cli.Context, client *photon.Client) error {
 	if utils.NeedsFormatting(c) {
 		deployment, err := client.System.GetSystemInfo()
@@ -10,15 +10,21 @@
 package command
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
 	cf "github.com/vmware/photon-controller-cli/photon/configuration"
 	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon"
@@ -443,15 +449,15 @@ func TestConfigureNSX(t *testing.T) {
 	set.String("dhcp-server-private-address", "dhcpServerPrivateAddress", "Private IP address of DHCP server")
 	set.String("dhcp-server-public-address", "dhcpServerPublicAddress", "Public IP address of DHCP server")
 	set.String("private-ip-root-cidr", "privateIpRootCidr", "Root CIDR of the private IP pool")
-	set.String("floating-ip-root-range-start", "floatingIpRootRangeStart",
+	set.String("floating-ip-root-range-start", "192.168.10.1",
 		"Start of the root range of the floating IP pool")
-	set.String("floating-ip-root-range-end", "floatingIpRootRangeEnd", "End of the root range of the floating IP pool")
+	set.String("floating-ip-root-range-end", "192.168.10.254", "End of the root range of the floating IP pool")
 	set.String("t0-router-id", "t0RouterId", "ID of the T0-Router")
 	set.String("edge-cluster-id", "edgeClusterId", "ID of the Edge cluster")
 	set.String("overlay-transport-zone-id", "overlayTransportZoneId", "ID of the OVERLAY transport zone")
 	set.String("tunnel-ip-pool-id", "tunnelIpPoolId", "ID of the tunnel IP pool")
 	set.String("host-uplink-pnic", "hostUplinkPnic", "Name of the host uplink pnic")
-	set.String("dns-server-addresses", "dnsServerAddress1,dnsServerAddress2", "Comma separated DNS server "+
+	set.String("dns-server-addresses", "10.0.0.2,10.0.0.3", "Comma separated DNS server "+
 		"addresses")
 
 	cxt := cli.NewContext(nil, set, globalCtx)
@@ -580,3 +586,169 @@ func TestDisableSystemServiceType(t *testing.T) {
 		t.Error("Not expecting pauseBackgroundTasks to fail")
 	}
 }
+
+func TestAddHostsFromCassette(t *testing.T) {
+	recorder, endpoint, err := mocks.NewCassette(t, "../../testdata/cassettes/system-add-hosts.yaml")
+	if err != nil {
+		t.Fatal("Not expecting error loading cassette: " + err.Error())
+	}
+	client.Photonclient = photon.NewTestClient(endpoint, nil, &http.Client{Transport: recorder})
+	if !recorder.Recording() {
+		defer func(delay time.Duration) { hostTaskPollDelay = delay }(hostTaskPollDelay)
+		hostTaskPollDelay = time.Millisecond
+	}
+
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{"../../testdata/add-hosts.yml"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	err = addHosts(cxt)
+	if err != nil {
+		t.Error("Not expecting error adding hosts: " + err.Error())
+	}
+	err = recorder.Stop()
+	if err != nil {
+		t.Error("Not expecting error stopping recorder: " + err.Error())
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	err := set.Parse([]string{"../../testdata/add-hosts.yml"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, nil)
+
+	var output bytes.Buffer
+	err = validateManifest(cxt, &output)
+	if err != nil {
+		t.Error("Not expecting error validating manifest: " + err.Error())
+	}
+	if !strings.Contains(output.String(), "is valid") {
+		t.Errorf("Expected manifest to be reported valid, got '%s'", output.String())
+	}
+
+	file, err := ioutil.TempFile("", "add-hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+	_, err = file.WriteString("hosts:\n  - address_ranges: 10.0.0.1-10.0.0.x\n    username: root\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{file.Name()})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt = cli.NewContext(nil, set, nil)
+
+	err = validateManifest(cxt, &output)
+	if err == nil || !strings.Contains(err.Error(), "line 2, column 21: hosts[0].address_ranges: bad IP address '10.0.0.x'") {
+		t.Errorf("Expected bad address to be reported, got %v", err)
+	}
+
+	// add-hosts must not send any request for an invalid manifest.
+	client.Photonclient = nil
+	err = addHosts(cxt)
+	if err == nil || !strings.Contains(err.Error(), "password is required") {
+		t.Errorf("Expected add-hosts to fail validation, got %v", err)
+	}
+}
+
+func TestAddHostsRetriesFailures(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+	defer func(delay time.Duration) { hostTaskPollDelay = delay }(hostTaskPollDelay)
+	hostTaskPollDelay = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "add-hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	manifest, err := ioutil.ReadFile("../../testdata/add-hosts.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "add-hosts.yml")
+	err = ioutil.WriteFile(file, manifest, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{file})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	cxt := cli.NewContext(nil, set, globalCtx)
+
+	sim.InjectFault(simulator.Fault{
+		Method:   "POST",
+		Path:     "/infrastructure/hosts",
+		Error:    photon.ApiError{Code: "HostUnreachable", Message: "host is unreachable"},
+		FailTask: true,
+		Times:    1,
+	})
+	err = addHosts(cxt)
+	if err == nil || !strings.Contains(err.Error(), "Failed to add 1 of 2 hosts") {
+		t.Errorf("Expected one host to fail, got %v", err)
+	}
+	state, err := loadAddHostsState(file + ".state")
+	if err != nil || len(state.Hosts) != 2 {
+		t.Fatalf("Expected the results of both hosts to be saved, got %v %v", state, err)
+	}
+
+	// A host recorded as created that was deleted since is added again,
+	// the zone is not created again.
+	for _, result := range state.Hosts {
+		if result.Status != "created" {
+			continue
+		}
+		task, err := client.Photonclient.InfraHosts.Delete(result.ID)
+		if err == nil {
+			_, err = client.Photonclient.Tasks.Wait(task.ID)
+		}
+		if err != nil {
+			t.Fatal("Not expecting error deleting host: " + err.Error())
+		}
+	}
+	err = addHosts(cxt)
+	if err != nil {
+		t.Error("Not expecting error retrying failed host: " + err.Error())
+	}
+	hosts, err := client.Photonclient.InfraHosts.GetHosts()
+	if err != nil || len(hosts.Items) != 2 {
+		t.Errorf("Expected 2 hosts, got %v %v", hosts, err)
+	}
+	zones, err := client.Photonclient.Zones.GetAll()
+	if err != nil || len(zones.Items) != 1 {
+		t.Errorf("Expected 1 zone, got %v %v", zones, err)
+	}
+	if _, err = os.Stat(file + ".state"); !os.IsNotExist(err) {
+		t.Errorf("Expected state file to be removed, got %v", err)
+	}
+}
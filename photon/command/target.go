@@ -19,6 +19,7 @@ import (
 	"net/url"
 	"os"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon/lightwave"
@@ -36,6 +37,10 @@ import (
 //              login;  Usage: target login <token>
 //              logout; Usage: target logout
 //              show;   Usage: target show
+//              save-profile;  Usage: target save-profile <name>
+//              use-profile;   Usage: target use-profile <name>
+//              list-profiles; Usage: target list-profiles
+//              delete-profile; Usage: target delete-profile <name>
 func GetTargetCommand() cli.Command {
 	command := cli.Command{
 		Name:  "target",
@@ -131,6 +136,56 @@ func GetTargetCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "save-profile",
+				Usage:     "Save the current target, token, tenant and project as a named profile",
+				ArgsUsage: "<profile-name>",
+				Description: "Save the current configuration under a name so that commands which work with\n" +
+					"   more than one deployment, like 'photon migrate', can refer to it.\n\n" +
+					"   Example:\n" +
+					"      photon target set https://old-deployment:443\n" +
+					"      photon target login --username admin@photon.local\n" +
+					"      photon target save-profile old",
+				Action: func(c *cli.Context) {
+					err := saveProfile(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "use-profile",
+				Usage:     "Make a saved profile the current target",
+				ArgsUsage: "<profile-name>",
+				Action: func(c *cli.Context) {
+					err := useProfile(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "list-profiles",
+				Usage:     "List saved profiles",
+				ArgsUsage: " ",
+				Action: func(c *cli.Context) {
+					err := listProfiles(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "delete-profile",
+				Usage:     "Delete a saved profile",
+				ArgsUsage: "<profile-name>",
+				Action: func(c *cli.Context) {
+					err := deleteProfile(c)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 		},
 	}
 	return command
@@ -347,6 +402,111 @@ func logout(c *cli.Context) error {
 	return nil
 }
 
+// Saves the current configuration as a named profile
+func saveProfile(c *cli.Context) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	name := c.Args().First()
+
+	config, err := cf.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(config.CloudTarget) == 0 {
+		return fmt.Errorf("No API target set, set one with 'target set' before saving a profile")
+	}
+
+	err = cf.SaveProfile(name, config)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Printf("Profile '%s' saved for target '%s'\n", name, config.CloudTarget)
+	}
+	return nil
+}
+
+// Replaces the current configuration with a saved profile
+func useProfile(c *cli.Context) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	name := c.Args().First()
+
+	config, err := cf.LoadProfile(name)
+	if err != nil {
+		return err
+	}
+
+	err = cf.SaveConfig(config)
+	if err != nil {
+		return err
+	}
+
+	if !c.GlobalIsSet("non-interactive") {
+		fmt.Printf("API target set to '%s' from profile '%s'\n", config.CloudTarget, name)
+	}
+	return nil
+}
+
+// Lists saved profiles and their targets
+func listProfiles(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 0)
+	if err != nil {
+		return err
+	}
+
+	names, err := cf.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	profiles := []map[string]string{}
+	for _, name := range names {
+		config, err := cf.LoadProfile(name)
+		if err != nil {
+			return err
+		}
+		profiles = append(profiles, map[string]string{"name": name, "target": config.CloudTarget})
+	}
+
+	if c.GlobalIsSet("non-interactive") {
+		for _, p := range profiles {
+			fmt.Printf("%s\t%s\n", p["name"], p["target"])
+		}
+	} else if utils.NeedsFormatting(c) {
+		utils.FormatObjects(profiles, w, c)
+	} else {
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Name\tTarget\n")
+		for _, p := range profiles {
+			fmt.Fprintf(w, "%s\t%s\n", p["name"], p["target"])
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nTotal: %d\n", len(profiles))
+	}
+	return nil
+}
+
+// Deletes a saved profile
+func deleteProfile(c *cli.Context) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+
+	return cf.RemoveProfile(c.Args().First())
+}
+
 func configureServerCerts(endpoint string, noChertCheck bool, c *cli.Context) (err error) {
 	if noChertCheck {
 		return
@@ -72,7 +72,9 @@ func GetTenantsCommand() cli.Command {
 					"                    persistent-disk.cost 1000 GB,\n" +
 					"                    storage.LOCAL_VMFS 1000 COUNT,\n" +
 					"                    storage.VSAN 1000 COUNT,\n" +
-					"                    sdn.floatingip.size 1000 COUNT'",
+					"                    sdn.floatingip.size 1000 COUNT'\n" +
+					"     create a tenant from a spec file:\n" +
+					"        photon tenant create --spec-file tenant1.yaml",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "security-groups, s",
@@ -82,6 +84,7 @@ func GetTenantsCommand() cli.Command {
 						Name:  "limits, l",
 						Usage: "Tenant limits (key value unit)",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createTenant(c, os.Stdout)
@@ -280,8 +283,15 @@ func createTenant(c *cli.Context, w io.Writer) error {
 	securityGroups := c.String("security-groups")
 	limits := c.String("limits")
 
+	spec := photon.TenantCreateSpec{}
+	err := readSpecFileFlag(c, &spec)
+	if err != nil {
+		return err
+	}
+	name = stringOrSpec(name, spec.Name)
+	securityGroups = stringOrSpec(securityGroups, strings.Join(spec.SecurityGroups, ","))
+
 	if !c.GlobalIsSet("non-interactive") {
-		var err error
 		name, err = askForInput("Tenant name: ", name)
 		if err != nil {
 			return err
@@ -303,7 +313,7 @@ func createTenant(c *cli.Context, w io.Writer) error {
 	}
 
 	// Get project quota if present
-	quota := photon.Quota{}
+	quota := spec.ResourceQuota
 	if c.IsSet("limits") {
 		limitsList, err := parseLimitsListFromFlag(limits)
 		if err != nil {
@@ -320,7 +330,6 @@ func createTenant(c *cli.Context, w io.Writer) error {
 		ResourceQuota:  quota,
 	}
 
-	var err error
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
@@ -0,0 +1,279 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// Delay between two progress reports of an upload
+const uploadReportInterval = 500 * time.Millisecond
+
+// Flag limiting the rate of image and ISO uploads
+var limitRateFlag = cli.StringFlag{
+	Name:  "limit-rate",
+	Usage: "Maximum upload rate in bytes per second, with an optional K, M or G suffix, e.g.: 10M",
+}
+
+var ratePattern = regexp.MustCompile(`^(\d+)([KMG]?)$`)
+
+// Reads a file being uploaded, computing its SHA-256 checksum, limiting the rate
+// of the upload and reporting its progress.
+type uploadReader struct {
+	file     io.ReadSeeker
+	name     string
+	size     int64
+	limit    int64
+	progress io.Writer
+
+	sent       int64
+	hash       hash.Hash
+	start      time.Time
+	lastReport time.Time
+}
+
+// Stream that can be read again from its start, such as a file or a download.
+type uploadSource struct {
+	// Size of the stream, or -1 if it is not known in advance.
+	size int64
+	open func() (io.ReadCloser, error)
+
+	// Stream opened to find the size, returned by the first call to open.
+	first io.ReadCloser
+}
+
+// Reads a source, opening it again when rewound.
+type sourceReader struct {
+	source *uploadSource
+	stream io.ReadCloser
+}
+
+// Opens a file to upload. Progress is reported to stderr unless the CLI is
+// non-interactive, and the rate is limited by --limit-rate.
+func openUpload(path, name string, c *cli.Context) (*uploadReader, error) {
+	source, err := fileSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return newUpload(source, name, c)
+}
+
+func newUpload(source *uploadSource, name string, c *cli.Context) (*uploadReader, error) {
+	limit, err := parseRate(c.String("limit-rate"))
+	if err != nil {
+		return nil, err
+	}
+	var progress io.Writer
+	if !c.GlobalIsSet("non-interactive") {
+		progress = os.Stderr
+	}
+	return newUploadReader(&sourceReader{source: source}, name, source.size, limit, progress), nil
+}
+
+func fileSource(path string) (*uploadSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadSource{
+		size: info.Size(),
+		open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}, nil
+}
+
+func (r *sourceReader) Read(p []byte) (int, error) {
+	if r.stream == nil {
+		if r.source.first != nil {
+			r.stream, r.source.first = r.source.first, nil
+		} else {
+			stream, err := r.source.open()
+			if err != nil {
+				return 0, err
+			}
+			r.stream = stream
+		}
+	}
+	return r.stream.Read(p)
+}
+
+func (r *sourceReader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, fmt.Errorf("Uploads can only be rewound to their start")
+	}
+	err := r.Close()
+	return 0, err
+}
+
+func (r *sourceReader) Close() error {
+	if first := r.source.first; first != nil {
+		r.source.first = nil
+		_ = first.Close()
+	}
+	if r.stream == nil {
+		return nil
+	}
+	err := r.stream.Close()
+	r.stream = nil
+	return err
+}
+
+func newUploadReader(file io.ReadSeeker, name string, size, limit int64, progress io.Writer) *uploadReader {
+	return &uploadReader{
+		file:     file,
+		name:     name,
+		size:     size,
+		limit:    limit,
+		progress: progress,
+		hash:     sha256.New(),
+	}
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	// Read at most a tenth of a second worth of data so that throttling is smooth.
+	if chunk := r.limit / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.file.Read(p)
+	r.hash.Write(p[:n])
+	r.sent += int64(n)
+	if err == io.EOF && r.size < 0 {
+		r.size = r.sent
+	}
+
+	if r.limit > 0 {
+		expected := time.Duration(float64(r.sent) / float64(r.limit) * float64(time.Second))
+		if elapsed := time.Since(r.start); elapsed < expected {
+			time.Sleep(expected - elapsed)
+		}
+	}
+	if err == io.EOF || time.Since(r.lastReport) >= uploadReportInterval {
+		r.report(err == io.EOF)
+	}
+	return n, err
+}
+
+// Seeks the file. The SDK rewinds uploads to retry them, in which case the
+// checksum and progress start over.
+func (r *uploadReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.file.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	if pos != 0 {
+		return pos, fmt.Errorf("Upload of %s can only be rewound to its start", r.name)
+	}
+	r.sent = 0
+	r.hash.Reset()
+	r.start = time.Time{}
+	return pos, nil
+}
+
+func (r *uploadReader) Close() error {
+	if closer, ok := r.file.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Returns the hex SHA-256 checksum of the file, or "" if it was not read to the end.
+func (r *uploadReader) Checksum() string {
+	if r.sent != r.size {
+		return ""
+	}
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// Prints the progress of the upload on a single line, e.g.
+// "Uploading disk.vmdk: 1.2 GiB / 4.0 GiB (30%), 12.5 MiB/s, ETA 3m50s".
+func (r *uploadReader) report(done bool) {
+	r.lastReport = time.Now()
+	if r.progress == nil {
+		return
+	}
+	elapsed := time.Since(r.start).Seconds()
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(r.sent) / elapsed
+	}
+	if r.size < 0 {
+		fmt.Fprintf(r.progress, "\rUploading %s: %s, %s/s\033[K", r.name, formatBytes(r.sent), formatBytes(int64(rate)))
+	} else {
+		percent := 100
+		if r.size > 0 {
+			percent = int(r.sent * 100 / r.size)
+		}
+		eta := "-"
+		if rate > 0 {
+			eta = (time.Duration(float64(r.size-r.sent)/rate) * time.Second).String()
+		}
+		fmt.Fprintf(r.progress, "\rUploading %s: %s / %s (%d%%), %s/s, ETA %s\033[K",
+			r.name, formatBytes(r.sent), formatBytes(r.size), percent, formatBytes(int64(rate)), eta)
+	}
+	if done {
+		fmt.Fprintf(r.progress, "\n")
+	}
+}
+
+// Prints the size and checksum of the uploaded file on stderr.
+func (r *uploadReader) printSummary() {
+	fmt.Fprintf(os.Stderr, "Uploaded %s: %d bytes, sha256 %s\n", r.name, r.sent, r.Checksum())
+}
+
+// Parses a rate such as "500K" or "10M" into bytes per second. An empty rate means no limit.
+func parseRate(rate string) (int64, error) {
+	if len(rate) == 0 {
+		return 0, nil
+	}
+	m := ratePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(rate)))
+	if m == nil {
+		return 0, fmt.Errorf("Invalid --limit-rate '%s', expected bytes per second such as 500K or 10M", rate)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("Invalid --limit-rate '%s', expected bytes per second such as 500K or 10M", rate)
+	}
+	switch m[2] {
+	case "K":
+		n <<= 10
+	case "M":
+		n <<= 20
+	case "G":
+		n <<= 30
+	}
+	return n, nil
+}
+
+// Formats a number of bytes with a binary unit, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := map[string]int64{"": 0, "512": 512, "500K": 500 << 10, "10m": 10 << 20, "1G": 1 << 30}
+	for rate, expected := range cases {
+		n, err := parseRate(rate)
+		if err != nil || n != expected {
+			t.Errorf("Expected %d for '%s', got %d (%v)", expected, rate, n, err)
+		}
+	}
+	for _, rate := range []string{"0", "10MB", "-5", "fast"} {
+		_, err := parseRate(rate)
+		if err == nil {
+			t.Errorf("Expected an error for rate '%s'", rate)
+		}
+	}
+}
+
+func TestUploadReader(t *testing.T) {
+	data := bytes.Repeat([]byte("photon"), 1000)
+	sum := sha256.Sum256(data)
+	var progress bytes.Buffer
+	r := newUploadReader(bytes.NewReader(data), "disk.vmdk", int64(len(data)), 20000, &progress)
+
+	// A partial read followed by a rewind, as when the SDK retries an upload.
+	_, err := r.Read(make([]byte, 100))
+	if err != nil {
+		t.Fatal("Not expecting error reading upload: " + err.Error())
+	}
+	if r.Checksum() != "" {
+		t.Error("Expected no checksum before the end of the upload")
+	}
+	_, err = r.Seek(0, 0)
+	if err != nil {
+		t.Fatal("Not expecting error rewinding upload: " + err.Error())
+	}
+
+	start := time.Now()
+	read, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("Expected to read the whole file, got %d bytes (%v)", len(read), err)
+	}
+	// 6000 bytes at 20000 bytes per second
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("Expected the upload to be throttled, it took %s", elapsed)
+	}
+	if r.Checksum() != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected checksum %x, got %s", sum, r.Checksum())
+	}
+	if !strings.Contains(progress.String(), "Uploading disk.vmdk: 5.9 KiB / 5.9 KiB (100%)") {
+		t.Errorf("Unexpected progress report: %q", progress.String())
+	}
+}
+
+func TestCreateImageChecksum(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	client.Photonclient = sim.NewClient()
+
+	dir, err := ioutil.TempDir("", "image-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	data := []byte("not really a disk")
+	path := filepath.Join(dir, "disk.vmdk")
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalSet.String("output", "json", "output")
+	err = globalSet.Parse([]string{"--non-interactive", "--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "disk", "name")
+	set.String("image_replication", "EAGER", "replication")
+	set.String("scope", "", "scope")
+	set.String("project", "", "project")
+	set.String("limit-rate", "1M", "limit rate")
+	err = set.Parse([]string{path})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = createImage(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error creating image: " + err.Error())
+	}
+	var image uploadedImage
+	err = json.Unmarshal(output.Bytes(), &image)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding image: %s\n%s", err, output.String())
+	}
+	sum := sha256.Sum256(data)
+	if image.Name != "disk" || image.Size != int64(len(data)) || image.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected image: %+v", image)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Creates copies of a VM with the same flavor, ephemeral disks, tags, metadata and,
+// when they are created in the project of the source VM, subnets.
+func cloneVM(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	sourceID := c.Args().First()
+	name := c.String("name")
+	count := c.Int("count")
+	tenantName := c.String("tenant")
+	projectName := c.String("project")
+
+	if !c.GlobalIsSet("non-interactive") {
+		name, err = askForInput("New VM name: ", name)
+		if err != nil {
+			return err
+		}
+	}
+	if len(name) == 0 {
+		return fmt.Errorf("Please provide the name of the new VM")
+	}
+	if count <= 0 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	tenant, err := verifyTenant(tenantName)
+	if err != nil {
+		return err
+	}
+	project, err := verifyProject(tenant.ID, projectName)
+	if err != nil {
+		return err
+	}
+
+	source, err := client.Photonclient.VMs.Get(sourceID)
+	if err != nil {
+		return err
+	}
+
+	cloner := &projectCloner{src: client.Photonclient, dst: client.Photonclient}
+	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
+		cloner.progress = os.Stdout
+	}
+
+	spec, err := cloner.vmCreateSpec(*source)
+	if err != nil {
+		return err
+	}
+	spec.Subnets, err = cloneVMSubnets(cloner, source, project.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("create-image") {
+		task, err := client.Photonclient.VMs.CreateImage(source.ID, &photon.ImageCreateSpec{
+			Name:            "image-from-vm-" + source.ID,
+			ReplicationType: c.String("image_replication"),
+		})
+		if err != nil {
+			return err
+		}
+		spec.SourceImageID, err = cloner.wait(task)
+		if err != nil {
+			return err
+		}
+		cloner.logf("Created image '%s' from the disk of VM '%s'\n", spec.SourceImageID, source.Name)
+	}
+
+	var ids []string
+	for i := 1; i <= count; i++ {
+		spec.Name = name
+		if count > 1 {
+			spec.Name = fmt.Sprintf("%s-%d", name, i)
+		}
+		task, err := client.Photonclient.Projects.CreateVM(project.ID, spec)
+		if err != nil {
+			return err
+		}
+		id, err := cloner.wait(task)
+		if err != nil {
+			return err
+		}
+		if len(source.Metadata) != 0 {
+			task, err = client.Photonclient.VMs.SetMetadata(id, &photon.VmMetadata{Metadata: source.Metadata})
+			if err != nil {
+				return err
+			}
+			_, err = cloner.wait(task)
+			if err != nil {
+				return err
+			}
+		}
+		cloner.logf("Created VM '%s' (%s)\n", spec.Name, id)
+		ids = append(ids, id)
+	}
+
+	for _, d := range source.AttachedDisks {
+		if d.Kind == "persistent-disk" {
+			cloner.skip("persistent disk '%s', it can only be attached to one VM", d.Name)
+		}
+	}
+	for _, iso := range source.AttachedISOs {
+		cloner.skip("attached ISO '%s'", iso.Name)
+	}
+	if len(source.FloatingIp) != 0 {
+		cloner.skip("floating IP %s", source.FloatingIp)
+	}
+
+	if utils.NeedsFormatting(c) {
+		var vms []photon.VM
+		for _, id := range ids {
+			vm, err := client.Photonclient.VMs.Get(id)
+			if err != nil {
+				return err
+			}
+			vms = append(vms, *vm)
+		}
+		utils.FormatObjects(vms, w, c)
+		cloner.printSkipped(os.Stderr)
+	} else if c.GlobalIsSet("non-interactive") {
+		for _, id := range ids {
+			fmt.Fprintln(w, id)
+		}
+		cloner.printSkipped(os.Stderr)
+	} else {
+		cloner.printSkipped(os.Stdout)
+	}
+	return nil
+}
+
+// Returns the subnets of the source VM when the clone is created in the same
+// project, since subnets belong to the routers of a project. Otherwise the clone
+// is attached to the default subnet of its project.
+func cloneVMSubnets(cloner *projectCloner, source *photon.VM, projectID string) ([]string, error) {
+	vms, err := client.Photonclient.Projects.GetVMs(projectID, &photon.VmGetOptions{Name: source.Name})
+	if err != nil {
+		return nil, err
+	}
+	sameProject := false
+	for _, vm := range vms.Items {
+		if vm.ID == source.ID {
+			sameProject = true
+		}
+	}
+
+	networks, err := fetchVMNetworks(source.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	var subnets []string
+	for _, network := range networks {
+		if len(network.Network) == 0 {
+			continue
+		}
+		if !sameProject {
+			cloner.skip("subnet %s, it belongs to the project of VM '%s'", network.Network, source.Name)
+			continue
+		}
+		subnets = append(subnets, network.Network)
+	}
+	return subnets, nil
+}
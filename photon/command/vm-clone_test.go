@@ -0,0 +1,131 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func runCloneVM(t *testing.T, id, project string, count int, createImage bool) []photon.VM {
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalSet.String("output", "json", "output")
+	err := globalSet.Parse([]string{"--non-interactive", "--output=json"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	set := flag.NewFlagSet("test", 0)
+	set.String("name", "web", "name")
+	set.Int("count", count, "count")
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", project, "project")
+	set.Bool("create-image", createImage, "create image")
+	set.String("image_replication", "EAGER", "replication")
+	err = set.Parse([]string{id})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = cloneVM(cli.NewContext(nil, set, cli.NewContext(nil, globalSet, nil)), &output)
+	if err != nil {
+		t.Fatal("Not expecting error cloning VM: " + err.Error())
+	}
+	var vms []photon.VM
+	err = json.Unmarshal(output.Bytes(), &vms)
+	if err != nil {
+		t.Fatalf("Not expecting error decoding VMs: %s\n%s", err, output.String())
+	}
+	return vms
+}
+
+func TestCloneVM(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "disk-small", Kind: "ephemeral-disk"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	projectIDs := map[string]string{}
+	for i, name := range []string{"project1", "project2"} {
+		task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+			Name:                       name,
+			DefaultRouterPrivateIpCidr: []string{"10.1.0.0/16", "10.2.0.0/16"}[i],
+		})
+		projectIDs[name] = waitForEntity(t, task, err)
+	}
+
+	task, err = api.Projects.CreateVM(projectIDs["project1"], &photon.VmCreateSpec{
+		Name:          "source",
+		Flavor:        "small",
+		SourceImageID: imageID,
+		Tags:          []string{"app:web"},
+		AttachedDisks: []photon.AttachedDisk{
+			{Name: "boot", Kind: "ephemeral-disk", Flavor: "disk-small", CapacityGB: 10, BootDisk: true},
+		},
+	})
+	sourceID := waitForEntity(t, task, err)
+	task, err = api.VMs.SetMetadata(sourceID, &photon.VmMetadata{Metadata: map[string]string{"role": "web"}})
+	waitForEntity(t, task, err)
+	sourceNetworks, err := fetchVMNetworks(sourceID, false)
+	if err != nil {
+		t.Fatal("Not expecting error getting VM networks: " + err.Error())
+	}
+
+	vms := runCloneVM(t, sourceID, "project1", 2, true)
+	if len(vms) != 2 || vms[0].Name != "web-1" || vms[1].Name != "web-2" {
+		t.Fatalf("Expected two clones named after --name, got %+v", vms)
+	}
+	for _, vm := range vms {
+		if vm.Flavor != "small" || vm.SourceImageID == imageID || len(vm.SourceImageID) == 0 {
+			t.Errorf("Expected clone %s to be created from a new image of the source, got %+v", vm.Name, vm)
+		}
+		if !reflect.DeepEqual(vm.Tags, []string{"app:web"}) || vm.Metadata["role"] != "web" {
+			t.Errorf("Expected clone %s to have the tags and metadata of the source, got %+v", vm.Name, vm)
+		}
+		if len(vm.AttachedDisks) != 1 || vm.AttachedDisks[0].Name != "boot" || vm.AttachedDisks[0].CapacityGB != 10 ||
+			!vm.AttachedDisks[0].BootDisk {
+			t.Errorf("Expected clone %s to have the disks of the source, got %+v", vm.Name, vm.AttachedDisks)
+		}
+		networks, err := fetchVMNetworks(vm.ID, false)
+		if err != nil {
+			t.Fatal("Not expecting error getting VM networks: " + err.Error())
+		}
+		if len(networks) != 1 || networks[0].Network != sourceNetworks[0].Network {
+			t.Errorf("Expected clone %s to be on the subnet of the source, got %+v", vm.Name, networks)
+		}
+	}
+
+	vms = runCloneVM(t, sourceID, "project2", 1, false)
+	if len(vms) != 1 || vms[0].Name != "web" || vms[0].SourceImageID != imageID {
+		t.Fatalf("Expected one clone from the image of the source, got %+v", vms)
+	}
+	networks, err := fetchVMNetworks(vms[0].ID, false)
+	if err != nil {
+		t.Fatal("Not expecting error getting VM networks: " + err.Error())
+	}
+	if len(networks) != 1 || networks[0].Network == sourceNetworks[0].Network {
+		t.Errorf("Expected the clone in another project to be on its default subnet, got %+v", networks)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/utils"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Network connection of a VM, as reported by the guest in the resource properties
+// of a GET_NETWORKS task.
+type VMNetwork struct {
+	Network     string `json:"network"`
+	MacAddress  string `json:"macAddress"`
+	IpAddress   string `json:"ipAddress"`
+	Netmask     string `json:"netmask"`
+	IsConnected string `json:"isConnected"`
+}
+
+// Delay between two polls of the networks of a VM waiting for an IP address
+var vmIpPollDelay = 2 * time.Second
+
+// Returns the network connections of a VM, showing the task progress unless the
+// CLI is non-interactive.
+func getVMNetworks(id string, c *cli.Context) ([]VMNetwork, error) {
+	return fetchVMNetworks(id, !c.GlobalIsSet("non-interactive"))
+}
+
+func fetchVMNetworks(id string, showProgress bool) ([]VMNetwork, error) {
+	task, err := client.Photonclient.VMs.GetNetworks(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if showProgress {
+		task, err = pollTask(task.ID)
+	} else {
+		task, err = client.Photonclient.Tasks.Wait(task.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseVMNetworks(task)
+}
+
+// Parses the networkConnections resource property of a GET_NETWORKS task.
+func parseVMNetworks(task *photon.Task) ([]VMNetwork, error) {
+	networks := []VMNetwork{}
+	properties, ok := task.ResourceProperties.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Task %s did not return the networks of VM %s", task.ID, task.Entity.ID)
+	}
+	connections, ok := properties["networkConnections"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Task %s did not return the networks of VM %s", task.ID, task.Entity.ID)
+	}
+	for _, c := range connections {
+		connection, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Task %s returned a bad network connection: %v", task.ID, c)
+		}
+		networks = append(networks, VMNetwork{
+			Network:     stringProperty(connection, "network"),
+			MacAddress:  stringProperty(connection, "macAddress"),
+			IpAddress:   stringProperty(connection, "ipAddress"),
+			Netmask:     stringProperty(connection, "netmask"),
+			IsConnected: stringProperty(connection, "isConnected"),
+		})
+	}
+	return networks, nil
+}
+
+// Returns a property as a string, or "" if it is missing or null.
+func stringProperty(properties map[string]interface{}, key string) string {
+	switch val := properties[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// Returns the IP address of the first connection to a network that has one, or
+// "" if there is none. When network is given, only connections to it are considered.
+func primaryIP(networks []VMNetwork, network string) string {
+	for _, n := range networks {
+		if len(n.Network) == 0 || len(n.IpAddress) == 0 {
+			continue
+		}
+		if len(network) == 0 || n.Network == network {
+			return n.IpAddress
+		}
+	}
+	return ""
+}
+
+// Prints the primary IP address of a VM, waiting for the guest to report one with --wait
+func showVMIP(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+	network := c.String("network")
+	wait := c.Bool("wait")
+	timeout := time.Duration(c.Int("timeout")) * time.Second
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var fetchErr error
+	for {
+		vm, err := client.Photonclient.VMs.Get(id)
+		if err != nil {
+			return err
+		}
+		if vm.State == "ERROR" {
+			return fmt.Errorf("VM %s is in ERROR state", id)
+		}
+
+		// The guest may not be able to report its networks yet while it boots,
+		// so with --wait a failed lookup is retried like a missing address.
+		networks, err := fetchVMNetworks(id, false)
+		if err != nil && !wait {
+			return err
+		}
+		fetchErr = err
+		ip := primaryIP(networks, network)
+		if len(ip) != 0 {
+			if utils.NeedsFormatting(c) {
+				utils.FormatObject(map[string]string{"id": id, "ipAddress": ip}, w, c)
+			} else {
+				fmt.Fprintf(w, "%s\n", ip)
+			}
+			return nil
+		}
+
+		if !wait {
+			if len(network) != 0 {
+				return fmt.Errorf("VM %s has no IP address on network %s", id, network)
+			}
+			return fmt.Errorf("VM %s has no IP address, use --wait to wait for one", id)
+		}
+		if time.Since(start) >= timeout {
+			if fetchErr != nil {
+				return fetchErr
+			}
+			return fmt.Errorf("Timed out while waiting for VM %s to get an IP address", id)
+		}
+		time.Sleep(vmIpPollDelay)
+	}
+}
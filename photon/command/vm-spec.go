@@ -0,0 +1,81 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Spec of a VM printed by vm get-spec and read by vm create --spec-file: the
+// create spec of the VM and the metadata set once it is created.
+type vmSpecFile struct {
+	photon.VmCreateSpec
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Prints the spec to create a VM like the one with the given id. Parts of the VM
+// that a create spec cannot hold, such as persistent disks, are reported on stderr.
+func getVMSpec(c *cli.Context, w io.Writer) error {
+	err := checkArgCount(c, 1)
+	if err != nil {
+		return err
+	}
+	id := c.Args().First()
+
+	client.Photonclient, err = client.GetClient(c)
+	if err != nil {
+		return err
+	}
+
+	vm, err := client.Photonclient.VMs.Get(id)
+	if err != nil {
+		return err
+	}
+	networks, err := fetchVMNetworks(id, false)
+	if err != nil {
+		return err
+	}
+
+	// The spec is the one vm clone uses, without flavor or image mapping.
+	cloner := &projectCloner{src: client.Photonclient, dst: client.Photonclient}
+	createSpec, err := cloner.vmCreateSpec(*vm)
+	if err != nil {
+		return err
+	}
+	spec := vmSpecFile{VmCreateSpec: *createSpec, Metadata: vm.Metadata}
+	for _, network := range networks {
+		if len(network.Network) != 0 {
+			spec.Subnets = append(spec.Subnets, network.Network)
+		}
+	}
+
+	for _, d := range vm.AttachedDisks {
+		if d.Kind == "persistent-disk" {
+			fmt.Fprintf(os.Stderr, "Warning: persistent disk '%s' (%s) is not in the spec, attach it with vm attach-disk\n",
+				d.Name, d.ID)
+		}
+	}
+	for _, iso := range vm.AttachedISOs {
+		fmt.Fprintf(os.Stderr, "Warning: ISO '%s' is not in the spec, attach it with vm attach-iso\n", iso.Name)
+	}
+	if len(vm.FloatingIp) != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: floating IP %s is not in the spec, acquire one with vm acquire-floating-ip\n",
+			vm.FloatingIp)
+	}
+
+	return printSpec(spec, w, c)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/photon-controller-cli/photon/client"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
+
+	"github.com/urfave/cli"
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+func TestVMSpecRoundTrip(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "disk-small", Kind: "ephemeral-disk"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{
+		Name:          "web",
+		Flavor:        "small",
+		SourceImageID: imageID,
+		Tags:          []string{"app:web"},
+		AttachedDisks: []photon.AttachedDisk{
+			{Name: "boot", Kind: "ephemeral-disk", Flavor: "disk-small", CapacityGB: 10, BootDisk: true},
+		},
+	})
+	sourceID := waitForEntity(t, task, err)
+	task, err = api.VMs.SetMetadata(sourceID, &photon.VmMetadata{Metadata: map[string]string{"role": "web"}})
+	waitForEntity(t, task, err)
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	set := flag.NewFlagSet("test", 0)
+	err = set.Parse([]string{sourceID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+
+	var output bytes.Buffer
+	err = getVMSpec(cli.NewContext(nil, set, globalCtx), &output)
+	if err != nil {
+		t.Fatal("Not expecting error getting VM spec: " + err.Error())
+	}
+	for _, line := range []string{"flavor: small", "name: web", "sourceImageId: " + imageID,
+		"- app:web", "  role: web", "  capacityGb: 10", "  bootDisk: true"} {
+		if !strings.Contains(output.String(), line+"\n") {
+			t.Errorf("Expected spec to contain '%s', got:\n%s", line, output.String())
+		}
+	}
+	if strings.Contains(output.String(), "state:") {
+		t.Errorf("Expected empty fields to be left out, got:\n%s", output.String())
+	}
+
+	file, err := ioutil.TempFile("", "vm-spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+	_, err = file.Write(output.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set = flag.NewFlagSet("test", 0)
+	for _, name := range []string{"name", "flavor", "image", "boot-disk-flavor", "disks", "environment",
+		"affinities", "networks"} {
+		set.String(name, "", name)
+	}
+	set.String("tenant", "tenant1", "tenant")
+	set.String("project", "project1", "project")
+	set.String("spec-file", file.Name(), "spec file")
+	err = set.Parse([]string{"--name=copy"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = createVM(cli.NewContext(nil, set, globalCtx), ioutil.Discard)
+	if err != nil {
+		t.Fatal("Not expecting error creating VM from spec: " + err.Error())
+	}
+
+	vms, err := api.Projects.GetVMs(projectID, &photon.VmGetOptions{Name: "copy"})
+	if err != nil || len(vms.Items) != 1 {
+		t.Fatalf("Expected the VM to be created with the name given on the command line, got %v, %v", vms, err)
+	}
+	vm := vms.Items[0]
+	if vm.Flavor != "small" || vm.SourceImageID != imageID || !reflect.DeepEqual(vm.Tags, []string{"app:web"}) ||
+		!reflect.DeepEqual(vm.Metadata, map[string]string{"role": "web"}) || len(vm.AttachedDisks) != 1 ||
+		vm.AttachedDisks[0].CapacityGB != 10 {
+		t.Errorf("Expected the VM to match the spec, got %+v", vm)
+	}
+}
+
+func TestReadSpecFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	_, err = file.WriteString(`{"name": "vm-1", "flavor": "small", "attachedDisks": [{"name": "boot", "capacityGb": 2}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := &vmSpecFile{}
+	err = readSpecFile(file.Name(), spec)
+	if err != nil || spec.Name != "vm-1" || spec.AttachedDisks[0].CapacityGB != 2 {
+		t.Errorf("Expected JSON spec to be read, got %+v, %v", spec, err)
+	}
+
+	err = ioutil.WriteFile(file.Name(), []byte("name: vm-1\nflavour: small\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = readSpecFile(file.Name(), &vmSpecFile{})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "flavour"`) {
+		t.Errorf("Expected unknown field to be rejected, got %v", err)
+	}
+}
@@ -45,8 +45,11 @@ import (
 //      set-metadata; Usage: vm set-metadata <id> [<options>]
 //      set-tag;      Usage: vm set-tag <id> [<options>]
 //      networks;     Usage: vm networks <id>
+//      ip;           Usage: vm ip <id> [<options>]
 //      mks-ticket;   Usage: vm mks-ticket <id>
 //      create-image; Usage: vm create-image <id> [<options>]
+//      clone;        Usage: vm clone <id> [<options>]
+//      get-spec;     Usage: vm get-spec <id>
 //      aquire-floating-ip; Usage: vm aquare-floating-ip <id> [<options>]
 //      release-floating-ip; Usage: vm release-floating-ip <id> [<options>]
 func GetVMCommand() cli.Command {
@@ -60,9 +63,12 @@ func GetVMCommand() cli.Command {
 				ArgsUsage: " ",
 				Description: "To create a VM in interactive mode, use the photon vm create command \n" +
 					"   with no options. The command prompts you for the VM name, flavor, and source image.\n" +
-					"   Also, photon provides non-interactive option to supply this information with '-n' \n\n" +
+					"   Also, photon provides non-interactive option to supply this information with '-n' \n" +
+					"   The VM can also be described by a spec file in YAML or JSON, such as the output of\n" +
+					"   'photon vm get-spec'. Options given on the command line override the spec file.\n\n" +
 					"   Example:\n" +
-					"     photon vm create -n vm-1 -f flavor-1 -d \"disk-1 disk-flavor boot=true\" -i [image_id]",
+					"     photon vm create -n vm-1 -f flavor-1 -d \"disk-1 disk-flavor boot=true\" -i [image_id]\n" +
+					"     photon vm create --spec-file vm-1.yaml -n vm-2",
 				Flags: []cli.Flag{
 					cli.StringFlag{
 						Name:  "name, n",
@@ -104,6 +110,7 @@ func GetVMCommand() cli.Command {
 						Name:  "project, p",
 						Usage: "Project name",
 					},
+					specFileFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := createVM(c, os.Stdout)
@@ -282,6 +289,7 @@ func GetVMCommand() cli.Command {
 						Name:  "name, n",
 						Usage: "ISO name",
 					},
+					limitRateFlag,
 				},
 				Action: func(c *cli.Context) {
 					err := attachIso(c, os.Stdout)
@@ -353,6 +361,37 @@ func GetVMCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "ip",
+				Usage:     "Show the primary IP address of a VM",
+				ArgsUsage: "<vm-id>",
+				Description: "Print the IP address of the first network of the VM that has one. With --wait,\n" +
+					"   wait until the guest reports an address, e.g. to connect to a VM right after creating it.\n\n" +
+					"   Example: \n" +
+					"   photon vm ip --wait 4f9caq234 \n" +
+					"   ssh root@$(photon vm ip --wait --network 8ab2d1e 4f9caq234) \n",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "network, n",
+						Usage: "Only consider connections to this network",
+					},
+					cli.BoolFlag{
+						Name:  "wait",
+						Usage: "Wait for the VM to get an IP address",
+					},
+					cli.IntFlag{
+						Name:  "timeout",
+						Value: 300,
+						Usage: "Number of seconds to wait with --wait",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := showVMIP(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:      "mks-ticket",
 				Usage:     "Get VM MKS ticket for a VM",
@@ -387,6 +426,69 @@ func GetVMCommand() cli.Command {
 					}
 				},
 			},
+			{
+				Name:      "clone",
+				Usage:     "Create copies of a VM",
+				ArgsUsage: "<vm-id>",
+				Description: "Create VMs with the same flavor, ephemeral disks, tags and metadata as an existing VM.\n" +
+					"   The new VMs are attached to the same subnets when they are created in the project of the\n" +
+					"   source VM, otherwise to the default subnet of their project. They are created from the image\n" +
+					"   of the source VM, or with --create-image from an image of its current disk.\n" +
+					"   With --count, the VMs are named <name>-1, <name>-2, etc.\n" +
+					"   Persistent disks, ISOs and floating IPs are not copied, they are listed at the end.\n\n" +
+					"   Example:\n" +
+					"     photon vm clone <vm-id> -n web -c 3 --create-image",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "name, n",
+						Usage: "Name of the new VM",
+					},
+					cli.IntFlag{
+						Name:  "count, c",
+						Value: 1,
+						Usage: "Number of VMs to create",
+					},
+					cli.StringFlag{
+						Name:  "tenant, t",
+						Usage: "Tenant name for the new VMs",
+					},
+					cli.StringFlag{
+						Name:  "project, p",
+						Usage: "Project name for the new VMs",
+					},
+					cli.BoolFlag{
+						Name:  "create-image",
+						Usage: "Create the VMs from an image of the current disk of the source VM",
+					},
+					cli.StringFlag{
+						Name:  "image_replication, i",
+						Value: "EAGER",
+						Usage: "Replication type of the image created with --create-image",
+					},
+				},
+				Action: func(c *cli.Context) {
+					err := cloneVM(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
+			{
+				Name:      "get-spec",
+				Usage:     "Print the spec to recreate a VM",
+				ArgsUsage: "<vm-id>",
+				Description: "Print the flavor, image, ephemeral disks, subnets, tags and metadata of a VM as a spec\n" +
+					"   in YAML, or in JSON with --output json. The spec can be given back to\n" +
+					"   'photon vm create --spec-file' to recreate the VM.\n\n" +
+					"   Example:\n" +
+					"     photon vm get-spec <vm-id> > vm-1.yaml",
+				Action: func(c *cli.Context) {
+					err := getVMSpec(c, os.Stdout)
+					if err != nil {
+						log.Fatal("Error: ", err)
+					}
+				},
+			},
 			{
 				Name:      "acquire-floating-ip",
 				Usage:     "Acquire a floating IP",
@@ -442,6 +544,15 @@ func createVM(c *cli.Context, w io.Writer) error {
 	projectName := c.String("project")
 	networks := c.String("networks")
 
+	spec := &vmSpecFile{}
+	err = readSpecFileFlag(c, spec)
+	if err != nil {
+		return err
+	}
+	name = stringOrSpec(name, spec.Name)
+	flavor = stringOrSpec(flavor, spec.Flavor)
+	imageID = stringOrSpec(imageID, spec.SourceImageID)
+
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
@@ -461,6 +572,9 @@ func createVM(c *cli.Context, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if len(disks) == 0 {
+		disksList = spec.AttachedDisks
+	}
 
 	if !c.GlobalIsSet("non-interactive") && !utils.NeedsFormatting(c) {
 		name, err = askForInput("VM name: ", name)
@@ -519,7 +633,7 @@ func createVM(c *cli.Context, w io.Writer) error {
 		}, disksList...)
 	}
 
-	var environmentMap map[string]string
+	environmentMap := spec.Environment
 	if len(environment) != 0 {
 		environmentMap, err = parseMapFromFlag(environment)
 		if err != nil {
@@ -527,17 +641,21 @@ func createVM(c *cli.Context, w io.Writer) error {
 		}
 	}
 
-	affinitiesList, err := parseAffinitiesListFromFlag(affinities)
-	if err != nil {
-		return err
+	affinitiesList := spec.Affinities
+	if len(affinities) != 0 {
+		affinitiesList, err = parseAffinitiesListFromFlag(affinities)
+		if err != nil {
+			return err
+		}
 	}
 
-	var networkList []string
+	networkList := spec.Subnets
 	if len(networks) > 0 {
 		networkList = regexp.MustCompile(`\s*,\s*`).Split(networks, -1)
 	}
 
 	vmSpec := photon.VmCreateSpec{}
+	vmSpec.Tags = spec.Tags
 	vmSpec.Name = name
 	vmSpec.Flavor = flavor
 	vmSpec.SourceImageID = imageID
@@ -569,6 +687,17 @@ func createVM(c *cli.Context, w io.Writer) error {
 			return err
 		}
 
+		if len(spec.Metadata) != 0 {
+			task, err := client.Photonclient.VMs.SetMetadata(vmID, &photon.VmMetadata{Metadata: spec.Metadata})
+			if err != nil {
+				return err
+			}
+			_, err = client.Photonclient.Tasks.Wait(task.ID)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = formatHelper(c, w, client.Photonclient, vmID)
 
 		return err
@@ -625,7 +754,7 @@ func showVM(c *cli.Context, w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	var networks []interface{}
+	var networks []VMNetwork
 	if vm.State != "ERROR" {
 		networks, err = getVMNetworks(id, c)
 		if err != nil {
@@ -684,19 +813,10 @@ func showVM(c *cli.Context, w io.Writer) error {
 			fmt.Println("      Name: ", iso.Name)
 			fmt.Println("      Size: ", iso.Size)
 		}
-		for i, nt := range networks {
-			network := nt.(map[string]interface{})
+		for i, network := range networks {
 			fmt.Printf("    Networks: %d\n", i+1)
-			networkName := ""
-			ipAddr := ""
-			if val, ok := network["network"]; ok && val != nil {
-				networkName = val.(string)
-			}
-			if val, ok := network["ipAddress"]; ok && val != nil {
-				ipAddr = val.(string)
-			}
-			fmt.Println("      Name:       ", networkName)
-			fmt.Println("      IP Address: ", ipAddr)
+			fmt.Println("      Name:       ", network.Network)
+			fmt.Println("      IP Address: ", network.IpAddress)
 		}
 		for i, tag := range vm.Tags {
 			fmt.Printf("    Tag %d:\n", i+1)
@@ -996,8 +1116,8 @@ func detachDisk(c *cli.Context, w io.Writer) error {
 	return err
 }
 
-func attachIso(c *cli.Context, w io.Writer) error {
-	err := checkArgCount(c, 1)
+func attachIso(c *cli.Context, w io.Writer) (err error) {
+	err = checkArgCount(c, 1)
 	if err != nil {
 		return err
 	}
@@ -1029,17 +1149,23 @@ func attachIso(c *cli.Context, w io.Writer) error {
 		return err
 	}
 
-	file, err := os.Open(path)
+	upload, err := openUpload(path, name, c)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		e := upload.Close()
+		if e != nil && err == nil {
+			err = e
+		}
+	}()
 
 	client.Photonclient, err = client.GetClient(c)
 	if err != nil {
 		return err
 	}
 
-	task, err := client.Photonclient.VMs.AttachISO(id, file, name)
+	task, err := client.Photonclient.VMs.AttachISO(id, upload, name)
 	if err != nil {
 		return err
 	}
@@ -1048,10 +1174,19 @@ func attachIso(c *cli.Context, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	upload.printSummary()
 
-	err = formatHelper(c, w, client.Photonclient, id)
-
-	return err
+	if utils.NeedsFormatting(c) {
+		vm, err := client.Photonclient.VMs.Get(id)
+		if err != nil {
+			return err
+		}
+		utils.FormatObject(struct {
+			photon.VM
+			Checksum string `json:"isoSha256"`
+		}{*vm, upload.Checksum()}, w, c)
+	}
+	return nil
 }
 
 func detachIso(c *cli.Context, w io.Writer) error {
@@ -10,14 +10,18 @@
 package command
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware/photon-controller-cli/photon/client"
 	"github.com/vmware/photon-controller-cli/photon/mocks"
+	"github.com/vmware/photon-controller-cli/photon/mocks/simulator"
 
 	"github.com/urfave/cli"
 	"github.com/vmware/photon-controller-go-sdk/photon"
@@ -1281,3 +1285,123 @@ func TestReleaseFloatingIp(t *testing.T) {
 		t.Error("Not expecting error creating VM image: " + err.Error())
 	}
 }
+
+func TestShowVMIP(t *testing.T) {
+	sim := simulator.NewServer()
+	defer sim.Close()
+	api := sim.NewClient()
+	client.Photonclient = api
+	defer func(delay time.Duration) { vmIpPollDelay = delay }(vmIpPollDelay)
+	vmIpPollDelay = time.Millisecond
+
+	sim.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	imageID := sim.AddImage("ubuntu", 1024)
+	task, err := api.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := waitForEntity(t, task, err)
+	task, err = api.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	projectID := waitForEntity(t, task, err)
+	task, err = api.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm1", Flavor: "small", SourceImageID: imageID})
+	vmID := waitForEntity(t, task, err)
+
+	globalSet := flag.NewFlagSet("test", 0)
+	globalSet.Bool("non-interactive", true, "doc")
+	globalCtx := cli.NewContext(nil, globalSet, nil)
+	err = globalSet.Parse([]string{"--non-interactive"})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	newContext := func(wait bool) *cli.Context {
+		set := flag.NewFlagSet("test", 0)
+		set.String("network", "", "network")
+		set.Bool("wait", wait, "wait")
+		set.Int("timeout", 5, "timeout")
+		err := set.Parse([]string{vmID})
+		if err != nil {
+			t.Error("Not expecting arguments parsing to fail")
+		}
+		return cli.NewContext(nil, set, globalCtx)
+	}
+
+	var output bytes.Buffer
+	err = showVMIP(newContext(false), &output)
+	if err == nil || !strings.Contains(err.Error(), "has no IP address") {
+		t.Errorf("Expected an error for a stopped VM, got %v", err)
+	}
+
+	// Simulated tasks only progress when polled, so the start task is waited
+	// for while showVMIP polls the VM. t.Fatal must not be called from the
+	// goroutine, the error is checked once showVMIP returns.
+	started := make(chan error, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		task, err := api.VMs.Start(vmID)
+		if err == nil {
+			_, err = api.Tasks.Wait(task.ID)
+		}
+		started <- err
+	}()
+	err = showVMIP(newContext(true), &output)
+	if startErr := <-started; startErr != nil {
+		t.Fatal("Not expecting error starting VM: " + startErr.Error())
+	}
+	if err != nil {
+		t.Fatal("Not expecting error waiting for the VM IP: " + err.Error())
+	}
+	networks, err := fetchVMNetworks(vmID, false)
+	if err != nil || len(networks) != 1 {
+		t.Fatalf("Expected one network connection, got %+v (%v)", networks, err)
+	}
+	if output.String() != networks[0].IpAddress+"\n" || len(networks[0].IpAddress) == 0 {
+		t.Errorf("Expected the address of %+v, got '%s'", networks[0], output.String())
+	}
+
+	// A failed network lookup is retried with --wait
+	sim.InjectFault(simulator.Fault{
+		Method: "GET",
+		Path:   "/vms/*/subnets",
+		Error:  photon.ApiError{Code: "VmNotReady", Message: "guest tools not running"},
+		Times:  1,
+	})
+	output.Reset()
+	err = showVMIP(newContext(true), &output)
+	if err != nil {
+		t.Fatal("Not expecting error once the network lookup succeeds: " + err.Error())
+	}
+	if output.String() != networks[0].IpAddress+"\n" {
+		t.Errorf("Expected the address of %+v, got '%s'", networks[0], output.String())
+	}
+
+	// Without --wait it is reported right away
+	sim.InjectFault(simulator.Fault{
+		Method: "GET",
+		Path:   "/vms/*/subnets",
+		Error:  photon.ApiError{Code: "VmNotReady", Message: "guest tools not running"},
+		Times:  1,
+	})
+	err = showVMIP(newContext(false), &output)
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.Code != "VmNotReady" {
+		t.Errorf("Expected the network lookup error, got %v", err)
+	}
+
+	// With --wait the last error is returned once the timeout expires
+	sim.InjectFault(simulator.Fault{
+		Method: "GET",
+		Path:   "/vms/*/subnets",
+		Error:  photon.ApiError{Code: "VmNotReady", Message: "guest tools not running"},
+	})
+	set := flag.NewFlagSet("test", 0)
+	set.String("network", "", "network")
+	set.Bool("wait", true, "wait")
+	set.Int("timeout", 0, "timeout")
+	err = set.Parse([]string{vmID})
+	if err != nil {
+		t.Error("Not expecting arguments parsing to fail")
+	}
+	err = showVMIP(cli.NewContext(nil, set, globalCtx), &output)
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.Code != "VmNotReady" {
+		t.Errorf("Expected the last network lookup error after the timeout, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A profile is a named copy of a configuration, which lets commands talk to
+// more than one deployment at a time.
+
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Load the configuration saved under the given profile name
+func LoadProfile(name string) (*Configuration, error) {
+	filepath, err := getProfileFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isFileExist(filepath) {
+		return nil, fmt.Errorf("Profile '%s' does not exist, save it with 'target save-profile %s'", name, name)
+	}
+
+	return readConfigFromFile(filepath)
+}
+
+// Save configuration under the given profile name, will overwrite an existing profile
+func SaveProfile(name string, config *Configuration) error {
+	filepath, err := getProfileFilePath(name)
+	if err != nil {
+		return err
+	}
+
+	return writeConfigToFile(filepath, config)
+}
+
+// Remove the profile with the given name
+func RemoveProfile(name string) error {
+	filepath, err := getProfileFilePath(name)
+	if err != nil {
+		return err
+	}
+
+	if !isFileExist(filepath) {
+		return fmt.Errorf("Profile '%s' does not exist", name)
+	}
+	return os.Remove(filepath)
+}
+
+// List the names of all saved profiles
+func ListProfiles() ([]string, error) {
+	profilesDir, err := getProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(profilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && path.Ext(f.Name()) == ".json" {
+			names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Get path of a profile file: $HOME_DIR/.photon-cli/profiles/<name>.json
+func getProfileFilePath(name string) (string, error) {
+	if !validProfileName.MatchString(name) {
+		return "", fmt.Errorf("Invalid profile name '%s', use letters, digits, '.', '_' or '-'", name)
+	}
+
+	profilesDir, err := getProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(profilesDir, name+".json"), nil
+}
+
+func getProfilesDir() (string, error) {
+	userConfigDir, err := getUserConfigDirectory()
+	if err != nil {
+		return userConfigDir, err
+	}
+	profilesDir := path.Join(userConfigDir, "profiles")
+
+	//Ensure Profiles Dir Exists - if not create it
+	if !isFileExist(profilesDir) {
+		err = os.Mkdir(profilesDir, 0700)
+		if err != nil {
+			return profilesDir, err
+		}
+	}
+	return profilesDir, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/vmware/photon-controller-cli/photon/configuration"
+)
+
+var _ = Describe("Profiles", func() {
+	BeforeEach(func() {
+		var err error
+		UserConfigDir, err = ioutil.TempDir("", "profiles-test-")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(UserConfigDir)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("LoadProfile", func() {
+		Context("when profile does not exist", func() {
+			It("returns an error", func() {
+				_, err := LoadProfile("old")
+				Expect(err).To(MatchError(
+					"Profile 'old' does not exist, save it with 'target save-profile old'"))
+			})
+		})
+
+		Context("when profile has been saved", func() {
+			var (
+				configExpected *Configuration
+			)
+			BeforeEach(func() {
+				configExpected = &Configuration{
+					CloudTarget: "https://old-deployment:9000",
+					Token:       "fake-token",
+				}
+
+				err := SaveProfile("old", configExpected)
+				Expect(err).To(BeNil())
+			})
+
+			It("returns the config", func() {
+				config, err := LoadProfile("old")
+
+				Expect(err).To(BeNil())
+				Expect(config).To(BeEquivalentTo(configExpected))
+			})
+
+			It("is listed and can be removed", func() {
+				names, err := ListProfiles()
+				Expect(err).To(BeNil())
+				Expect(names).To(Equal([]string{"old"}))
+
+				err = RemoveProfile("old")
+				Expect(err).To(BeNil())
+
+				names, err = ListProfiles()
+				Expect(err).To(BeNil())
+				Expect(names).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("SaveProfile", func() {
+		It("rejects names that are not valid file names", func() {
+			err := SaveProfile("../config", &Configuration{})
+			Expect(err).To(MatchError(
+				"Invalid profile name '../config', use letters, digits, '.', '_' or '-'"))
+		})
+	})
+})
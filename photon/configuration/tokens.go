@@ -0,0 +1,91 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// Tokens of a user for the OIDC client of a service, cached so that kubectl can get
+// a valid id token without asking for the password again. The client ID is kept to
+// refresh them without looking up the service.
+type ServiceTokens struct {
+	ClientID     string
+	Username     string
+	IdToken      string
+	RefreshToken string
+}
+
+// Load the tokens cached for the given service ID, nil if there are none
+func LoadServiceTokens(serviceID string) (*ServiceTokens, error) {
+	filepath, err := getServiceTokensFilePath(serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error loading tokens: %v", err)
+	}
+
+	var tokens ServiceTokens
+	err = json.Unmarshal(data, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading tokens: %v", err)
+	}
+	return &tokens, nil
+}
+
+// Save the tokens for the given service ID, readable only by the user
+func SaveServiceTokens(serviceID string, tokens *ServiceTokens) error {
+	filepath, err := getServiceTokensFilePath(serviceID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("Error saving tokens: %v", err)
+	}
+	err = ioutil.WriteFile(filepath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("Error saving tokens: %v", err)
+	}
+	return nil
+}
+
+// Get path of a tokens file: $HOME_DIR/.photon-cli/tokens/<service-id>.json
+func getServiceTokensFilePath(serviceID string) (string, error) {
+	if !validProfileName.MatchString(serviceID) {
+		return "", fmt.Errorf("Invalid service ID '%s'", serviceID)
+	}
+
+	userConfigDir, err := getUserConfigDirectory()
+	if err != nil {
+		return "", err
+	}
+	tokensDir := path.Join(userConfigDir, "tokens")
+
+	//Ensure Tokens Dir Exists - if not create it
+	if !isFileExist(tokensDir) {
+		err = os.Mkdir(tokensDir, 0700)
+		if err != nil {
+			return "", err
+		}
+	}
+	return path.Join(tokensDir, serviceID+".json"), nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package configuration_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/vmware/photon-controller-cli/photon/configuration"
+)
+
+var _ = Describe("ServiceTokens", func() {
+	BeforeEach(func() {
+		var err error
+		UserConfigDir, err = ioutil.TempDir("", "tokens-test-")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(UserConfigDir)
+		Expect(err).To(BeNil())
+	})
+
+	Context("when no tokens have been saved", func() {
+		It("returns nil", func() {
+			tokens, err := LoadServiceTokens("service-1")
+			Expect(err).To(BeNil())
+			Expect(tokens).To(BeNil())
+		})
+	})
+
+	Context("when tokens have been saved", func() {
+		var (
+			tokensExpected *ServiceTokens
+		)
+		BeforeEach(func() {
+			tokensExpected = &ServiceTokens{
+				ClientID:     "client-1",
+				Username:     "admin@tenant1",
+				IdToken:      "fake-id-token",
+				RefreshToken: "fake-refresh-token",
+			}
+
+			err := SaveServiceTokens("service-1", tokensExpected)
+			Expect(err).To(BeNil())
+		})
+
+		It("returns the tokens of the service", func() {
+			tokens, err := LoadServiceTokens("service-1")
+			Expect(err).To(BeNil())
+			Expect(tokens).To(Equal(tokensExpected))
+
+			tokens, err = LoadServiceTokens("service-2")
+			Expect(err).To(BeNil())
+			Expect(tokens).To(BeNil())
+		})
+
+		It("keeps them readable by the user only", func() {
+			info, err := os.Stat(path.Join(UserConfigDir, "tokens", "service-1.json"))
+			Expect(err).To(BeNil())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+
+	It("rejects service IDs that are not valid file names", func() {
+		err := SaveServiceTokens("../config", &ServiceTokens{})
+		Expect(err).To(MatchError("Invalid service ID '../config'"))
+	})
+})
@@ -0,0 +1,375 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package ipaddr
+
+/**
+ * These utilities parse the IP addresses, CIDR blocks and address ranges given on the
+ * command line and in manifests, so that bad input is rejected before calling the API.
+ *
+ * Both IPv4 and IPv6 are supported. IPv4 addresses are always returned in their 4-byte
+ * form, so that addresses of the same family can be compared byte by byte.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Inclusive range of addresses of a single family.
+type Range struct {
+	Start net.IP
+	End   net.IP
+
+	// The text the range was parsed from, used in error messages.
+	Text string
+}
+
+// Addresses and ranges, such as "10.0.0.0/28, !10.0.0.1" or "10.0.0.1, 10.0.0.5-10.0.0.9".
+type List []Range
+
+var (
+	listSeparator  = regexp.MustCompile(`\s*,\s*`)
+	rangeSeparator = regexp.MustCompile(`\s*-\s*`)
+)
+
+// Parses an IPv4 or IPv6 address.
+func ParseIP(s string) (net.IP, error) {
+	ip := normalize(net.ParseIP(strings.TrimSpace(s)))
+	if ip == nil {
+		return nil, fmt.Errorf("bad IP address '%s'", strings.TrimSpace(s))
+	}
+	return ip, nil
+}
+
+// Parses a comma separated list of IPv4 or IPv6 addresses.
+func ParseIPs(s string) ([]net.IP, error) {
+	var res []net.IP
+	if len(strings.TrimSpace(s)) == 0 {
+		return res, nil
+	}
+	for _, address := range listSeparator.Split(strings.TrimSpace(s), -1) {
+		ip, err := ParseIP(address)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ip)
+	}
+	return res, nil
+}
+
+// Parses a CIDR block such as "10.0.0.0/28" or "fd00::/64". Unlike net.ParseCIDR,
+// the address must be the first address of the block.
+func ParseCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	ip, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad CIDR '%s', expected an address and a prefix length such as 10.0.0.0/24", s)
+	}
+	network.IP = normalize(network.IP)
+	if !ip.Equal(network.IP) {
+		return nil, fmt.Errorf("CIDR '%s' has host bits set, did you mean %s?", s, network)
+	}
+	return network, nil
+}
+
+// Parses a single address, a range such as "10.0.0.5-10.0.0.9" or a CIDR block.
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		network, err := ParseCIDR(s)
+		if err != nil {
+			return Range{}, err
+		}
+		r := CIDRRange(network)
+		r.Text = s
+		return r, nil
+	}
+
+	addresses := rangeSeparator.Split(s, -1)
+	if len(addresses) > 2 {
+		return Range{}, fmt.Errorf("bad address range '%s'", s)
+	}
+	r := Range{Text: s}
+	for i, address := range addresses {
+		ip, err := ParseIP(address)
+		if err != nil {
+			return Range{}, err
+		}
+		r.End = ip
+		if i == 0 {
+			r.Start = ip
+		}
+	}
+	if len(r.Start) != len(r.End) {
+		return Range{}, fmt.Errorf("address range '%s' mixes IPv4 and IPv6 addresses", s)
+	}
+	if bytes.Compare(r.Start, r.End) > 0 {
+		return Range{}, fmt.Errorf("address range '%s' ends before it starts", s)
+	}
+	return r, nil
+}
+
+// Parses a comma separated list of addresses, ranges and CIDR blocks. Items that
+// start with '!' are removed from the others, e.g. "10.0.0.0/28, !10.0.0.0, !10.0.0.15".
+func Parse(s string) (List, error) {
+	var res, excluded List
+	for _, text := range listSeparator.Split(strings.TrimSpace(s), -1) {
+		exclude := strings.HasPrefix(text, "!")
+		r, err := ParseRange(strings.TrimPrefix(text, "!"))
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			excluded = append(excluded, r)
+		} else {
+			res = append(res, r)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("address list '%s' only has exclusions", strings.TrimSpace(s))
+	}
+
+	for _, e := range excluded {
+		if !res.Overlaps(e) {
+			return nil, fmt.Errorf("excluded range '%s' is not in the list", e.Text)
+		}
+		res = res.remove(e)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("address list '%s' excludes all its addresses", strings.TrimSpace(s))
+	}
+	return res, nil
+}
+
+// Returns the range of addresses in a CIDR block.
+func CIDRRange(network *net.IPNet) Range {
+	start := normalize(network.IP)
+	return Range{Start: start, End: Last(network), Text: network.String()}
+}
+
+// Returns the last address of a CIDR block.
+func Last(network *net.IPNet) net.IP {
+	start := normalize(network.IP)
+	mask := network.Mask[len(network.Mask)-len(start):]
+	end := make(net.IP, len(start))
+	for i := range start {
+		end[i] = start[i] | ^mask[i]
+	}
+	return end
+}
+
+// Returns the first count blocks with the given prefix length in pool that
+// overlap none of the used ranges. The blocks found so far are returned with
+// the error when the pool runs out.
+func FreeBlocks(pool *net.IPNet, ones, count int, used List) ([]*net.IPNet, error) {
+	poolOnes, bits := pool.Mask.Size()
+	if ones < poolOnes || ones > bits {
+		return nil, fmt.Errorf("a /%d block does not fit in the pool %s", ones, pool)
+	}
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	base := normalize(pool.IP)
+	last := Last(pool)
+	taken := append(List{}, used...)
+	var res []*net.IPNet
+	for start := base; start != nil && Compare(start, last) <= 0 && len(res) < count; {
+		candidate := &net.IPNet{IP: start, Mask: net.CIDRMask(ones, bits)}
+		r := CIDRRange(candidate)
+		next, free := Next(r.End), true
+		for _, u := range taken {
+			if !u.Overlaps(r) {
+				continue
+			}
+			free = false
+			// Skip to the first block after the used range.
+			if Compare(u.End, r.End) > 0 {
+				next = alignUp(Next(u.End), base, size)
+			}
+		}
+		if free {
+			res = append(res, candidate)
+			taken = append(taken, r)
+		}
+		start = next
+	}
+	if len(res) < count {
+		return res, fmt.Errorf("no free /%d block left in the pool %s", ones, pool)
+	}
+	return res, nil
+}
+
+// Returns the first address from ip that is a multiple of size addresses from base.
+func alignUp(ip, base net.IP, size *big.Int) net.IP {
+	if ip == nil {
+		return nil
+	}
+	rem := new(big.Int).Mod(Distance(base, ip), size)
+	if rem.Sign() == 0 {
+		return ip
+	}
+	return Add(ip, rem.Sub(size, rem))
+}
+
+// Returns true if ip is in the range.
+func (r Range) Contains(ip net.IP) bool {
+	ip = normalize(ip)
+	return len(ip) == len(r.Start) && bytes.Compare(r.Start, ip) <= 0 && bytes.Compare(ip, r.End) <= 0
+}
+
+// Returns true if both ranges have addresses in common.
+func (r Range) Overlaps(other Range) bool {
+	return len(r.Start) == len(other.Start) &&
+		bytes.Compare(r.Start, other.End) <= 0 && bytes.Compare(other.Start, r.End) <= 0
+}
+
+// Returns the number of addresses in the range.
+func (r Range) Size() *big.Int {
+	return new(big.Int).Add(Distance(r.Start, r.End), big.NewInt(1))
+}
+
+func (r Range) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.String()
+	}
+	return r.Start.String() + "-" + r.End.String()
+}
+
+// Returns true if ip is in one of the ranges.
+func (l List) Contains(ip net.IP) bool {
+	for _, r := range l {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if one of the ranges overlaps other.
+func (l List) Overlaps(other Range) bool {
+	for _, r := range l {
+		if r.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of addresses in the list, counting repeated ones each time.
+func (l List) Size() *big.Int {
+	n := new(big.Int)
+	for _, r := range l {
+		n.Add(n, r.Size())
+	}
+	return n
+}
+
+// Returns every address of the list, failing when there are more than max.
+func (l List) Addresses(max int) ([]net.IP, error) {
+	if l.Size().Cmp(big.NewInt(int64(max))) > 0 {
+		return nil, fmt.Errorf("address list '%s' has more than %d addresses", l, max)
+	}
+	var res []net.IP
+	for _, r := range l {
+		for ip := r.Start; ip != nil && bytes.Compare(ip, r.End) <= 0; ip = Next(ip) {
+			res = append(res, ip)
+		}
+	}
+	return res, nil
+}
+
+func (l List) String() string {
+	var ranges []string
+	for _, r := range l {
+		ranges = append(ranges, r.String())
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// Returns the ranges without the addresses of excluded.
+func (l List) remove(excluded Range) List {
+	var res List
+	for _, r := range l {
+		if !r.Overlaps(excluded) {
+			res = append(res, r)
+			continue
+		}
+		if bytes.Compare(r.Start, excluded.Start) < 0 {
+			res = append(res, Range{Start: r.Start, End: Previous(excluded.Start), Text: r.Text})
+		}
+		if bytes.Compare(excluded.End, r.End) < 0 {
+			res = append(res, Range{Start: Next(excluded.End), End: r.End, Text: r.Text})
+		}
+	}
+	return res
+}
+
+// Collapses consecutive addresses into ranges, such as "10.0.0.1-10.0.0.4, 10.0.0.7".
+func Format(ips []net.IP) string {
+	var l List
+	for _, ip := range ips {
+		ip = normalize(ip)
+		if n := len(l); n != 0 && Next(l[n-1].End).Equal(ip) {
+			l[n-1].End = ip
+			continue
+		}
+		l = append(l, Range{Start: ip, End: ip})
+	}
+	return l.String()
+}
+
+// Returns the address following ip, or nil if ip is the last address of its family.
+func Next(ip net.IP) net.IP {
+	return Add(ip, big.NewInt(1))
+}
+
+// Returns the address preceding ip, or nil if ip is the first address of its family.
+func Previous(ip net.IP) net.IP {
+	return Add(ip, big.NewInt(-1))
+}
+
+// Returns the address n addresses after ip, or nil if there is no such address.
+func Add(ip net.IP, n *big.Int) net.IP {
+	ip = normalize(ip)
+	i := new(big.Int).Add(new(big.Int).SetBytes(ip), n)
+	if i.Sign() < 0 || i.BitLen() > len(ip)*8 {
+		return nil
+	}
+	b := i.Bytes()
+	res := make(net.IP, len(ip))
+	copy(res[len(res)-len(b):], b)
+	return res
+}
+
+// Returns the number of addresses from one address to another of the same
+// family, which is negative if to comes first.
+func Distance(from, to net.IP) *big.Int {
+	return new(big.Int).Sub(new(big.Int).SetBytes(normalize(to)), new(big.Int).SetBytes(normalize(from)))
+}
+
+// Orders addresses, with IPv4 addresses before IPv6 ones.
+func Compare(a, b net.IP) int {
+	a, b = normalize(a), normalize(b)
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return bytes.Compare(a, b)
+}
+
+// Returns IPv4 addresses in their 4-byte form.
+func normalize(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package ipaddr_test
+
+import (
+	. "github.com/vmware/photon-controller-cli/photon/ipaddr"
+
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ipaddr", func() {
+	Describe("ParseCIDR", func() {
+		It("parses IPv4 and IPv6 blocks", func() {
+			network, err := ParseCIDR("10.0.0.0/28")
+			Expect(err).To(BeNil())
+			Expect(network.String()).To(Equal("10.0.0.0/28"))
+			Expect(network.IP).To(HaveLen(4))
+
+			network, err = ParseCIDR(" fd00::/64 ")
+			Expect(err).To(BeNil())
+			Expect(network.String()).To(Equal("fd00::/64"))
+		})
+
+		It("rejects bad blocks", func() {
+			_, err := ParseCIDR("10.0.0.0")
+			Expect(err).To(MatchError("bad CIDR '10.0.0.0', expected an address and a prefix length such as 10.0.0.0/24"))
+
+			_, err = ParseCIDR("10.0.0.5/24")
+			Expect(err).To(MatchError("CIDR '10.0.0.5/24' has host bits set, did you mean 10.0.0.0/24?"))
+		})
+	})
+
+	Describe("ParseRange", func() {
+		It("parses addresses, ranges and blocks", func() {
+			r, err := ParseRange("10.0.0.5")
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("10.0.0.5"))
+
+			r, err = ParseRange("10.0.0.5 - 10.0.0.9")
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("10.0.0.5-10.0.0.9"))
+			Expect(r.Size().Int64()).To(Equal(int64(5)))
+
+			r, err = ParseRange("192.168.0.0/24")
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("192.168.0.0-192.168.0.255"))
+
+			r, err = ParseRange("fd00::ff00/120")
+			Expect(err).To(BeNil())
+			Expect(r.String()).To(Equal("fd00::ff00-fd00::ffff"))
+		})
+
+		It("rejects bad ranges", func() {
+			_, err := ParseRange("10.0.0.300")
+			Expect(err).To(MatchError("bad IP address '10.0.0.300'"))
+
+			_, err = ParseRange("10.0.0.1-10.0.0.2-10.0.0.3")
+			Expect(err).To(MatchError("bad address range '10.0.0.1-10.0.0.2-10.0.0.3'"))
+
+			_, err = ParseRange("10.0.0.9-10.0.0.4")
+			Expect(err).To(MatchError("address range '10.0.0.9-10.0.0.4' ends before it starts"))
+
+			_, err = ParseRange("10.0.0.1-fd00::1")
+			Expect(err).To(MatchError("address range '10.0.0.1-fd00::1' mixes IPv4 and IPv6 addresses"))
+		})
+	})
+
+	Describe("Parse", func() {
+		It("parses lists", func() {
+			l, err := Parse("10.0.0.1, 10.0.0.5-10.0.0.6, fd00::1")
+			Expect(err).To(BeNil())
+			Expect(l.String()).To(Equal("10.0.0.1, 10.0.0.5-10.0.0.6, fd00::1"))
+			Expect(l.Size().Int64()).To(Equal(int64(4)))
+			Expect(l.Contains(net.ParseIP("10.0.0.6"))).To(BeTrue())
+			Expect(l.Contains(net.ParseIP("10.0.0.7"))).To(BeFalse())
+		})
+
+		It("removes exclusions", func() {
+			l, err := Parse("10.0.0.0/28, !10.0.0.0, !10.0.0.4-10.0.0.5, !10.0.0.15")
+			Expect(err).To(BeNil())
+			Expect(l.String()).To(Equal("10.0.0.1-10.0.0.3, 10.0.0.6-10.0.0.14"))
+			Expect(l[0].Text).To(Equal("10.0.0.0/28"))
+		})
+
+		It("rejects bad exclusions", func() {
+			_, err := Parse("10.0.0.0/28, !10.0.1.0")
+			Expect(err).To(MatchError("excluded range '10.0.1.0' is not in the list"))
+
+			_, err = Parse("!10.0.0.1")
+			Expect(err).To(MatchError("address list '!10.0.0.1' only has exclusions"))
+
+			_, err = Parse("10.0.0.1, !10.0.0.1")
+			Expect(err).To(MatchError("address list '10.0.0.1, !10.0.0.1' excludes all its addresses"))
+		})
+
+		It("expands lists up to a limit", func() {
+			l, err := Parse("10.0.0.254-10.0.1.1")
+			Expect(err).To(BeNil())
+			ips, err := l.Addresses(4)
+			Expect(err).To(BeNil())
+			Expect(Format(ips)).To(Equal("10.0.0.254-10.0.1.1"))
+			Expect(ips[2].String()).To(Equal("10.0.1.0"))
+
+			l, err = Parse("fd00::/64")
+			Expect(err).To(BeNil())
+			_, err = l.Addresses(4096)
+			Expect(err).To(MatchError("address list 'fd00::-fd00::ffff:ffff:ffff:ffff' has more than 4096 addresses"))
+		})
+	})
+
+	Describe("Range", func() {
+		It("detects overlaps", func() {
+			a, _ := ParseRange("10.0.0.5-10.0.0.9")
+			b, _ := ParseRange("10.0.0.8/29")
+			c, _ := ParseRange("10.0.0.10")
+			v6, _ := ParseRange("::a00:5")
+
+			Expect(a.Overlaps(b)).To(BeTrue())
+			Expect(b.Overlaps(a)).To(BeTrue())
+			Expect(a.Overlaps(c)).To(BeFalse())
+			Expect(a.Overlaps(v6)).To(BeFalse())
+		})
+	})
+
+	Describe("FreeBlocks", func() {
+		It("skips used ranges", func() {
+			_, pool, _ := net.ParseCIDR("10.0.0.0/8")
+			used, _ := Parse("10.0.0.0/16, 10.1.5.0/24, 10.2.0.0-10.3.0.5")
+			blocks, err := FreeBlocks(pool, 16, 2, used)
+			Expect(err).To(BeNil())
+			Expect(blocks).To(HaveLen(2))
+			Expect(blocks[0].String()).To(Equal("10.4.0.0/16"))
+			Expect(blocks[1].String()).To(Equal("10.5.0.0/16"))
+
+			blocks, err = FreeBlocks(pool, 24, 1, used)
+			Expect(err).To(BeNil())
+			Expect(blocks[0].String()).To(Equal("10.1.0.0/24"))
+		})
+
+		It("fails when the pool runs out", func() {
+			_, pool, _ := net.ParseCIDR("192.168.0.0/23")
+			used, _ := Parse("192.168.0.0/24")
+			blocks, err := FreeBlocks(pool, 24, 2, used)
+			Expect(err).To(MatchError("no free /24 block left in the pool 192.168.0.0/23"))
+			Expect(blocks).To(HaveLen(1))
+
+			_, err = FreeBlocks(pool, 16, 1, nil)
+			Expect(err).To(MatchError("a /16 block does not fit in the pool 192.168.0.0/23"))
+		})
+	})
+
+	Describe("Next", func() {
+		It("returns the following address", func() {
+			Expect(Next(net.ParseIP("10.0.0.255")).String()).To(Equal("10.0.1.0"))
+			Expect(Next(net.ParseIP("fd00::ffff")).String()).To(Equal("fd00::1:0"))
+			Expect(Next(net.ParseIP("255.255.255.255"))).To(BeNil())
+		})
+	})
+
+	Describe("Compare", func() {
+		It("orders IPv4 addresses first", func() {
+			Expect(Compare(net.ParseIP("10.0.0.9"), net.ParseIP("10.0.0.10"))).To(BeNumerically("<", 0))
+			Expect(Compare(net.ParseIP("fd00::1"), net.ParseIP("10.0.0.1"))).To(BeNumerically(">", 0))
+			Expect(Compare(net.ParseIP("10.0.0.1"), net.ParseIP("::ffff:10.0.0.1"))).To(Equal(0))
+		})
+	})
+})
@@ -48,7 +48,7 @@ func BuildApp() *cli.App {
 		},
 		cli.StringFlag{
 			Name:  "output, o",
-			Usage: "select output format",
+			Usage: "select output format: json, or dot for network topology",
 		},
 		cli.BoolFlag{
 			Name:  "detail, d",
@@ -68,11 +68,14 @@ func BuildApp() *cli.App {
 		command.GetProjectsCommand(),
 		command.GetDiskCommand(),
 		command.GetVMCommand(),
+		command.GetInventoryCommand(),
 		command.GetServiceCommand(),
 		command.GetRoutersCommand(),
 		command.GetSubnetsCommand(),
+		command.GetNetworksCommand(),
 		command.GetZonesCommand(),
 		command.GetInfrastructureCommand(),
+		command.GetMigrateCommand(),
 	}
 	app.Before = func(c *cli.Context) error {
 		logFile := c.GlobalString("log-file")
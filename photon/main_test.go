@@ -10,6 +10,8 @@
 package main
 
 import (
+	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/urfave/cli"
@@ -93,6 +95,67 @@ func TestGlobalFlagSubcommand(t *testing.T) {
 	}
 }
 
+/*
+ * Tests that the output formats are validated before the command runs
+ * dot is accepted for network topology, unknown formats are rejected
+ */
+func TestGlobalOutputFlag(t *testing.T) {
+	// Errors returned by app.Before exit the process, record them instead.
+	exitCode := 0
+	defer func(exiter func(int), w io.Writer) {
+		cli.OsExiter = exiter
+		cli.ErrWriter = w
+	}(cli.OsExiter, cli.ErrWriter)
+	cli.OsExiter = func(code int) { exitCode = code }
+	cli.ErrWriter = ioutil.Discard
+
+	tests := []struct {
+		output  string
+		command []string
+	}{
+		{"json", []string{"subcommand", "nested"}},
+		{"dot", []string{"network", "topology"}},
+	}
+	for _, test := range tests {
+		state := &TestFlagState{interactive: true}
+		app := CreateGlobalFlagApp(state)
+		app.Writer = ioutil.Discard
+
+		args := append([]string{"program", "-n", "-o", test.output}, test.command...)
+		err := app.Run(args)
+		if err == nil {
+			t.Errorf("Expected --non-interactive and --output %s to be rejected", test.output)
+		}
+
+		args = append([]string{"program", "-o", test.output}, test.command...)
+		err = app.Run(args)
+		if err != nil {
+			t.Errorf("Expected output type '%s' to be accepted, got: %s", test.output, err)
+		}
+	}
+
+	rejected := []struct {
+		output  string
+		command []string
+		message string
+	}{
+		{"xml", []string{"test"}, "output type must be 'json'"},
+		{"dot", []string{"subcommand", "nested"}, "output type 'dot' is only supported by 'network topology'"},
+		{"dot", []string{"network"}, "output type 'dot' is only supported by 'network topology'"},
+	}
+	for _, test := range rejected {
+		state := &TestFlagState{interactive: true}
+		app := CreateGlobalFlagApp(state)
+		app.Writer = ioutil.Discard
+		exitCode = 0
+		args := append([]string{"program", "-o", test.output}, test.command...)
+		err := app.Run(args)
+		if err == nil || err.Error() != test.message || exitCode != 1 {
+			t.Errorf("Expected %v to be rejected, got: %v (exit code %d)", args, err, exitCode)
+		}
+	}
+}
+
 /*
  * Creates an application that sets global flag for interaction or scripting
  * t is the test state to determine if the flag is properly set
@@ -110,6 +173,17 @@ func CreateGlobalFlagApp(t *TestFlagState) *cli.App {
 				}
 			},
 		},
+		{
+			Name:  "network",
+			Usage: "network global test",
+			Subcommands: []cli.Command{
+				{
+					Name:   "topology",
+					Usage:  "subcommand that accepts the dot output type",
+					Action: func(c *cli.Context) {},
+				},
+			},
+		},
 		{
 			Name:  "subcommand",
 			Usage: "subcommand global test",
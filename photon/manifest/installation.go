@@ -10,70 +10,76 @@
 package manifest
 
 import (
-	"io/ioutil"
 	"regexp"
-
-	"gopkg.in/yaml.v2"
+	"sort"
 )
 
 type Installation struct {
-	Deployment deployment `yaml:"deployment"`
-	Hosts      []host     `yaml:"hosts"`
+	Deployment Deployment `yaml:"deployment"`
+	Hosts      []Host     `yaml:"hosts"`
+
+	// Files whose hosts are added to Hosts, relative to the manifest. It is
+	// empty once the manifest is loaded.
+	Include fileList `yaml:"include,omitempty"`
 }
 
-type deployment struct {
-	ResumeSystem            bool            `yaml:"resume_system"`
-	ImageDatastores         imageDatastores `yaml:"image_datastores"`
-	UseImageDatastoreForVms bool            `yaml:"use_image_datastore_for_vms"`
-
-	SyslogEndpoint interface{} `yaml:"syslog_endpoint"`
-	NTPEndpoint    interface{} `yaml:"ntp_endpoint"`
-
-	LoadBalancerEnabled string `yaml:"enable_loadbalancer"`
-
-	StatsEnabled       bool   `yaml:"stats_enabled"`
-	StatsStoreEndpoint string `yaml:"stats_store_endpoint"`
-	StatsPort          int    `yaml:"stats_port"`
-
-	AuthEnabled        bool     `yaml:"auth_enabled"`
-	AuthEndpoint       string   `yaml:"auth_endpoint"`
-	AuthPort           int      `yaml:"auth_port"`
-	AuthUsername       string   `yaml:"oauth_username"`
-	AuthPassword       string   `yaml:"oauth_password"`
-	AuthTenant         string   `yaml:"oauth_tenant"`
-	AuthSecurityGroups []string `yaml:"oauth_security_groups"`
-
-	SdnEnabled             bool     `yaml:"sdn_enabled"`
-	NetworkManagerAddress  string   `yaml:"network_manager_address"`
-	NetworkManagerUsername string   `yaml:"network_manager_username"`
-	NetworkManagerPassword string   `yaml:"network_manager_password"`
-	NetworkZoneId          string   `yaml:"network_zone_id"`
-	NetworkTopRouterId     string   `yaml:"network_top_router_id"`
-	NetworkEdgeIpPoolId    string   `yaml:"network_edge_ip_pool_id"`
-	NetworkHostUplinkPnic  string   `yaml:"network_host_uplink_pnic"`
-	NetworkIpRange         string   `yaml:"network_ip_range"`
-	NetworkExternalIpRange string   `yaml:"network_external_ip_range"`
-	NetworkDhcpServers     []string `yaml:"network_dhcp_servers"`
+type Deployment struct {
+	ResumeSystem            bool            `yaml:"resume_system,omitempty"`
+	ImageDatastores         imageDatastores `yaml:"image_datastores,omitempty"`
+	UseImageDatastoreForVms bool            `yaml:"use_image_datastore_for_vms,omitempty"`
+
+	SyslogEndpoint interface{} `yaml:"syslog_endpoint,omitempty"`
+	NTPEndpoint    interface{} `yaml:"ntp_endpoint,omitempty"`
+
+	LoadBalancerEnabled string `yaml:"enable_loadbalancer,omitempty"`
+
+	StatsEnabled       bool   `yaml:"stats_enabled,omitempty"`
+	StatsStoreEndpoint string `yaml:"stats_store_endpoint,omitempty"`
+	StatsPort          int    `yaml:"stats_port,omitempty"`
+
+	AuthEnabled        bool     `yaml:"auth_enabled,omitempty"`
+	AuthEndpoint       string   `yaml:"auth_endpoint,omitempty"`
+	AuthPort           int      `yaml:"auth_port,omitempty"`
+	AuthUsername       string   `yaml:"oauth_username,omitempty"`
+	AuthPassword       string   `yaml:"oauth_password,omitempty"`
+	AuthTenant         string   `yaml:"oauth_tenant,omitempty"`
+	AuthSecurityGroups []string `yaml:"oauth_security_groups,omitempty"`
+
+	SdnEnabled             bool     `yaml:"sdn_enabled,omitempty"`
+	NetworkManagerAddress  string   `yaml:"network_manager_address,omitempty"`
+	NetworkManagerUsername string   `yaml:"network_manager_username,omitempty"`
+	NetworkManagerPassword string   `yaml:"network_manager_password,omitempty"`
+	NetworkZoneId          string   `yaml:"network_zone_id,omitempty"`
+	NetworkTopRouterId     string   `yaml:"network_top_router_id,omitempty"`
+	NetworkEdgeIpPoolId    string   `yaml:"network_edge_ip_pool_id,omitempty"`
+	NetworkHostUplinkPnic  string   `yaml:"network_host_uplink_pnic,omitempty"`
+	NetworkIpRange         string   `yaml:"network_ip_range,omitempty"`
+	NetworkExternalIpRange string   `yaml:"network_external_ip_range,omitempty"`
+	NetworkDhcpServers     []string `yaml:"network_dhcp_servers,omitempty"`
 }
 
-type host struct {
-	IpRanges         string            `yaml:"address_ranges"`
-	Username         string            `yaml:"username"`
-	Password         string            `yaml:"password"`
-	AvailabilityZone string            `yaml:"availability_zone"`
-	Tags             []string          `yaml:"usage_tags"`
-	Metadata         map[string]string `yaml:"metadata"`
+type Host struct {
+	IpRanges         string            `yaml:"address_ranges,omitempty"`
+	Username         string            `yaml:"username,omitempty"`
+	Password         string            `yaml:"password,omitempty"`
+	AvailabilityZone string            `yaml:"availability_zone,omitempty"`
+	Tags             []string          `yaml:"usage_tags,omitempty"`
+	Metadata         map[string]string `yaml:"metadata,omitempty"`
 }
 
+// Loads the installation manifest and the hosts of the files it includes.
+// String values can use ${NAME} to insert environment variables, and can be
+// replaced by the content of a file or environment variable with the !file
+// and !env tags, e.g. "password: !file secrets/esx-password".
 func LoadInstallation(file string) (res *Installation, err error) {
-	buf, err := ioutil.ReadFile(file)
+	v := &validator{file: file}
+	res, _, err = v.load(file, nil)
 	if err != nil {
 		return nil, err
 	}
-	res = &Installation{}
-	err = yaml.Unmarshal(buf, res)
-	if err != nil {
-		return nil, err
+	if len(v.errors) != 0 {
+		sort.Stable(byPosition(v.errors))
+		return nil, v.errors
 	}
 	return
 }
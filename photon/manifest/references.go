@@ -0,0 +1,235 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Starts the values that stand for !file and !env references once the
+// manifest is read. The yaml package drops unknown tags, so tagged values
+// are rewritten with this marker before they are decoded.
+const referenceMarker = "\x00"
+
+var (
+	taggedValue = regexp.MustCompile(`(?m)^([ \t]*(?:- +)*(?:[\w.-]+: +)?)!(file|env) +("[^"]*"|'[^']*'|[^#\r\n]*?)(?: +#.*)?[ \t]*$`)
+	variable    = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// A manifest file, with its tagged values rewritten.
+type source struct {
+	file      string
+	abs       string
+	buf       []byte
+	positions *positions
+}
+
+// The file and index in its hosts section that a host was loaded from.
+type hostOrigin struct {
+	src   *source
+	index int
+}
+
+func readSource(file string) (*source, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	buf = taggedValue.ReplaceAllFunc(buf, func(match []byte) []byte {
+		groups := taggedValue.FindSubmatch(match)
+		value := strings.Trim(string(groups[3]), `"'`)
+		reference := referenceMarker + string(groups[2]) + ":" + value
+		return append(groups[1], strconv.Quote(reference)...)
+	})
+	return &source{file: file, abs: abs, buf: buf, positions: locate(buf)}, nil
+}
+
+// Loads the manifest and the hosts of the files it includes, resolving the
+// references in their values. included lists the absolute paths of the
+// files that include this one.
+func (v *validator) load(file string, included []string) (*Installation, []hostOrigin, error) {
+	src, err := readSource(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(included) == 0 {
+		v.main = src
+	}
+
+	if v.strict {
+		var raw interface{}
+		err = yaml.Unmarshal(src.buf, &raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		v.checkKeys(src, raw, reflect.TypeOf(Installation{}), "")
+	}
+	res := &Installation{}
+	err = yaml.Unmarshal(src.buf, res)
+	if typeError, ok := err.(*yaml.TypeError); ok && v.strict {
+		v.addTypeErrors(src, typeError)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(included) != 0 && !reflect.DeepEqual(res.Deployment, Deployment{}) {
+		v.report(src, "deployment", true, "included files can only define hosts")
+	}
+	v.resolveReferences(src, reflect.ValueOf(res).Elem(), "")
+
+	var origins []hostOrigin
+	for i := range res.Hosts {
+		origins = append(origins, hostOrigin{src, i})
+	}
+	for i, include := range res.Include {
+		path := fmt.Sprintf("include[%d]", i)
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+		abs, err := filepath.Abs(include)
+		if err != nil {
+			return nil, nil, err
+		}
+		if abs == src.abs || contains(included, abs) {
+			v.report(src, path, false, "file '%s' includes itself", include)
+			continue
+		}
+		if _, err = os.Stat(include); err != nil {
+			v.report(src, path, false, "cannot include '%s': %s", include, err)
+			continue
+		}
+
+		inst, includedOrigins, err := v.load(include, append(included, src.abs))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", include, err)
+		}
+		res.Hosts = append(res.Hosts, inst.Hosts...)
+		origins = append(origins, includedOrigins...)
+	}
+	res.Include = nil
+	return res, origins, nil
+}
+
+// Replaces ${NAME} variables and !file and !env references in the string
+// values found in value, reporting those that cannot be resolved.
+func (v *validator) resolveReferences(src *source, value reflect.Value, path string) {
+	switch value.Kind() {
+	case reflect.String:
+		resolved, err := resolveString(src, value.String())
+		if err != nil {
+			v.report(src, path, false, "%s", err)
+			return
+		}
+		value.SetString(resolved)
+	case reflect.Interface:
+		if s, ok := value.Interface().(string); ok {
+			resolved, err := resolveString(src, s)
+			if err != nil {
+				v.report(src, path, false, "%s", err)
+				return
+			}
+			value.Set(reflect.ValueOf(resolved))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			tag := strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			v.resolveReferences(src, value.Field(i), join(path, tag))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			v.resolveReferences(src, value.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(key))
+			v.resolveReferences(src, item, join(path, fmt.Sprint(key.Interface())))
+			value.SetMapIndex(key, item)
+		}
+	}
+}
+
+// Returns the value of a !file or !env reference, or s with its ${NAME}
+// variables replaced by the environment variables they name. Files are
+// relative to the manifest that references them.
+func resolveString(src *source, s string) (string, error) {
+	if strings.HasPrefix(s, referenceMarker) {
+		reference := strings.SplitN(strings.TrimPrefix(s, referenceMarker), ":", 2)
+		kind, name := reference[0], reference[1]
+		if kind == "env" {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable '%s' is not set", name)
+			}
+			return value, nil
+		}
+		file := name
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(src.file), file)
+		}
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret file '%s': %s", name, err)
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	}
+
+	var err error
+	res := variable.ReplaceAllStringFunc(s, func(match string) string {
+		name := variable.FindStringSubmatch(match)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable '%s' is not set", name)
+		}
+		return value
+	})
+	return res, err
+}
+
+// List of files, given either as a YAML sequence or as a single string.
+type fileList []string
+
+func (l *fileList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var files []string
+	err := unmarshal(&files)
+	if err == nil {
+		*l = files
+		return nil
+	}
+
+	var file string
+	err = unmarshal(&file)
+	if err != nil {
+		return err
+	}
+	*l = fileList{file}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
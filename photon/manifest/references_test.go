@@ -0,0 +1,197 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package manifest_test
+
+import (
+	. "github.com/vmware/photon-controller-cli/photon/manifest"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("References", func() {
+	var (
+		dir   string
+		files map[string]string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "references_")
+		if err != nil {
+			Fail("Could not create temporary test directory.")
+		}
+		files = map[string]string{}
+		_ = os.Setenv("PHOTON_TEST_USER", "root")
+		_ = os.Setenv("PHOTON_TEST_PASSWORD", "env-secret")
+		_ = os.Unsetenv("PHOTON_TEST_UNSET")
+	})
+
+	JustBeforeEach(func() {
+		for name, content := range files {
+			err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+			if err != nil {
+				Fail("Could not write test file " + name)
+			}
+		}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+		_ = os.Unsetenv("PHOTON_TEST_USER")
+		_ = os.Unsetenv("PHOTON_TEST_PASSWORD")
+	})
+
+	validationErrors := func() []string {
+		inst, err := ValidateInstallation(filepath.Join(dir, "installation.yml"))
+		Expect(inst).To(BeNil())
+		Expect(err).To(BeAssignableToTypeOf(ValidationErrors{}))
+
+		messages := []string{}
+		for _, e := range err.(ValidationErrors) {
+			messages = append(messages, e.Error())
+		}
+		return messages
+	}
+
+	Context("when values use variables and references", func() {
+		BeforeEach(func() {
+			files["esx-password"] = "file-secret\n"
+			files["installation.yml"] = `---
+deployment:
+  image_datastores: ds1
+  auth_enabled: true
+  auth_endpoint: 10.0.0.5
+  oauth_password: !env PHOTON_TEST_PASSWORD
+hosts:
+  - address_ranges: 10.0.0.1
+    username: ${PHOTON_TEST_USER}
+    password: !file esx-password # relative to the manifest
+    metadata:
+      MANAGEMENT_PORTGROUP: "${PHOTON_TEST_USER}-network"
+`
+		})
+
+		It("resolves them", func() {
+			inst, err := LoadInstallation(filepath.Join(dir, "installation.yml"))
+			Expect(err).To(BeNil())
+
+			Expect(inst.Deployment.AuthPassword).To(Equal("env-secret"))
+			Expect(inst.Hosts).To(HaveLen(1))
+			Expect(inst.Hosts[0].Username).To(Equal("root"))
+			Expect(inst.Hosts[0].Password).To(Equal("file-secret"))
+			Expect(inst.Hosts[0].Metadata["MANAGEMENT_PORTGROUP"]).To(Equal("root-network"))
+		})
+	})
+
+	Context("when references cannot be resolved", func() {
+		BeforeEach(func() {
+			files["installation.yml"] = `---
+hosts:
+  - address_ranges: 10.0.0.1
+    username: ${PHOTON_TEST_UNSET}
+    password: !file missing-password
+  - address_ranges: 10.0.0.2
+    username: root
+    password: !env PHOTON_TEST_UNSET
+`
+		})
+
+		It("reports the position of each value", func() {
+			Expect(validationErrors()).To(Equal([]string{
+				"line 4, column 15: hosts[0].username: environment variable 'PHOTON_TEST_UNSET' is not set",
+				"line 5, column 15: hosts[0].password: cannot read secret file 'missing-password': " +
+					"open " + filepath.Join(dir, "missing-password") + ": no such file or directory",
+				"line 8, column 15: hosts[1].password: environment variable 'PHOTON_TEST_UNSET' is not set",
+			}))
+		})
+
+		It("fails to load file", func() {
+			inst, err := LoadInstallation(filepath.Join(dir, "installation.yml"))
+			Expect(inst).To(BeNil())
+			Expect(err).To(BeAssignableToTypeOf(ValidationErrors{}))
+		})
+	})
+
+	Context("when the manifest includes other files", func() {
+		BeforeEach(func() {
+			files["installation.yml"] = `---
+deployment:
+  image_datastores: ds1
+hosts:
+  - address_ranges: 10.0.0.1
+    username: root
+    password: vmware
+include:
+  - rack1.yml
+  - rack2.yml
+`
+			files["rack1.yml"] = `---
+hosts:
+  - address_ranges: 10.0.0.2-10.0.0.3
+    username: root
+    password: !env PHOTON_TEST_PASSWORD
+`
+			files["rack2.yml"] = `---
+hosts:
+  - address_ranges: 10.0.0.4
+    username: root
+    password: vmware
+`
+		})
+
+		It("adds the hosts of each file", func() {
+			inst, err := ValidateInstallation(filepath.Join(dir, "installation.yml"))
+			Expect(err).To(BeNil())
+
+			Expect(inst.Include).To(BeEmpty())
+			Expect(inst.Hosts).To(HaveLen(3))
+			Expect(inst.Hosts[1].IpRanges).To(Equal("10.0.0.2-10.0.0.3"))
+			Expect(inst.Hosts[1].Password).To(Equal("env-secret"))
+			Expect(inst.Hosts[2].IpRanges).To(Equal("10.0.0.4"))
+		})
+	})
+
+	Context("when included files are invalid", func() {
+		BeforeEach(func() {
+			files["installation.yml"] = `---
+hosts:
+  - address_ranges: 10.0.0.1
+    username: root
+    password: vmware
+include: rack1.yml
+`
+			files["rack1.yml"] = `---
+deployment:
+  image_datastores: ds1
+hosts:
+  - address_ranges: 10.0.0.1
+    username: root
+    password: vmware
+include: installation.yml
+`
+		})
+
+		It("reports the file of each error", func() {
+			rack1 := filepath.Join(dir, "rack1.yml")
+			Expect(validationErrors()).To(Equal([]string{
+				rack1 + ": line 2, column 1: deployment: included files can only define hosts",
+				rack1 + ": line 5, column 21: hosts[0].address_ranges: duplicate host address '10.0.0.1', also in hosts[0] of " +
+					filepath.Join(dir, "installation.yml"),
+				rack1 + ": line 8, column 10: include[0]: file '" + filepath.Join(dir, "installation.yml") +
+					"' includes itself",
+			}))
+		})
+	})
+})
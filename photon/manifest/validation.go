@@ -0,0 +1,420 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/photon-controller-cli/photon/ipaddr"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Problem found in a manifest. Line and Column are counted from 1, and are
+// zero when the position is not known. File is set when the problem is in a
+// file included by the manifest.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	prefix := ""
+	if len(e.File) != 0 {
+		prefix = e.File + ": "
+	}
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%sline %d, column %d: %s", prefix, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%sline %d: %s", prefix, e.Line, e.Message)
+	}
+	return prefix + e.Message
+}
+
+// All the problems found in a manifest, in the order they appear in the file.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := []string{fmt.Sprintf("manifest has %d problem(s):", len(e))}
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Written in place of passwords by system export-manifest, the system does not
+// report them. It must be replaced before the manifest is used.
+const PasswordPlaceholder = "<password>"
+
+// Metadata keys of a host that hold IP addresses.
+var metadataAddressKeys = []string{
+	"MANAGEMENT_NETWORK_DNS_SERVER",
+	"MANAGEMENT_NETWORK_GATEWAY",
+	"MANAGEMENT_NETWORK_NETMASK",
+}
+
+// Loads the installation manifest and checks it for unknown keys, bad
+// addresses, overlapping hosts, missing credentials and inconsistent
+// deployment settings. All the problems found are returned as
+// ValidationErrors.
+func ValidateInstallation(file string) (*Installation, error) {
+	v := &validator{strict: true, file: file}
+	res, origins, err := v.load(file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v.checkHosts(res.Hosts, origins)
+	v.checkDeployment(&res.Deployment)
+	if len(v.errors) != 0 {
+		sort.Stable(byPosition(v.errors))
+		return nil, v.errors
+	}
+	return res, nil
+}
+
+// Collects the problems found while loading a manifest.
+type validator struct {
+	// Report unknown keys and values of the wrong type rather than failing on them.
+	strict bool
+	// The manifest being loaded, and its source once read.
+	file string
+	main *source
+
+	errors ValidationErrors
+}
+
+// Reports a problem with the value at path in src, or with the key itself
+// when atKey is true.
+func (v *validator) report(src *source, path string, atKey bool, format string, args ...interface{}) {
+	pos := src.positions.of(path, atKey)
+	message := fmt.Sprintf(format, args...)
+	if len(path) != 0 {
+		message = path + ": " + message
+	}
+	v.errors = append(v.errors, &ValidationError{
+		File:    v.fileName(src),
+		Line:    pos.line,
+		Column:  pos.column,
+		Message: message,
+	})
+}
+
+// Returns the name of the file to report problems in src with, which is
+// empty for the manifest itself.
+func (v *validator) fileName(src *source) string {
+	if src == v.main {
+		return ""
+	}
+	return src.file
+}
+
+// Reports the keys of raw that have no matching yaml tag in t.
+func (v *validator) checkKeys(src *source, raw interface{}, t reflect.Type, path string) {
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			fields[tag] = t.Field(i).Type
+		}
+		for key, value := range values {
+			name := fmt.Sprint(key)
+			fieldType, ok := fields[name]
+			if !ok {
+				v.report(src, join(path, name), true, "unknown key '%s'", name)
+				continue
+			}
+			v.checkKeys(src, value, fieldType, join(path, name))
+		}
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			v.checkKeys(src, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+var typeErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+func (v *validator) addTypeErrors(src *source, err *yaml.TypeError) {
+	for _, message := range err.Errors {
+		e := &ValidationError{File: v.fileName(src), Message: message}
+		if match := typeErrorLine.FindStringSubmatch(message); match != nil {
+			e.Line, _ = strconv.Atoi(match[1])
+			e.Message = match[2]
+		}
+		v.errors = append(v.errors, e)
+	}
+}
+
+type ownedRange struct {
+	ipaddr.Range
+	src  *source
+	path string
+}
+
+func (v *validator) checkHosts(hosts []Host, origins []hostOrigin) {
+	var seen []ownedRange
+	for i, host := range hosts {
+		src := origins[i].src
+		path := fmt.Sprintf("hosts[%d]", origins[i].index)
+
+		if len(host.Username) == 0 {
+			v.report(src, path, false, "username is required")
+		}
+		if len(host.Password) == 0 {
+			v.report(src, path, false, "password is required")
+		} else if host.Password == PasswordPlaceholder {
+			v.report(src, path+".password", false, "replace the %s placeholder with the host password", PasswordPlaceholder)
+		}
+		for _, key := range metadataAddressKeys {
+			value, ok := host.Metadata[key]
+			if !ok {
+				continue
+			}
+			if _, err := ipaddr.ParseIPs(value); err != nil {
+				v.report(src, path+".metadata."+key, false, "%s", err)
+			}
+		}
+		var vmRanges ipaddr.List
+		if value, ok := host.Metadata["MANAGEMENT_VM_IPS"]; ok {
+			var err error
+			vmRanges, err = ipaddr.Parse(value)
+			if err != nil {
+				v.report(src, path+".metadata.MANAGEMENT_VM_IPS", false, "%s", err)
+			}
+		}
+
+		if len(strings.TrimSpace(host.IpRanges)) == 0 {
+			v.report(src, path, false, "address_ranges is required")
+			continue
+		}
+		ranges, err := ipaddr.Parse(host.IpRanges)
+		if err != nil {
+			v.report(src, path+".address_ranges", false, "%s", err)
+			continue
+		}
+		for _, r := range ranges {
+			for _, other := range seen {
+				if r.Overlaps(other.Range) {
+					v.reportOverlap(src, path+".address_ranges", r, other)
+				}
+			}
+			seen = append(seen, ownedRange{r, src, path})
+		}
+		if vmRanges != nil && vmRanges.Size().Cmp(ranges.Size()) != 0 {
+			v.report(src, path+".metadata.MANAGEMENT_VM_IPS", false,
+				"has %s address(es) but address_ranges has %s host(s)", vmRanges.Size(), ranges.Size())
+		}
+	}
+}
+
+func (v *validator) reportOverlap(src *source, path string, r ipaddr.Range, other ownedRange) {
+	owner := other.path
+	if other.src != src {
+		owner += " of " + other.src.file
+	}
+	if r.Start.Equal(r.End) && other.Start.Equal(other.End) {
+		v.report(src, path, false, "duplicate host address '%s', also in %s", r.Text, owner)
+	} else {
+		v.report(src, path, false, "address range '%s' overlaps '%s' in %s", r.Text, other.Text, owner)
+	}
+}
+
+func (v *validator) checkDeployment(d *Deployment) {
+	if d.SdnEnabled {
+		if len(d.NetworkManagerAddress) == 0 {
+			v.report(v.main, "deployment.sdn_enabled", false, "network_manager_address is required when SDN is enabled")
+		}
+		if len(d.NetworkManagerUsername) == 0 || len(d.NetworkManagerPassword) == 0 {
+			v.report(v.main, "deployment.sdn_enabled", false,
+				"network_manager_username and network_manager_password are required when SDN is enabled")
+		}
+	}
+	for key, value := range map[string]string{
+		"network_manager_password": d.NetworkManagerPassword,
+		"oauth_password":           d.AuthPassword,
+	} {
+		if value == PasswordPlaceholder {
+			v.report(v.main, "deployment."+key, false, "replace the %s placeholder with the password", PasswordPlaceholder)
+		}
+	}
+	if d.AuthEnabled && len(d.AuthEndpoint) == 0 {
+		v.report(v.main, "deployment.auth_enabled", false, "auth_endpoint is required when auth is enabled")
+	}
+	if d.StatsEnabled && len(d.StatsStoreEndpoint) == 0 {
+		v.report(v.main, "deployment.stats_enabled", false, "stats_store_endpoint is required when stats are enabled")
+	}
+
+	for key, value := range map[string]string{
+		"network_ip_range":          d.NetworkIpRange,
+		"network_external_ip_range": d.NetworkExternalIpRange,
+	} {
+		if len(value) == 0 {
+			continue
+		}
+		if _, err := ipaddr.ParseRange(value); err != nil {
+			v.report(v.main, "deployment."+key, false, "%s, expected a CIDR or an address range", err)
+		}
+	}
+	for i, address := range d.NetworkDhcpServers {
+		if _, err := ipaddr.ParseIP(address); err != nil {
+			v.report(v.main, fmt.Sprintf("deployment.network_dhcp_servers[%d]", i), false, "%s", err)
+		}
+	}
+}
+
+func join(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+type byPosition ValidationErrors
+
+func (e byPosition) Len() int      { return len(e) }
+func (e byPosition) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byPosition) Less(i, j int) bool {
+	if e[i].File != e[j].File {
+		return e[i].File < e[j].File
+	}
+	if e[i].Line != e[j].Line {
+		return e[i].Line < e[j].Line
+	}
+	return e[i].Column < e[j].Column
+}
+
+// Position of a key or value in a manifest, counted from 1.
+type position struct {
+	line, column int
+}
+
+// Positions of the keys and values of a YAML document by path, such as
+// "hosts[1].metadata.MANAGEMENT_VM_IPS".
+type positions struct {
+	keys   map[string]position
+	values map[string]position
+}
+
+// Returns the position of the value at path, or of its key when atKey is
+// true. Paths that were not located, such as those inside flow style
+// collections, fall back to the position of their closest located parent.
+func (p *positions) of(path string, atKey bool) position {
+	for len(path) != 0 {
+		first, second := p.values, p.keys
+		if atKey {
+			first, second = p.keys, p.values
+		}
+		if pos, ok := first[path]; ok {
+			return pos
+		}
+		if pos, ok := second[path]; ok {
+			return pos
+		}
+		path = path[:strings.LastIndexAny(path, ".[")+1]
+		path = strings.TrimRight(path, ".[")
+	}
+	return position{}
+}
+
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"\[\]{},:-][^#]*?|-[^\s#][^#]*?)\s*:(\s+|$)`)
+
+type frame struct {
+	indent int
+	path   string
+	item   bool
+	items  int
+}
+
+// Scans the block style structure of a YAML document and records where
+// each key and value starts. The yaml package does not report positions of
+// decoded values, so this follows indentation the way the parser does for
+// mappings and sequences.
+func locate(buf []byte) *positions {
+	p := &positions{keys: map[string]position{}, values: map[string]position{}}
+	stack := []*frame{{indent: -1}}
+	scalarIndent := -1
+	for i, line := range strings.Split(string(buf), "\n") {
+		text := strings.TrimRight(line, " \t\r")
+		col := len(text) - len(strings.TrimLeft(text, " "))
+		if scalarIndent >= 0 {
+			// Skip the lines of a literal or folded block scalar.
+			if len(text) == 0 || col > scalarIndent {
+				continue
+			}
+			scalarIndent = -1
+		}
+		content := text[col:]
+		if len(content) == 0 || content[0] == '#' || content == "---" || content == "..." {
+			continue
+		}
+
+		for {
+			if content == "-" || strings.HasPrefix(content, "- ") {
+				for top := stack[len(stack)-1]; top.indent > col || (top.indent == col && top.item); top = stack[len(stack)-1] {
+					stack = stack[:len(stack)-1]
+				}
+				parent := stack[len(stack)-1]
+				path := fmt.Sprintf("%s[%d]", parent.path, parent.items)
+				parent.items++
+				stack = append(stack, &frame{indent: col, path: path, item: true})
+
+				rest := strings.TrimLeft(content[1:], " ")
+				if len(rest) == 0 {
+					p.values[path] = position{i + 1, col + 1}
+					break
+				}
+				col += len(content) - len(rest)
+				content = rest
+				p.values[path] = position{i + 1, col + 1}
+				continue
+			}
+
+			match := yamlKey.FindStringSubmatch(content)
+			if match == nil {
+				break
+			}
+			for top := stack[len(stack)-1]; top.indent >= col; top = stack[len(stack)-1] {
+				stack = stack[:len(stack)-1]
+			}
+			key := strings.Trim(match[1], `"'`)
+			path := join(stack[len(stack)-1].path, key)
+			p.keys[path] = position{i + 1, col + 1}
+			stack = append(stack, &frame{indent: col, path: path})
+
+			value := content[len(match[0]):]
+			if len(value) != 0 && value[0] != '#' {
+				p.values[path] = position{i + 1, col + len(match[0]) + 1}
+				if value[0] == '|' || value[0] == '>' {
+					scalarIndent = col
+				}
+			}
+			break
+		}
+	}
+	return p
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package manifest_test
+
+import (
+	. "github.com/vmware/photon-controller-cli/photon/manifest"
+
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validation", func() {
+	Describe("ValidateInstallation", func() {
+		var (
+			file        *os.File
+			fileContent string
+		)
+
+		JustBeforeEach(func() {
+			var err error
+			file, err = ioutil.TempFile("", "installation_")
+			if err != nil {
+				Fail("Could not create temporary test file.")
+			}
+
+			_, err = file.WriteString(fileContent)
+			if err != nil {
+				Fail("Could not write test file " + file.Name())
+			}
+
+			_ = file.Close()
+		})
+
+		AfterEach(func() {
+			if file != nil {
+				_ = os.Remove(file.Name())
+				file = nil
+			}
+		})
+
+		validationErrors := func() []string {
+			inst, err := ValidateInstallation(file.Name())
+			Expect(inst).To(BeNil())
+			Expect(err).To(BeAssignableToTypeOf(ValidationErrors{}))
+
+			messages := []string{}
+			for _, e := range err.(ValidationErrors) {
+				messages = append(messages, e.Error())
+			}
+			return messages
+		}
+
+		Context("when the manifest is valid", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  image_datastores: ds1
+  auth_enabled: true
+  auth_endpoint: 10.0.0.5
+hosts:
+  - address_ranges: 10.0.0.1-10.0.0.2
+    username: root
+    password: vmware
+    metadata:
+      MANAGEMENT_NETWORK_GATEWAY: 10.0.0.253
+      MANAGEMENT_VM_IPS: 10.0.0.11, 10.0.0.12
+  - address_ranges: 10.0.0.3
+    username: root
+    password: vmware
+`
+			})
+
+			It("loads successfully", func() {
+				inst, err := ValidateInstallation(file.Name())
+				Expect(err).To(BeNil())
+
+				Expect(inst.Hosts).To(HaveLen(2))
+				Expect(inst.Deployment.ImageDatastores).To(BeEquivalentTo([]string{"ds1"}))
+			})
+		})
+
+		Context("when addresses use CIDRs, exclusions and IPv6", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  network_dhcp_servers:
+    - fd00::53
+hosts:
+  - address_ranges: 10.0.0.0/29, !10.0.0.0, !10.0.0.7
+    username: root
+    password: vmware
+    metadata:
+      MANAGEMENT_VM_IPS: 10.0.1.1-10.0.1.6
+  - address_ranges: fd00::10-fd00::11
+    username: root
+    password: vmware
+  - address_ranges: 10.0.0.7, fd00::11
+    username: root
+    password: vmware
+`
+			})
+
+			It("reports overlaps with the remaining addresses only", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 14, column 21: hosts[2].address_ranges: address range 'fd00::11' overlaps 'fd00::10-fd00::11' in hosts[1]",
+				}))
+			})
+		})
+
+		Context("when the manifest has unknown keys", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  image_datastore: ds1
+hosts:
+  - adress_ranges: 10.0.0.1
+    address_ranges: 10.0.0.2
+    username: root
+    password: vmware
+`
+			})
+
+			It("reports the position of each key", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 3, column 3: deployment.image_datastore: unknown key 'image_datastore'",
+					"line 5, column 5: hosts[0].adress_ranges: unknown key 'adress_ranges'",
+				}))
+			})
+		})
+
+		Context("when addresses are malformed", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  network_dhcp_servers:
+    - 10.0.0.300
+hosts:
+  - address_ranges: 10.0.0.1-10.0.0.2-10.0.0.3
+    username: root
+    password: vmware
+  - address_ranges: 10.0.0.9 - 10.0.0.4
+    username: root
+    password: vmware
+    metadata:
+      MANAGEMENT_NETWORK_NETMASK: 255.255.255
+`
+			})
+
+			It("reports the position of each value", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 4, column 7: deployment.network_dhcp_servers[0]: bad IP address '10.0.0.300'",
+					"line 6, column 21: hosts[0].address_ranges: bad address range '10.0.0.1-10.0.0.2-10.0.0.3'",
+					"line 9, column 21: hosts[1].address_ranges: address range '10.0.0.9 - 10.0.0.4' ends before it starts",
+					"line 13, column 35: hosts[1].metadata.MANAGEMENT_NETWORK_NETMASK: bad IP address '255.255.255'",
+				}))
+			})
+		})
+
+		Context("when host addresses are repeated", func() {
+			BeforeEach(func() {
+				fileContent = `---
+hosts:
+  - address_ranges: 10.0.0.1, 10.0.0.5-10.0.0.9
+    username: root
+    password: vmware
+  - address_ranges: 10.0.0.1
+    username: root
+    password: vmware
+  - address_ranges: 10.0.0.8-10.0.0.12
+    username: root
+    password: vmware
+`
+			})
+
+			It("reports duplicate and overlapping addresses", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 6, column 21: hosts[1].address_ranges: duplicate host address '10.0.0.1', also in hosts[0]",
+					"line 9, column 21: hosts[2].address_ranges: address range '10.0.0.8-10.0.0.12' overlaps '10.0.0.5-10.0.0.9' in hosts[0]",
+				}))
+			})
+		})
+
+		Context("when the management VM addresses do not match the hosts", func() {
+			BeforeEach(func() {
+				fileContent = `---
+hosts:
+  - address_ranges: 10.0.0.1-10.0.0.3
+    username: root
+    password: vmware
+    metadata:
+      MANAGEMENT_VM_IPS: 10.0.0.11-10.0.0.12
+`
+			})
+
+			It("reports the count mismatch", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 7, column 26: hosts[0].metadata.MANAGEMENT_VM_IPS: has 2 address(es) but address_ranges has 3 host(s)",
+				}))
+			})
+		})
+
+		Context("when credentials are missing", func() {
+			BeforeEach(func() {
+				fileContent = `---
+hosts:
+  - address_ranges: 10.0.0.1
+    username: root
+  - {address_ranges: 10.0.0.2}
+`
+			})
+
+			It("reports the host entries", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 3, column 5: hosts[0]: password is required",
+					"line 5, column 5: hosts[1]: username is required",
+					"line 5, column 5: hosts[1]: password is required",
+				}))
+			})
+		})
+
+		Context("when exported password placeholders were not replaced", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  sdn_enabled: true
+  network_manager_address: 10.0.0.5
+  network_manager_username: admin
+  network_manager_password: <password>
+hosts:
+  - address_ranges: 10.0.0.1
+    username: root
+    password: <password>
+`
+			})
+
+			It("reports the placeholders", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 6, column 29: deployment.network_manager_password: replace the <password> placeholder with the password",
+					"line 10, column 15: hosts[0].password: replace the <password> placeholder with the host password",
+				}))
+			})
+		})
+
+		Context("when deployment settings are inconsistent", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  sdn_enabled: true
+  network_manager_username: admin
+  network_manager_password: secret
+  auth_enabled: true
+`
+			})
+
+			It("reports the settings that enable them", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 3, column 16: deployment.sdn_enabled: network_manager_address is required when SDN is enabled",
+					"line 6, column 17: deployment.auth_enabled: auth_endpoint is required when auth is enabled",
+				}))
+			})
+		})
+
+		Context("when a value has the wrong type", func() {
+			BeforeEach(func() {
+				fileContent = `---
+deployment:
+  resume_system: other_value
+`
+			})
+
+			It("reports the line", func() {
+				Expect(validationErrors()).To(Equal([]string{
+					"line 3: cannot unmarshal !!str `other_v...` into bool",
+				}))
+			})
+		})
+	})
+})
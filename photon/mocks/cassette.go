@@ -0,0 +1,281 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package mocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Environment variable that switches cassette tests to record mode. It holds
+// the endpoint of the deployment to record from, e.g. https://10.0.0.1:9000.
+const RecordEndpointEnv = "PHOTON_RECORD_ENDPOINT"
+
+// Value that replaces tokens, passwords and other secrets in recorded interactions.
+const Scrubbed = "<scrubbed>"
+
+// Keys of JSON fields and query parameters whose values are scrubbed.
+var secretKey = regexp.MustCompile(`(?i)(password|token|secret|ticket)`)
+
+// Cassette is a list of recorded HTTP interactions.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// Interaction is a recorded request and the response it received.
+type Interaction struct {
+	Request  RecordedRequest  `yaml:"request"`
+	Response RecordedResponse `yaml:"response"`
+}
+
+// RecordedRequest is the part of a request that is used to match it on replay.
+type RecordedRequest struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Query  string `yaml:"query,omitempty"`
+	Body   string `yaml:"body,omitempty"`
+}
+
+// RecordedResponse is the part of a response that is replayed.
+type RecordedResponse struct {
+	StatusCode int    `yaml:"status"`
+	Body       string `yaml:"body,omitempty"`
+}
+
+// Reports problems found while replaying, implemented by *testing.T.
+type TestReporter interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette
+// file, or replays them from it.
+//
+// Requests match a recorded interaction on method, path, query and body;
+// JSON bodies are compared after sorting their keys and scrubbing secrets.
+// Interactions are replayed in the order they were recorded, so repeated
+// requests such as task polls get successive responses. Requests that do
+// not match are reported as errors, and so are interactions left unused when
+// the recorder is stopped.
+type Recorder struct {
+	path      string
+	recording bool
+	transport http.RoundTripper
+	reporter  TestReporter
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// Creates a recorder for the cassette file. It records through transport
+// when recording is true, and replays the cassette otherwise.
+func NewRecorder(reporter TestReporter, path string, recording bool, transport http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		recording: recording,
+		transport: transport,
+		reporter:  reporter,
+	}
+	if recording {
+		return r, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, &r.cassette)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cassette '%s': %s", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Creates a recorder for the cassette file, and returns it with the endpoint
+// to point the client at. It records from the deployment named by
+// PHOTON_RECORD_ENDPOINT when that is set, and replays otherwise.
+func NewCassette(reporter TestReporter, path string) (*Recorder, string, error) {
+	endpoint := os.Getenv(RecordEndpointEnv)
+	if len(endpoint) != 0 {
+		transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+		r, err := NewRecorder(reporter, path, true, transport)
+		return r, endpoint, err
+	}
+	r, err := NewRecorder(reporter, path, false, nil)
+	return r, "http://cassette.invalid", err
+}
+
+// Returns true if the recorder records interactions rather than replaying them.
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// RoundTrip records or replays the request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording {
+		return r.record(req, recorded)
+	}
+
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] && interaction.Request == recorded {
+			r.used[i] = true
+			return replayResponse(req, interaction.Response), nil
+		}
+	}
+	r.reporter.Errorf("Unexpected request not in cassette '%s': %s %s?%s %s",
+		r.path, recorded.Method, recorded.Path, recorded.Query, recorded.Body)
+	return nil, fmt.Errorf("no interaction recorded for %s %s", recorded.Method, recorded.Path)
+}
+
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{StatusCode: res.StatusCode, Body: normalizeBody(body, r.hostPrefix(req))},
+	})
+	return res, nil
+}
+
+// Returns the scheme and host of the request, which are stripped from
+// recorded links so the cassette replays against any endpoint.
+func (r *Recorder) hostPrefix(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host
+}
+
+// Stops the recorder. In record mode it writes the cassette file, in replay
+// mode it reports the interactions that were not used.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.recording {
+		data, err := yaml.Marshal(r.cassette)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(r.path, data, 0644)
+	}
+
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			r.reporter.Errorf("Interaction %d in cassette '%s' was not used: %s %s",
+				i, r.path, interaction.Request.Method, interaction.Request.Path)
+		}
+	}
+	return nil
+}
+
+// Returns the matchable, scrubbed part of the request. The body is read and
+// put back so the request can still be sent.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  scrubQuery(req.URL.Query()),
+	}
+	if req.Body == nil {
+		return recorded, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return recorded, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	// Multipart boundaries are random, replace them with a fixed one.
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err == nil && len(params["boundary"]) != 0 {
+		body = bytes.Replace(body, []byte(params["boundary"]), []byte("BOUNDARY"), -1)
+	}
+	recorded.Body = normalizeBody(body, "")
+	return recorded, nil
+}
+
+func scrubQuery(query url.Values) string {
+	for key := range query {
+		if secretKey.MatchString(key) {
+			query.Set(key, Scrubbed)
+		}
+	}
+	return query.Encode()
+}
+
+// Returns a JSON body re-encoded with sorted keys and scrubbed secrets, and
+// with hostPrefix removed from the links it contains. Other bodies are
+// returned unchanged.
+func normalizeBody(body []byte, hostPrefix string) string {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return string(body)
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(scrub(v))
+	if err != nil {
+		return string(body)
+	}
+	normalized := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if len(hostPrefix) != 0 {
+		normalized = bytes.Replace(normalized, []byte(hostPrefix), nil, -1)
+	}
+	return string(normalized)
+}
+
+func scrub(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, isString := value.(string); isString && secretKey.MatchString(key) {
+				v[key] = Scrubbed
+			} else {
+				v[key] = scrub(value)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = scrub(v[i])
+		}
+	}
+	return v
+}
+
+func replayResponse(req *http.Request, recorded RecordedResponse) *http.Response {
+	response, _ := CreateResponder(recorded.StatusCode, recorded.Body)(req)
+	return response
+}
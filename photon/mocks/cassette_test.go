@@ -0,0 +1,110 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package mocks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type reporter struct {
+	errors []string
+}
+
+func (r *reporter) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "cassette.yaml")
+
+	server := NewTestServerWithBody(`{"id":"task1","state":"QUEUED","token":"abc"}`)
+	defer server.Close()
+
+	recorder, err := NewRecorder(t, path, true, http.DefaultTransport)
+	if err != nil {
+		t.Fatal("Not expecting error creating recorder: " + err.Error())
+	}
+	client := &http.Client{Transport: recorder}
+	res, err := client.Post(server.URL+"/v1/infrastructure/hosts?access_token=xyz", "application/json",
+		strings.NewReader(`{"username":"root","address":"10.0.0.1","password":"secret"}`))
+	if err != nil {
+		t.Fatal("Not expecting error recording request: " + err.Error())
+	}
+	_ = res.Body.Close()
+	err = recorder.Stop()
+	if err != nil {
+		t.Fatal("Not expecting error writing cassette: " + err.Error())
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret", "xyz", "abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected '%s' to be scrubbed from the cassette:\n%s", secret, data)
+		}
+	}
+
+	// Same request with its keys in a different order and another password.
+	r := &reporter{}
+	recorder, err = NewRecorder(r, path, false, nil)
+	if err != nil {
+		t.Fatal("Not expecting error loading cassette: " + err.Error())
+	}
+	client = &http.Client{Transport: recorder}
+	res, err = client.Post("http://other/v1/infrastructure/hosts?access_token=123", "application/json",
+		strings.NewReader(`{"address":"10.0.0.1","password":"other","username":"root"}`))
+	if err != nil {
+		t.Fatal("Not expecting error replaying request: " + err.Error())
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if string(body) != `{"id":"task1","state":"QUEUED","token":"<scrubbed>"}` {
+		t.Errorf("Unexpected replayed body: %s", body)
+	}
+
+	_, err = client.Get("http://other/v1/tasks/task1")
+	if err == nil || len(r.errors) != 1 {
+		t.Errorf("Expected an unexpected request to be reported, got %v", r.errors)
+	}
+	err = recorder.Stop()
+	if err != nil {
+		t.Fatal("Not expecting error stopping recorder: " + err.Error())
+	}
+	if len(r.errors) != 1 {
+		t.Errorf("Not expecting unused interactions, got %v", r.errors)
+	}
+
+	r = &reporter{}
+	recorder, err = NewRecorder(r, path, false, nil)
+	if err != nil {
+		t.Fatal("Not expecting error loading cassette: " + err.Error())
+	}
+	err = recorder.Stop()
+	if err != nil {
+		t.Fatal("Not expecting error stopping recorder: " + err.Error())
+	}
+	if len(r.errors) != 1 {
+		t.Errorf("Expected the unused interaction to be reported, got %v", r.errors)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"net/http/httptest"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+type image struct {
+	photon.Image
+	iam []photon.PolicyEntry
+}
+
+// Adds a ready image to the simulator and returns its ID.
+func (sim *Simulator) AddImage(name string, size int64) string {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	i := sim.addImage(seedRequest(), name, size, "EAGER", photon.ImageScope{Kind: "infrastructure"})
+	sim.imageReady(i)
+	return i.ID
+}
+
+// Adds a ready flavor to the simulator and returns its ID.
+func (sim *Simulator) AddFlavor(spec photon.FlavorCreateSpec) string {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	f := sim.addFlavor(seedRequest(), &spec)
+	f.State = "READY"
+	return f.ID
+}
+
+// Returns a request to build self links for entities added without a request.
+func seedRequest() *request {
+	return &request{r: httptest.NewRequest("GET", "http://simulator/v1", nil)}
+}
+
+func (sim *Simulator) addImage(req *request, name string, size int64, replication string, scope photon.ImageScope) *image {
+	if len(replication) == 0 {
+		replication = "EAGER"
+	}
+	id := sim.newID("image")
+	i := &image{
+		Image: photon.Image{
+			Size:                size,
+			Kind:                "image",
+			Name:                name,
+			State:               "CREATING",
+			ID:                  id,
+			Tags:                []string{},
+			Scope:               scope,
+			SelfLink:            selfLink(req, "images/"+id),
+			Settings:            []photon.ImageSetting{},
+			ReplicationType:     replication,
+			ReplicationProgress: "0.0%",
+			SeedingProgress:     "0.0%",
+		},
+		iam: []photon.PolicyEntry{},
+	}
+	sim.images[id] = i
+	return i
+}
+
+// ON_DEMAND images are only copied to a datastore when a VM uses them there,
+// so their replication does not progress when they become ready.
+func (sim *Simulator) imageReady(i *image) {
+	i.State = "READY"
+	if i.ReplicationType != "ON_DEMAND" {
+		i.ReplicationProgress = "100.0%"
+	}
+	i.SeedingProgress = "100.0%"
+}
+
+// Uploads an image in the given scope.
+func (sim *Simulator) uploadImage(req *request, scope photon.ImageScope) {
+	name, size, fields, err := readUpload(req.r)
+	if err != nil {
+		req.badRequest("InvalidEntity", "reading image: %s", err)
+		return
+	}
+	i := sim.addImage(req, name, size, fields["ImageReplication"], scope)
+	task := sim.newTask(req, "CREATE_IMAGE", photon.Entity{ID: i.ID, Kind: "image"},
+		"UPLOAD_IMAGE", "REPLICATE_IMAGE")
+	task.onStep = func(step int) {
+		if step == 0 {
+			i.SeedingProgress = "100.0%"
+		}
+	}
+	task.onComplete = func() { sim.imageReady(i) }
+	task.onError = func() { delete(sim.images, i.ID) }
+	sim.startTask(req, task)
+}
+
+func (sim *Simulator) listImages(req *request, scope photon.ImageScope) {
+	images := []photon.Image{}
+	for _, id := range sim.sortedIDs(sim.images) {
+		i := sim.images[id]
+		if scope.Kind == "project" && i.Scope != scope {
+			continue
+		}
+		if name := req.query("name"); len(name) == 0 || i.Name == name {
+			images = append(images, i.Image)
+		}
+	}
+	sim.writeList(req, images)
+}
+
+// Serves /projects/{id}/images.
+func (sim *Simulator) serveProjectImages(req *request, p *project) {
+	scope := photon.ImageScope{Kind: "project", ID: p.ID}
+	switch {
+	case req.is("GET", 3):
+		sim.listImages(req, scope)
+	case req.is("POST", 3):
+		sim.uploadImage(req, scope)
+	default:
+		req.notFound()
+	}
+}
+
+// Serves /images.
+func (sim *Simulator) serveImages(req *request) {
+	if req.is("GET", 1) {
+		sim.listImages(req, photon.ImageScope{Kind: "infrastructure"})
+		return
+	}
+	if req.is("POST", 1) {
+		sim.uploadImage(req, photon.ImageScope{Kind: "infrastructure"})
+		return
+	}
+
+	i, ok := sim.images[req.segs[1]]
+	if !ok {
+		req.entityNotFound("image", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: i.ID, Kind: "image"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(i.Image)
+	case req.is("DELETE", 2):
+		task := sim.newTask(req, "DELETE_IMAGE", entity)
+		task.onComplete = func() { delete(sim.images, i.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "images", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, i.ID))
+	case len(req.segs) == 3 && req.segs[2] == "iam":
+		sim.serveIam(req, &i.iam, entity)
+	default:
+		req.notFound()
+	}
+}
+
+func (sim *Simulator) findFlavor(name, kind string) (*photon.Flavor, bool) {
+	for _, f := range sim.flavors {
+		if f.Name == name && f.Kind == kind {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func (sim *Simulator) addFlavor(req *request, spec *photon.FlavorCreateSpec) *photon.Flavor {
+	id := sim.newID("flavor")
+	f := &photon.Flavor{
+		Cost:     spec.Cost,
+		Kind:     spec.Kind,
+		Name:     spec.Name,
+		ID:       id,
+		Tags:     []string{},
+		SelfLink: selfLink(req, "flavors/"+id),
+		State:    "CREATING",
+	}
+	sim.flavors[id] = f
+	return f
+}
+
+// Serves /flavors.
+func (sim *Simulator) serveFlavors(req *request) {
+	if req.is("GET", 1) {
+		flavors := []photon.Flavor{}
+		for _, id := range sim.sortedIDs(sim.flavors) {
+			f := sim.flavors[id]
+			if name := req.query("name"); len(name) != 0 && f.Name != name {
+				continue
+			}
+			if kind := req.query("kind"); len(kind) != 0 && f.Kind != kind {
+				continue
+			}
+			flavors = append(flavors, *f)
+		}
+		sim.writeList(req, flavors)
+		return
+	}
+	if req.is("POST", 1) {
+		spec := &photon.FlavorCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		if _, ok := sim.findFlavor(spec.Name, spec.Kind); ok {
+			req.badRequest("NameTaken", "flavor '%s' of kind '%s' already exists", spec.Name, spec.Kind)
+			return
+		}
+		f := sim.addFlavor(req, spec)
+		task := sim.newTask(req, "CREATE_FLAVOR", photon.Entity{ID: f.ID, Kind: "flavor"})
+		task.onComplete = func() { f.State = "READY" }
+		task.onError = func() { delete(sim.flavors, f.ID) }
+		sim.startTask(req, task)
+		return
+	}
+
+	f, ok := sim.flavors[req.segs[1]]
+	if !ok {
+		req.entityNotFound("flavor", req.segs[1])
+		return
+	}
+	switch {
+	case req.is("GET", 2):
+		req.ok(f)
+	case req.is("DELETE", 2):
+		task := sim.newTask(req, "DELETE_FLAVOR", photon.Entity{ID: f.ID, Kind: "flavor"})
+		task.onComplete = func() { delete(sim.flavors, f.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "flavors", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, f.ID))
+	default:
+		req.notFound()
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// State transitions of the host operations, see vmOperations.
+var hostOperations = map[string]struct {
+	operation string
+	from      []string
+	to        string
+}{
+	"suspend":           {"SUSPEND_HOST", []string{"READY"}, "SUSPENDED"},
+	"resume":            {"RESUME_HOST", []string{"SUSPENDED"}, "READY"},
+	"enter-maintenance": {"ENTER_MAINTENANCE_MODE", []string{"READY", "SUSPENDED"}, "MAINTENANCE"},
+	"exit-maintenance":  {"EXIT_MAINTENANCE_MODE", []string{"MAINTENANCE"}, "READY"},
+	"provision":         {"PROVISION_HOST", []string{"READY", "NOT_PROVISIONED", "ERROR"}, "READY"},
+}
+
+// Serves /infrastructure.
+func (sim *Simulator) serveInfrastructure(req *request) {
+	switch {
+	case len(req.segs) >= 2 && req.segs[1] == "hosts":
+		sim.serveHosts(req)
+	case req.match("GET", "infrastructure", "datastores"):
+		datastores := []photon.Datastore{}
+		for _, id := range sim.sortedIDs(sim.datastores) {
+			datastores = append(datastores, *sim.datastores[id])
+		}
+		req.ok(photon.Datastores{Items: datastores})
+	case req.match("GET", "infrastructure", "datastores", "*"):
+		d, ok := sim.datastores[req.segs[2]]
+		if !ok {
+			req.entityNotFound("datastore", req.segs[2])
+			return
+		}
+		req.ok(d)
+	case req.match("POST", "infrastructure", "sync-hosts-config"):
+		sim.startTask(req, sim.newTask(req, "SYNC_HOSTS_CONFIG", photon.Entity{Kind: "deployment"}))
+	case req.match("POST", "infrastructure", "image-datastores"):
+		spec := &photon.ImageDatastores{}
+		if !req.decode(spec) {
+			return
+		}
+		sim.system.ImageDatastores = spec.Items
+		sim.startTask(req, sim.newTask(req, "UPDATE_IMAGE_DATASTORES", photon.Entity{Kind: "deployment"}))
+	default:
+		req.notFound()
+	}
+}
+
+// Serves /infrastructure/hosts.
+func (sim *Simulator) serveHosts(req *request) {
+	if req.is("GET", 2) {
+		hosts := []photon.Host{}
+		for _, id := range sim.sortedIDs(sim.hosts) {
+			hosts = append(hosts, *sim.hosts[id])
+		}
+		sim.writeList(req, hosts)
+		return
+	}
+	if req.is("POST", 2) {
+		sim.createHost(req)
+		return
+	}
+
+	h, ok := sim.hosts[req.segs[2]]
+	if !ok {
+		req.entityNotFound("host", req.segs[2])
+		return
+	}
+	entity := photon.Entity{ID: h.ID, Kind: "host"}
+	if op, ok := hostOperations[req.segs[len(req.segs)-1]]; ok && req.is("POST", 4) {
+		allowed := false
+		for _, state := range op.from {
+			allowed = allowed || h.State == state
+		}
+		if !allowed {
+			req.badRequest("InvalidHostState", "cannot %s host %s in state %s", req.segs[3], h.ID, h.State)
+			return
+		}
+		task := sim.newTask(req, op.operation, entity)
+		task.onComplete = func() { h.State = op.to }
+		sim.startTask(req, task)
+		return
+	}
+
+	switch {
+	case req.is("GET", 3):
+		req.ok(h)
+	case req.is("DELETE", 3):
+		if len(sim.hostVMs(h)) != 0 {
+			req.badRequest("HostHasVms", "host %s has VMs", h.ID)
+			return
+		}
+		task := sim.newTask(req, "DELETE_HOST", entity)
+		task.onComplete = func() {
+			delete(sim.hosts, h.ID)
+			delete(sim.datastores, "datastore-"+h.ID)
+		}
+		sim.startTask(req, task)
+	case req.match("GET", "infrastructure", "hosts", "*", "vms"):
+		sim.writeList(req, sim.hostVMs(h))
+	case req.match("GET", "infrastructure", "hosts", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, h.ID))
+	case req.match("POST", "infrastructure", "hosts", "*", "set_availability_zone"):
+		spec := &photon.HostSetAvailabilityZoneOperation{}
+		if !req.decode(spec) {
+			return
+		}
+		if _, ok := sim.zones[spec.AvailabilityZoneId]; !ok {
+			req.entityNotFound("availability-zone", spec.AvailabilityZoneId)
+			return
+		}
+		h.Zone = spec.AvailabilityZoneId
+		sim.startTask(req, sim.newTask(req, "SET_AVAILABILITYZONE", entity))
+	default:
+		req.notFound()
+	}
+}
+
+// Adds the host in CREATING state, it becomes READY with its local
+// datastore once the task completes.
+func (sim *Simulator) createHost(req *request) {
+	spec := &photon.HostCreateSpec{}
+	if !req.decode(spec) {
+		return
+	}
+	if len(spec.Address) == 0 || len(spec.Username) == 0 || len(spec.Password) == 0 {
+		req.badRequest("InvalidEntity", "address, username and password are required")
+		return
+	}
+	for _, h := range sim.hosts {
+		if h.Address == spec.Address {
+			req.badRequest("HostExists", "host with address %s already exists", spec.Address)
+			return
+		}
+	}
+	if len(spec.Zone) != 0 {
+		if _, ok := sim.zones[spec.Zone]; !ok {
+			req.entityNotFound("availability-zone", spec.Zone)
+			return
+		}
+	}
+
+	id := sim.newID("host")
+	h := &photon.Host{
+		Username:   spec.Username,
+		Address:    spec.Address,
+		Kind:       "host",
+		ID:         id,
+		Zone:       spec.Zone,
+		Tags:       spec.Tags,
+		Metadata:   spec.Metadata,
+		SelfLink:   selfLink(req, "infrastructure/hosts/"+id),
+		State:      "CREATING",
+		EsxVersion: "6.0.0",
+	}
+	sim.hosts[id] = h
+	task := sim.newTask(req, "CREATE_HOST", photon.Entity{ID: id, Kind: "host"},
+		"CREATE_HOST", "PROVISION_HOST")
+	task.onComplete = func() {
+		h.State = "READY"
+		datastoreID := "datastore-" + id
+		sim.datastores[datastoreID] = &photon.Datastore{
+			Kind:     "datastore",
+			Type:     "LOCAL_VMFS",
+			Tags:     []string{},
+			ID:       datastoreID,
+			SelfLink: selfLink(req, "infrastructure/datastores/"+datastoreID),
+		}
+		sim.seq[datastoreID] = sim.seq[id]
+	}
+	task.onError = func() { delete(sim.hosts, id) }
+	sim.startTask(req, task)
+}
+
+func (sim *Simulator) hostVMs(h *photon.Host) []photon.VM {
+	vms := []photon.VM{}
+	for _, id := range sim.sortedIDs(sim.vms) {
+		if v := sim.vms[id]; v.Host == h.Address {
+			vms = append(vms, v.VM)
+		}
+	}
+	return vms
+}
+
+// Serves /zones.
+func (sim *Simulator) serveZones(req *request) {
+	if req.is("GET", 1) {
+		zones := []photon.Zone{}
+		for _, id := range sim.sortedIDs(sim.zones) {
+			zones = append(zones, *sim.zones[id])
+		}
+		sim.writeList(req, zones)
+		return
+	}
+	if req.is("POST", 1) {
+		spec := &photon.ZoneCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		for _, z := range sim.zones {
+			if z.Name == spec.Name {
+				req.badRequest("NameTaken", "availability zone name '%s' is already taken", spec.Name)
+				return
+			}
+		}
+		id := sim.newID("zone")
+		z := &photon.Zone{
+			Kind:     "availability-zone",
+			Name:     spec.Name,
+			State:    "CREATING",
+			ID:       id,
+			SelfLink: selfLink(req, "zones/"+id),
+		}
+		sim.zones[id] = z
+		task := sim.newTask(req, "CREATE_AVAILABILITYZONE", photon.Entity{ID: id, Kind: "availability-zone"})
+		task.onComplete = func() { z.State = "READY" }
+		task.onError = func() { delete(sim.zones, id) }
+		sim.startTask(req, task)
+		return
+	}
+
+	z, ok := sim.zones[req.segs[1]]
+	if !ok {
+		req.entityNotFound("availability-zone", req.segs[1])
+		return
+	}
+	switch {
+	case req.is("GET", 2):
+		req.ok(z)
+	case req.is("DELETE", 2):
+		for _, h := range sim.hosts {
+			if h.Zone == z.ID {
+				req.badRequest("ContainerNotEmpty", "availability zone %s has hosts", z.ID)
+				return
+			}
+		}
+		task := sim.newTask(req, "DELETE_AVAILABILITYZONE", photon.Entity{ID: z.ID, Kind: "availability-zone"})
+		task.onComplete = func() { delete(sim.zones, z.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "zones", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, z.ID))
+	default:
+		req.notFound()
+	}
+}
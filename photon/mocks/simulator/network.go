@@ -0,0 +1,357 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+type router struct {
+	photon.Router
+	projectID string
+}
+
+type subnet struct {
+	photon.Subnet
+	// Empty for physical subnets.
+	routerID string
+	// Number of addresses handed out to VMs.
+	allocated int
+}
+
+func (sim *Simulator) addRouter(req *request, projectID, name, cidr string, isDefault bool) *router {
+	id := sim.newID("router")
+	r := &router{
+		Router: photon.Router{
+			ID:            id,
+			Kind:          "router",
+			Name:          name,
+			PrivateIpCidr: cidr,
+			IsDefault:     isDefault,
+		},
+		projectID: projectID,
+	}
+	sim.routers[id] = r
+	return r
+}
+
+func (sim *Simulator) addSubnet(req *request, routerID, name, cidr string, isDefault bool) *subnet {
+	id := sim.newID("subnet")
+	s := &subnet{
+		Subnet: photon.Subnet{
+			ID:                 id,
+			Kind:               "subnet",
+			Name:               name,
+			PrivateIpCidr:      cidr,
+			ReservedIps:        map[string]string{},
+			State:              "READY",
+			IsDefault:          isDefault,
+			PortGroups:         photon.PortGroups{Names: []string{}},
+			DnsServerAddresses: []string{},
+		},
+		routerID: routerID,
+	}
+	if _, n, err := parseCidr(cidr); err == nil {
+		s.ReservedIps["GATEWAY"] = hostIP(n, 1)
+	}
+	sim.subnets[id] = s
+	return s
+}
+
+func (sim *Simulator) deleteRouter(id string) {
+	for subnetID, s := range sim.subnets {
+		if s.routerID == id {
+			delete(sim.subnets, subnetID)
+		}
+	}
+	delete(sim.routers, id)
+}
+
+// Returns the default subnet of a project, or the default physical subnet.
+func (sim *Simulator) defaultSubnet(projectID string) *subnet {
+	for _, id := range sim.sortedIDs(sim.subnets) {
+		s := sim.subnets[id]
+		if !s.IsDefault {
+			continue
+		}
+		if len(s.routerID) == 0 || sim.routers[s.routerID].projectID == projectID {
+			return s
+		}
+	}
+	return nil
+}
+
+func (sim *Simulator) subnetInUse(id string) bool {
+	for _, v := range sim.vms {
+		for _, n := range v.networks {
+			if n.subnetID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Serves /projects/{id}/routers.
+func (sim *Simulator) serveProjectRouters(req *request, p *project) {
+	switch {
+	case req.is("GET", 3):
+		routers := []photon.Router{}
+		for _, id := range sim.sortedIDs(sim.routers) {
+			r := sim.routers[id]
+			if r.projectID != p.ID {
+				continue
+			}
+			if name := req.query("name"); len(name) == 0 || r.Name == name {
+				routers = append(routers, r.Router)
+			}
+		}
+		sim.writeList(req, routers)
+	case req.is("POST", 3):
+		spec := &photon.RouterCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		_, n, err := parseCidr(spec.PrivateIpCidr)
+		if err != nil {
+			req.badRequest("InvalidEntity", "invalid private ip cidr '%s'", spec.PrivateIpCidr)
+			return
+		}
+		for _, r := range sim.routers {
+			if r.projectID == p.ID && r.Name == spec.Name {
+				req.badRequest("NameTaken", "router name '%s' is already taken", spec.Name)
+				return
+			}
+		}
+		r := sim.addRouter(req, p.ID, spec.Name, n.String(), false)
+		task := sim.newTask(req, "CREATE_ROUTER", photon.Entity{ID: r.ID, Kind: "router"})
+		task.onError = func() { sim.deleteRouter(r.ID) }
+		sim.startTask(req, task)
+	default:
+		req.notFound()
+	}
+}
+
+// Serves /routers.
+func (sim *Simulator) serveRouters(req *request) {
+	if len(req.segs) < 2 {
+		req.notFound()
+		return
+	}
+	r, ok := sim.routers[req.segs[1]]
+	if !ok {
+		req.entityNotFound("router", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: r.ID, Kind: "router"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(r.Router)
+	case req.is("PATCH", 2):
+		spec := &photon.RouterUpdateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		r.Name = spec.RouterName
+		sim.startTask(req, sim.newTask(req, "UPDATE_ROUTER", entity))
+	case req.is("DELETE", 2):
+		if r.IsDefault {
+			req.badRequest("InvalidOperation", "the default router of a project cannot be deleted")
+			return
+		}
+		for _, s := range sim.subnets {
+			if s.routerID == r.ID && sim.subnetInUse(s.ID) {
+				req.badRequest("ContainerNotEmpty", "router %s has subnets in use", r.ID)
+				return
+			}
+		}
+		task := sim.newTask(req, "DELETE_ROUTER", entity)
+		task.onComplete = func() { sim.deleteRouter(r.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "routers", "*", "subnets"):
+		subnets := []photon.Subnet{}
+		for _, id := range sim.sortedIDs(sim.subnets) {
+			s := sim.subnets[id]
+			if s.routerID != r.ID {
+				continue
+			}
+			if name := req.query("name"); len(name) == 0 || s.Name == name {
+				subnets = append(subnets, s.Subnet)
+			}
+		}
+		sim.writeList(req, subnets)
+	case req.match("POST", "routers", "*", "subnets"):
+		sim.createSubnet(req, r)
+	default:
+		req.notFound()
+	}
+}
+
+// Creates a subnet on a router, or a physical subnet when r is nil.
+func (sim *Simulator) createSubnet(req *request, r *router) {
+	spec := &photon.SubnetCreateSpec{}
+	if !req.decode(spec) {
+		return
+	}
+	routerID := ""
+	cidr := spec.PrivateIpCidr
+	if r != nil {
+		routerID = r.ID
+		_, n, err := parseCidr(spec.PrivateIpCidr)
+		if err != nil {
+			req.badRequest("InvalidEntity", "invalid private ip cidr '%s'", spec.PrivateIpCidr)
+			return
+		}
+		_, routerNet, _ := parseCidr(r.PrivateIpCidr)
+		if !contains(routerNet, n) {
+			req.badRequest("InvalidEntity", "subnet %s is not within router range %s", n, r.PrivateIpCidr)
+			return
+		}
+		for _, s := range sim.subnets {
+			if s.routerID != r.ID {
+				continue
+			}
+			if _, other, err := parseCidr(s.PrivateIpCidr); err == nil && overlaps(n, other) {
+				req.badRequest("InvalidEntity", "subnet %s overlaps subnet '%s' (%s)", n, s.Name, s.PrivateIpCidr)
+				return
+			}
+		}
+		cidr = n.String()
+	}
+	for _, s := range sim.subnets {
+		if s.routerID == routerID && s.Name == spec.Name {
+			req.badRequest("NameTaken", "subnet name '%s' is already taken", spec.Name)
+			return
+		}
+	}
+
+	s := sim.addSubnet(req, routerID, spec.Name, cidr, false)
+	s.Description = spec.Description
+	if spec.PortGroups.Names != nil {
+		s.PortGroups = spec.PortGroups
+	}
+	if spec.DnsServerAddresses != nil {
+		s.DnsServerAddresses = spec.DnsServerAddresses
+	}
+	task := sim.newTask(req, "CREATE_SUBNET", photon.Entity{ID: s.ID, Kind: "subnet"})
+	task.onError = func() { delete(sim.subnets, s.ID) }
+	sim.startTask(req, task)
+}
+
+// Serves /subnets.
+func (sim *Simulator) serveSubnets(req *request) {
+	if req.is("GET", 1) {
+		subnets := []photon.Subnet{}
+		for _, id := range sim.sortedIDs(sim.subnets) {
+			s := sim.subnets[id]
+			if name := req.query("name"); len(name) == 0 || s.Name == name {
+				subnets = append(subnets, s.Subnet)
+			}
+		}
+		sim.writeList(req, subnets)
+		return
+	}
+	if req.is("POST", 1) {
+		sim.createSubnet(req, nil)
+		return
+	}
+
+	s, ok := sim.subnets[req.segs[1]]
+	if !ok {
+		req.entityNotFound("subnet", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: s.ID, Kind: "subnet"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(s.Subnet)
+	case req.is("PATCH", 2):
+		spec := &photon.SubnetUpdateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		s.Name = spec.SubnetName
+		sim.startTask(req, sim.newTask(req, "UPDATE_SUBNET", entity))
+	case req.is("DELETE", 2):
+		if sim.subnetInUse(s.ID) {
+			req.badRequest("ContainerNotEmpty", "subnet %s has VMs connected to it", s.ID)
+			return
+		}
+		task := sim.newTask(req, "DELETE_SUBNET", entity)
+		task.onComplete = func() { delete(sim.subnets, s.ID) }
+		sim.startTask(req, task)
+	case req.match("POST", "subnets", "*", "set_default"):
+		for _, other := range sim.subnets {
+			if other.routerID == s.routerID ||
+				(len(s.routerID) != 0 && len(other.routerID) != 0 &&
+					sim.routers[other.routerID].projectID == sim.routers[s.routerID].projectID) {
+				other.IsDefault = false
+			}
+		}
+		s.IsDefault = true
+		sim.startTask(req, sim.newTask(req, "SET_DEFAULT_SUBNET", entity))
+	default:
+		req.notFound()
+	}
+}
+
+// Parses an IPv4 CIDR, the simulator does not hand out IPv6 addresses.
+func parseCidr(cidr string) (net.IP, *net.IPNet, error) {
+	ip, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ip.To4() == nil {
+		return nil, nil, fmt.Errorf("%s is not an IPv4 cidr", cidr)
+	}
+	return ip, n, nil
+}
+
+// Returns the first block with the given prefix length in n, or n itself if it is smaller.
+func firstSubnet(n *net.IPNet, prefix int) string {
+	ones, bits := n.Mask.Size()
+	if ones >= prefix {
+		return n.String()
+	}
+	first := net.IPNet{IP: n.IP, Mask: net.CIDRMask(prefix, bits)}
+	return first.String()
+}
+
+func contains(outer, inner *net.IPNet) bool {
+	outerOnes, _ := outer.Mask.Size()
+	innerOnes, _ := inner.Mask.Size()
+	return outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Returns the n-th address of the network.
+func hostIP(network *net.IPNet, n int) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(network.IP.To4())+uint32(n))
+	return ip.String()
+}
+
+// Hands out the next address of the subnet, starting at .10 to leave room
+// for the gateway and other reserved addresses.
+func (s *subnet) allocateIP() (ip string, netmask string) {
+	_, n, err := parseCidr(s.PrivateIpCidr)
+	if err != nil {
+		return "", ""
+	}
+	s.allocated++
+	return hostIP(n, 9+s.allocated), net.IP(n.Mask).String()
+}
@@ -0,0 +1,318 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+type service struct {
+	photon.Service
+	spec photon.ServiceCreateSpec
+}
+
+// Serves /projects/{id}/services.
+func (sim *Simulator) serveProjectServices(req *request, p *project) {
+	switch {
+	case req.is("GET", 3):
+		services := []photon.Service{}
+		for _, id := range sim.sortedIDs(sim.services) {
+			if s := sim.services[id]; s.ProjectID == p.ID {
+				services = append(services, s.Service)
+			}
+		}
+		sim.writeList(req, services)
+	case req.is("POST", 3):
+		sim.createService(req, p)
+	default:
+		req.notFound()
+	}
+}
+
+// Creates the service with its etcd, master and worker VMs, they are
+// started as the task completes the matching step.
+func (sim *Simulator) createService(req *request, p *project) {
+	spec := &photon.ServiceCreateSpec{}
+	if !req.decode(spec) {
+		return
+	}
+	if spec.Type != "KUBERNETES" && spec.Type != "HARBOR" {
+		req.badRequest("InvalidEntity", "unsupported service type '%s'", spec.Type)
+		return
+	}
+	for _, s := range sim.services {
+		if s.ProjectID == p.ID && s.Name == spec.Name {
+			req.badRequest("NameTaken", "service name '%s' is already taken", spec.Name)
+			return
+		}
+	}
+	imageID := spec.ImageID
+	if len(imageID) == 0 {
+		for _, config := range sim.system.ServiceConfigurations {
+			if config.Type == spec.Type {
+				imageID = config.ImageID
+			}
+		}
+	}
+	if i, ok := sim.images[imageID]; !ok || i.State != "READY" {
+		req.badRequest("InvalidEntity", "no ready image for service type '%s'", spec.Type)
+		return
+	}
+	if len(spec.SubnetId) != 0 {
+		if _, ok := sim.subnets[spec.SubnetId]; !ok {
+			req.entityNotFound("subnet", spec.SubnetId)
+			return
+		}
+	}
+
+	id := sim.newID("service")
+	properties := map[string]string{}
+	for key, value := range spec.ExtendedProperties {
+		properties[key] = value
+	}
+	s := &service{
+		Service: photon.Service{
+			Kind:               "service",
+			Name:               spec.Name,
+			State:              "CREATING",
+			ID:                 id,
+			Type:               spec.Type,
+			ImageID:            imageID,
+			ProjectID:          p.ID,
+			ClientID:           "client-" + id,
+			WorkerCount:        spec.WorkerCount,
+			SelfLink:           selfLink(req, "services/"+id),
+			ExtendedProperties: properties,
+		},
+		spec: *spec,
+	}
+	if spec.Type != "KUBERNETES" {
+		s.WorkerCount = 0
+	}
+	sim.services[id] = s
+
+	etcds := sim.addServiceNodes(req, s, "etcd", nodeCount(properties[photon.ExtendedPropertyNumberOfETCDs], spec.Type == "KUBERNETES"))
+	masters := sim.addServiceNodes(req, s, "master", nodeCount(properties[photon.ExtendedPropertyNumberOfMasters], true))
+	workers := sim.addServiceNodes(req, s, "worker", s.WorkerCount)
+	if len(masters) != 0 {
+		if len(properties[photon.ExtendedPropertyMasterIP]) == 0 && len(masters[0].networks) != 0 {
+			properties[photon.ExtendedPropertyMasterIP] = masters[0].networks[0].ipAddress
+		}
+		if len(properties[photon.ExtendedPropertyLoadBalancerIP]) == 0 {
+			properties[photon.ExtendedPropertyLoadBalancerIP] = properties[photon.ExtendedPropertyMasterIP]
+		}
+		properties[photon.ExtendedPropertyMasterIPs] = properties[photon.ExtendedPropertyMasterIP]
+	}
+
+	nodes := [][]*vm{etcds, masters, workers}
+	task := sim.newTask(req, "CREATE_SERVICE", photon.Entity{ID: id, Kind: "service"},
+		"SETUP_ETCD", "SETUP_MASTER", "SETUP_WORKERS")
+	task.onStep = func(step int) {
+		for _, v := range nodes[step] {
+			v.State = "STARTED"
+		}
+	}
+	task.onComplete = func() { s.State = "READY" }
+	task.onError = func() {
+		s.State = "ERROR"
+		s.ErrorReason = task.fault.Message
+	}
+	sim.startTask(req, task)
+}
+
+// Returns the number of nodes requested, which defaults to one if the
+// node type is used at all.
+func nodeCount(requested string, used bool) int {
+	if n, err := strconv.Atoi(requested); err == nil {
+		return n
+	}
+	if used {
+		return 1
+	}
+	return 0
+}
+
+// Adds n VMs of the given role to the service, numbered after the existing ones.
+func (sim *Simulator) addServiceNodes(req *request, s *service, role string, n int) []*vm {
+	flavor := s.spec.VMFlavor
+	if role == "master" && len(s.spec.MasterVmFlavor) != 0 {
+		flavor = s.spec.MasterVmFlavor
+	}
+	if role == "worker" && len(s.spec.WorkerVmFlavor) != 0 {
+		flavor = s.spec.WorkerVmFlavor
+	}
+	subnets := []string{}
+	if len(s.spec.SubnetId) != 0 {
+		subnets = append(subnets, s.spec.SubnetId)
+	} else if subnet := sim.defaultSubnet(s.ProjectID); subnet != nil {
+		subnets = append(subnets, subnet.ID)
+	}
+
+	existing := len(sim.serviceNodes(s, role))
+	nodes := []*vm{}
+	for i := 0; i < n; i++ {
+		v := sim.addVM(req, s.ProjectID, &photon.VmCreateSpec{
+			Flavor:        flavor,
+			SourceImageID: s.ImageID,
+			Name:          fmt.Sprintf("%s-%s-%d", role, s.ID, existing+i+1),
+			Tags:          []string{fmt.Sprintf("service:%s:%s", s.ID, role)},
+		}, subnets)
+		nodes = append(nodes, v)
+	}
+	return nodes
+}
+
+// Returns the VMs of the service, or only those with the given role when it is not empty.
+func (sim *Simulator) serviceNodes(s *service, role string) []*vm {
+	prefix := "service:" + s.ID + ":"
+	nodes := []*vm{}
+	for _, id := range sim.sortedIDs(sim.vms) {
+		v := sim.vms[id]
+		for _, tag := range v.Tags {
+			if tag == prefix+role || (len(role) == 0 && strings.HasPrefix(tag, prefix)) {
+				nodes = append(nodes, v)
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// Serves /services.
+func (sim *Simulator) serveServices(req *request) {
+	if len(req.segs) < 2 {
+		req.notFound()
+		return
+	}
+	s, ok := sim.services[req.segs[1]]
+	if !ok {
+		req.entityNotFound("service", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: s.ID, Kind: "service"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(s.Service)
+	case req.is("DELETE", 2):
+		s.State = "PENDING_DELETE"
+		task := sim.newTask(req, "DELETE_SERVICE", entity)
+		task.onComplete = func() {
+			for _, v := range sim.serviceNodes(s, "") {
+				delete(sim.vms, v.ID)
+			}
+			delete(sim.services, s.ID)
+		}
+		sim.startTask(req, task)
+	case req.match("GET", "services", "*", "vms"):
+		vms := []photon.VM{}
+		for _, v := range sim.serviceNodes(s, "") {
+			vms = append(vms, v.VM)
+		}
+		sim.writeList(req, vms)
+	case req.match("POST", "services", "*", "resize"):
+		spec := &photon.ServiceResizeOperation{}
+		if !req.decode(spec) {
+			return
+		}
+		sim.resizeService(req, s, spec.NewWorkerCount)
+	case req.match("POST", "services", "*", "trigger_maintenance"):
+		sim.startTask(req, sim.newTask(req, "TRIGGER_SERVICE_MAINTENANCE", entity))
+	case req.match("POST", "services", "*", "change_version"):
+		spec := &photon.ServiceChangeVersionOperation{}
+		if !req.decode(spec) {
+			return
+		}
+		sim.upgradeService(req, s, spec.NewImageID)
+	default:
+		req.notFound()
+	}
+}
+
+func (sim *Simulator) resizeService(req *request, s *service, workers int) {
+	if s.State != "READY" {
+		req.badRequest("InvalidServiceState", "service %s is %s", s.ID, s.State)
+		return
+	}
+	if s.Type != "KUBERNETES" || workers < 1 {
+		req.badRequest("InvalidEntity", "cannot resize service %s to %d workers", s.ID, workers)
+		return
+	}
+
+	current := sim.serviceNodes(s, "worker")
+	added := []*vm{}
+	if workers > len(current) {
+		added = sim.addServiceNodes(req, s, "worker", workers-len(current))
+	}
+	s.State = "RESIZING"
+	task := sim.newTask(req, "RESIZE_SERVICE", photon.Entity{ID: s.ID, Kind: "service"})
+	task.onComplete = func() {
+		for _, v := range added {
+			v.State = "STARTED"
+		}
+		for i := workers; i < len(current); i++ {
+			delete(sim.vms, current[i].ID)
+		}
+		s.WorkerCount = workers
+		s.State = "READY"
+	}
+	task.onError = func() {
+		for _, v := range added {
+			delete(sim.vms, v.ID)
+		}
+		s.State = "READY"
+	}
+	sim.startTask(req, task)
+}
+
+// Replaces the nodes of the service one at a time with nodes running the
+// new image, the upgrade status reports the progress.
+func (sim *Simulator) upgradeService(req *request, s *service, imageID string) {
+	if s.State != "READY" {
+		req.badRequest("InvalidServiceState", "service %s is %s", s.ID, s.State)
+		return
+	}
+	if i, ok := sim.images[imageID]; !ok || i.State != "READY" {
+		req.badRequest("InvalidImageState", "image %s is not ready", imageID)
+		return
+	}
+
+	nodes := sim.serviceNodes(s, "")
+	steps := []string{}
+	for range nodes {
+		steps = append(steps, "UPGRADE_NODE")
+	}
+	s.State = "UPGRADING"
+	s.UpgradeStatus = &photon.ServiceUpgradeStatus{
+		NewImageID:     imageID,
+		UpgradeMessage: "Upgrading service nodes",
+		TotalNodes:     len(nodes),
+	}
+	task := sim.newTask(req, "UPGRADE_SERVICE", photon.Entity{ID: s.ID, Kind: "service"}, steps...)
+	task.onStep = func(step int) {
+		if step < len(nodes) {
+			nodes[step].SourceImageID = imageID
+			s.UpgradeStatus.NumNodesUpgraded = step + 1
+		}
+	}
+	task.onComplete = func() {
+		s.ImageID = imageID
+		s.State = "READY"
+		s.UpgradeStatus.UpgradeResultMessage = "Upgrade completed"
+	}
+	task.onError = func() {
+		s.State = "ERROR"
+		s.UpgradeStatus.UpgradeResultMessage = task.fault.Message
+	}
+	sim.startTask(req, task)
+}
@@ -0,0 +1,391 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+// Package simulator implements an in-memory Photon Controller API.
+//
+// Unlike the responders in the mocks package, the simulator keeps state: a VM
+// created through the API shows up in the project's VM list, starting it
+// changes its state, and deleting it removes it. Every operation returns a
+// task that advances one step each time it is polled, so commands that wait
+// on tasks see them progress the same way they do against a deployment.
+//
+// A simulator can be used from tests:
+//
+//	server := simulator.NewServer()
+//	defer server.Close()
+//	client.Photonclient = server.NewClient()
+//
+// or served on a port for local demos:
+//
+//	http.ListenAndServe(":9000", simulator.New())
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Simulator is an http.Handler that serves the /v1 Photon Controller API from memory.
+type Simulator struct {
+	// Limits the number of items returned per page by list calls. 0 disables paging.
+	PageSize int
+
+	mu     sync.Mutex
+	nextID int
+	seq    map[string]int
+	faults []*Fault
+
+	tenants    map[string]*tenant
+	projects   map[string]*project
+	vms        map[string]*vm
+	disks      map[string]*disk
+	images     map[string]*image
+	flavors    map[string]*photon.Flavor
+	hosts      map[string]*photon.Host
+	zones      map[string]*photon.Zone
+	datastores map[string]*photon.Datastore
+	routers    map[string]*router
+	subnets    map[string]*subnet
+	services   map[string]*service
+	tasks      map[string]*task
+	system     photon.SystemInfo
+	systemVMs  []photon.VM
+
+	// Error to report in the next task created, set by a Fault with FailTask.
+	taskFault *photon.ApiError
+}
+
+// Server is a Simulator listening on a local port, for use in tests.
+type Server struct {
+	*httptest.Server
+	*Simulator
+}
+
+// Fault describes an error the simulator returns instead of handling a request.
+type Fault struct {
+	// HTTP method to match, any method when empty.
+	Method string
+	// Path below /v1 to match, '*' matches a single segment, e.g. "/vms/*/start".
+	Path string
+	// HTTP status of the error response, 500 when zero.
+	StatusCode int
+	// Error returned in the response body, or in the failed task step.
+	Error photon.ApiError
+	// When set, the request is accepted but the task it creates ends in ERROR.
+	FailTask bool
+	// Number of requests the fault applies to, every matching request when zero.
+	Times int
+}
+
+// Creates an empty simulator.
+func New() *Simulator {
+	return &Simulator{
+		seq:        map[string]int{},
+		tenants:    map[string]*tenant{},
+		projects:   map[string]*project{},
+		vms:        map[string]*vm{},
+		disks:      map[string]*disk{},
+		images:     map[string]*image{},
+		flavors:    map[string]*photon.Flavor{},
+		hosts:      map[string]*photon.Host{},
+		zones:      map[string]*photon.Zone{},
+		datastores: map[string]*photon.Datastore{},
+		routers:    map[string]*router{},
+		subnets:    map[string]*subnet{},
+		services:   map[string]*service{},
+		tasks:      map[string]*task{},
+		system: photon.SystemInfo{
+			Kind:            "deployment",
+			State:           "READY",
+			ImageDatastores: []string{},
+			BaseVersion:     "1.2.0",
+			FullVersion:     "1.2.0-simulator",
+			GitCommitHash:   "simulator",
+			NetworkType:     "PHYSICAL",
+			Auth:            &photon.AuthInfo{},
+		},
+	}
+}
+
+// Starts a simulator on a local port. The caller should call Close when finished.
+func NewServer() *Server {
+	sim := New()
+	return &Server{Server: httptest.NewServer(sim), Simulator: sim}
+}
+
+// Creates a photon client that talks to the server.
+func (s *Server) NewClient() *photon.Client {
+	options := &photon.ClientOptions{TaskPollDelay: time.Millisecond}
+	return photon.NewTestClient(s.URL, options, &http.Client{})
+}
+
+// Makes matching requests fail, see Fault.
+func (sim *Simulator) InjectFault(f Fault) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.faults = append(sim.faults, &f)
+}
+
+// Removes all injected faults.
+func (sim *Simulator) ClearFaults() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.faults = nil
+}
+
+// Runs every task that has not finished to completion, for tests that do
+// not poll the tasks they start.
+func (sim *Simulator) CompletePendingTasks() {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for _, id := range sim.sortedIDs(sim.tasks) {
+		t := sim.tasks[id]
+		for t.State == "QUEUED" || t.State == "STARTED" {
+			sim.advance(t)
+		}
+	}
+}
+
+func (sim *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, "/v1/") {
+		sim.writeError(w, http.StatusNotFound, "NotFound", "unknown path "+r.URL.Path)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	if sim.applyFault(w, r.Method, path) {
+		return
+	}
+
+	req := &request{w: w, r: r, segs: strings.Split(strings.Trim(path, "/"), "/")}
+	switch req.segs[0] {
+	case "tenants":
+		sim.serveTenants(req)
+	case "projects":
+		sim.serveProjects(req)
+	case "vms":
+		sim.serveVMs(req)
+	case "disks":
+		sim.serveDisks(req)
+	case "images":
+		sim.serveImages(req)
+	case "flavors":
+		sim.serveFlavors(req)
+	case "tasks":
+		sim.serveTasks(req)
+	case "infrastructure":
+		sim.serveInfrastructure(req)
+	case "zones":
+		sim.serveZones(req)
+	case "routers":
+		sim.serveRouters(req)
+	case "subnets":
+		sim.serveSubnets(req)
+	case "services":
+		sim.serveServices(req)
+	case "system":
+		sim.serveSystem(req)
+	case "info":
+		sim.serveInfo(req)
+	default:
+		req.notFound()
+	}
+}
+
+// Returns true if a fault was written as the response.
+func (sim *Simulator) applyFault(w http.ResponseWriter, method, path string) bool {
+	for i, f := range sim.faults {
+		if (len(f.Method) != 0 && f.Method != method) || !pathMatches(f.Path, path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				sim.faults = append(sim.faults[:i], sim.faults[i+1:]...)
+			}
+		}
+		if f.FailTask {
+			apiError := f.Error
+			sim.taskFault = &apiError
+			return false
+		}
+		status := f.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		sim.writeJSON(w, status, f.Error)
+		return true
+	}
+	return false
+}
+
+func pathMatches(pattern, path string) bool {
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegs) != len(pathSegs) {
+		return false
+	}
+	for i := range patternSegs {
+		if patternSegs[i] != "*" && patternSegs[i] != pathSegs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A request being served, with the path below /v1 split into segments.
+type request struct {
+	w    http.ResponseWriter
+	r    *http.Request
+	segs []string
+}
+
+// Returns true if the request has the given method and number of path segments.
+func (req *request) is(method string, segments int) bool {
+	return req.r.Method == method && len(req.segs) == segments
+}
+
+// Returns true if the request has the given method and path segments, '*'
+// matches any single segment.
+func (req *request) match(method string, segs ...string) bool {
+	if req.r.Method != method || len(req.segs) != len(segs) {
+		return false
+	}
+	for i := range segs {
+		if segs[i] != "*" && segs[i] != req.segs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (req *request) query(key string) string {
+	return req.r.URL.Query().Get(key)
+}
+
+// Decodes the JSON body into v, writes an error response and returns false if it fails.
+func (req *request) decode(v interface{}) bool {
+	err := json.NewDecoder(req.r.Body).Decode(v)
+	if err != nil {
+		writeError(req.w, http.StatusBadRequest, "InvalidJson", err.Error())
+		return false
+	}
+	return true
+}
+
+func (req *request) notFound() {
+	writeError(req.w, http.StatusNotFound, "NotFound", "unknown path "+req.r.URL.Path)
+}
+
+func (req *request) entityNotFound(kind, id string) {
+	writeError(req.w, http.StatusNotFound, kindCode(kind)+"NotFound", fmt.Sprintf("%s %s not found", kind, id))
+}
+
+func (req *request) badRequest(code, format string, args ...interface{}) {
+	writeError(req.w, http.StatusBadRequest, code, fmt.Sprintf(format, args...))
+}
+
+func (req *request) ok(v interface{}) {
+	writeJSON(req.w, http.StatusOK, v)
+}
+
+func kindCode(kind string) string {
+	code := ""
+	for _, part := range strings.Split(kind, "-") {
+		if len(part) != 0 {
+			code += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return code
+}
+
+func (sim *Simulator) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	writeJSON(w, status, v)
+}
+
+func (sim *Simulator) writeError(w http.ResponseWriter, status int, code, message string) {
+	writeError(w, status, code, message)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// The status is already sent, an encoding error can only be seen by the client.
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, photon.ApiError{Code: code, Message: message})
+}
+
+// Writes a list response. items must be a slice; it is paged when PageSize is set.
+func (sim *Simulator) writeList(req *request, items interface{}) {
+	v := reflect.ValueOf(items)
+	offset, _ := strconv.Atoi(req.query("pageLink"))
+	if offset > v.Len() {
+		offset = v.Len()
+	}
+	end := v.Len()
+	if sim.PageSize > 0 && offset+sim.PageSize < end {
+		end = offset + sim.PageSize
+	}
+
+	page := map[string]interface{}{"items": v.Slice(offset, end).Interface()}
+	if end < v.Len() {
+		query := req.r.URL.Query()
+		query.Set("pageLink", strconv.Itoa(end))
+		page["nextPageLink"] = req.r.URL.Path + "?" + query.Encode()
+	}
+	if offset > 0 {
+		query := req.r.URL.Query()
+		query.Set("pageLink", strconv.Itoa(0))
+		page["previousPageLink"] = req.r.URL.Path + "?" + query.Encode()
+	}
+	req.ok(page)
+}
+
+// Returns a new ID with the given prefix, IDs sort in creation order.
+func (sim *Simulator) newID(prefix string) string {
+	sim.nextID++
+	id := fmt.Sprintf("%s-%06d", prefix, sim.nextID)
+	sim.seq[id] = sim.nextID
+	return id
+}
+
+// Returns the keys of a map of entities in creation order.
+func (sim *Simulator) sortedIDs(entities interface{}) []string {
+	ids := []string{}
+	for _, key := range reflect.ValueOf(entities).MapKeys() {
+		ids = append(ids, key.String())
+	}
+	sort.Slice(ids, func(i, j int) bool { return sim.seq[ids[i]] < sim.seq[ids[j]] })
+	return ids
+}
+
+func selfLink(req *request, path string) string {
+	u := url.URL{Scheme: "http", Host: req.r.Host, Path: "/v1/" + path}
+	return u.String()
+}
+
+func now() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Waits for the task and returns the ID of the entity it operated on.
+func wait(t *testing.T, client *photon.Client, task *photon.Task, err error) string {
+	if err != nil {
+		t.Fatal("Not expecting error starting task: " + err.Error())
+	}
+	task, err = client.Tasks.Wait(task.ID)
+	if err != nil {
+		t.Fatal("Not expecting error waiting for task: " + err.Error())
+	}
+	return task.Entity.ID
+}
+
+// Creates a tenant with a project and returns the project ID.
+func createProject(t *testing.T, client *photon.Client) string {
+	task, err := client.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := wait(t, client, task, err)
+	task, err = client.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{
+		Name:                       "project1",
+		DefaultRouterPrivateIpCidr: "10.1.0.0/16",
+	})
+	return wait(t, client, task, err)
+}
+
+func TestVMLifecycle(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	projectID := createProject(t, client)
+	server.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	server.AddFlavor(photon.FlavorCreateSpec{Name: "boot", Kind: "ephemeral-disk"})
+	imageID := server.AddImage("ubuntu", 1024)
+
+	task, err := client.Projects.CreateVM(projectID, &photon.VmCreateSpec{
+		Name:          "vm1",
+		Flavor:        "small",
+		SourceImageID: imageID,
+		AttachedDisks: []photon.AttachedDisk{{Name: "boot", Kind: "ephemeral-disk", Flavor: "boot", BootDisk: true}},
+	})
+	if err != nil {
+		t.Fatal("Not expecting error creating VM: " + err.Error())
+	}
+	if task.State != "QUEUED" || len(task.Steps) != 2 {
+		t.Errorf("Expected a queued task with two steps, got %s with %d steps", task.State, len(task.Steps))
+	}
+	task, err = client.Tasks.Get(task.ID)
+	if err != nil || task.State != "STARTED" || task.Steps[0].State != "STARTED" {
+		t.Errorf("Expected the task to start once polled, got %+v (%v)", task, err)
+	}
+	vmID := wait(t, client, task, nil)
+
+	vms, err := client.Projects.GetVMs(projectID, nil)
+	if err != nil || len(vms.Items) != 1 || vms.Items[0].State != "STOPPED" {
+		t.Fatalf("Expected one stopped VM, got %+v (%v)", vms, err)
+	}
+
+	task, err = client.VMs.Start(vmID)
+	wait(t, client, task, err)
+	task, err = client.VMs.GetNetworks(vmID)
+	wait(t, client, task, err)
+	task, err = client.Tasks.Get(task.ID)
+	if err != nil {
+		t.Fatal("Not expecting error getting networks task: " + err.Error())
+	}
+	connections := task.ResourceProperties.(map[string]interface{})["networkConnections"].([]interface{})
+	connection := connections[0].(map[string]interface{})
+	if connection["ipAddress"] != "10.1.0.10" || connection["isConnected"] != "Connected" {
+		t.Errorf("Expected the VM to have an address in the default subnet, got %v", connection)
+	}
+
+	_, err = client.VMs.Delete(vmID)
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.Code != "InvalidVmState" {
+		t.Errorf("Expected deleting a running VM to fail, got %v", err)
+	}
+	task, err = client.VMs.Stop(vmID)
+	wait(t, client, task, err)
+	task, err = client.VMs.Delete(vmID)
+	wait(t, client, task, err)
+	_, err = client.VMs.Get(vmID)
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.HttpStatusCode != 404 {
+		t.Errorf("Expected the deleted VM to be gone, got %v", err)
+	}
+}
+
+func TestPersistentDisks(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	projectID := createProject(t, client)
+	server.AddFlavor(photon.FlavorCreateSpec{Name: "small", Kind: "vm"})
+	server.AddFlavor(photon.FlavorCreateSpec{Name: "disk", Kind: "persistent-disk"})
+	imageID := server.AddImage("ubuntu", 1024)
+
+	task, err := client.Projects.CreateVM(projectID, &photon.VmCreateSpec{Name: "vm1", Flavor: "small", SourceImageID: imageID})
+	vmID := wait(t, client, task, err)
+	task, err = client.Projects.CreateDisk(projectID, &photon.DiskCreateSpec{
+		Name: "data", Flavor: "disk", Kind: "persistent-disk", CapacityGB: 10,
+	})
+	diskID := wait(t, client, task, err)
+
+	task, err = client.VMs.AttachDisk(vmID, &photon.VmDiskOperation{DiskID: diskID})
+	wait(t, client, task, err)
+	disk, err := client.Disks.Get(diskID)
+	if err != nil || disk.State != "ATTACHED" || len(disk.VMs) != 1 || disk.VMs[0] != vmID {
+		t.Errorf("Expected the disk to be attached to the VM, got %+v (%v)", disk, err)
+	}
+	_, err = client.Disks.Delete(diskID)
+	if err == nil {
+		t.Error("Expected deleting an attached disk to fail")
+	}
+
+	task, err = client.VMs.DetachDisk(vmID, &photon.VmDiskOperation{DiskID: diskID})
+	wait(t, client, task, err)
+	task, err = client.Disks.Delete(diskID)
+	wait(t, client, task, err)
+}
+
+func TestImageUpload(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	task, err := client.Images.Create(bytes.NewReader(make([]byte, 2048)), "photon.ova",
+		&photon.ImageCreateOptions{ReplicationType: "ON_DEMAND"})
+	imageID := wait(t, client, task, err)
+
+	images, err := client.Images.GetAll(&photon.ImageGetOptions{Name: "photon.ova"})
+	if err != nil || len(images.Items) != 1 {
+		t.Fatalf("Expected to find the uploaded image, got %+v (%v)", images, err)
+	}
+	image := images.Items[0]
+	if image.ID != imageID || image.Size != 2048 || image.ReplicationType != "ON_DEMAND" || image.State != "READY" {
+		t.Errorf("Unexpected uploaded image: %+v", image)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	server.PageSize = 2
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		server.AddFlavor(photon.FlavorCreateSpec{Name: name, Kind: "vm"})
+	}
+	flavors, err := client.Flavors.GetAll(nil)
+	if err != nil {
+		t.Fatal("Not expecting error listing flavors: " + err.Error())
+	}
+	names := ""
+	for _, f := range flavors.Items {
+		names += f.Name
+	}
+	if names != "abcde" {
+		t.Errorf("Expected all pages in creation order, got %s", names)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	server.InjectFault(Fault{
+		Method:     "POST",
+		Path:       "/tenants",
+		StatusCode: 503,
+		Error:      photon.ApiError{Code: "ServiceUnavailable", Message: "try again"},
+		Times:      1,
+	})
+	_, err := client.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.HttpStatusCode != 503 || apiErr.Code != "ServiceUnavailable" {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	task, err := client.Tenants.Create(&photon.TenantCreateSpec{Name: "tenant1"})
+	tenantID := wait(t, client, task, err)
+
+	server.InjectFault(Fault{
+		Path:     "/tenants/*/projects",
+		Error:    photon.ApiError{Code: "QuotaError", Message: "not enough quota"},
+		FailTask: true,
+	})
+	task, err = client.Tenants.CreateProject(tenantID, &photon.ProjectCreateSpec{Name: "project1"})
+	if err != nil {
+		t.Fatal("Expected the request to be accepted: " + err.Error())
+	}
+	_, err = client.Tasks.Wait(task.ID)
+	taskErr, ok := err.(photon.TaskError)
+	if !ok {
+		t.Fatalf("Expected the task to fail, got %v", err)
+	}
+	task, _ = client.Tasks.Get(taskErr.ID)
+	if task.State != "ERROR" || task.Steps[0].Errors[0].Code != "QuotaError" {
+		t.Errorf("Expected the step to report the injected error, got %+v", task)
+	}
+	projects, err := client.Tenants.GetProjects(tenantID, nil)
+	if err != nil || len(projects.Items) != 0 {
+		t.Errorf("Expected the failed project to be rolled back, got %+v (%v)", projects, err)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	task, err := client.Zones.Create(&photon.ZoneCreateSpec{Name: "zone1"})
+	zoneID := wait(t, client, task, err)
+	task, err = client.InfraHosts.Create(&photon.HostCreateSpec{
+		Username: "root", Password: "secret", Address: "10.0.0.1", Zone: zoneID, Tags: []string{"CLOUD"},
+	})
+	hostID := wait(t, client, task, err)
+
+	_, err = client.InfraHosts.Create(&photon.HostCreateSpec{Username: "root", Password: "secret", Address: "10.0.0.1"})
+	if apiErr, ok := err.(photon.ApiError); !ok || apiErr.Code != "HostExists" {
+		t.Errorf("Expected adding the same host twice to fail, got %v", err)
+	}
+
+	task, err = client.InfraHosts.EnterMaintenanceMode(hostID)
+	wait(t, client, task, err)
+	host, err := client.InfraHosts.Get(hostID)
+	if err != nil || host.State != "MAINTENANCE" || host.Zone != zoneID || len(host.Password) != 0 {
+		t.Errorf("Unexpected host: %+v (%v)", host, err)
+	}
+	datastores, err := client.Datastores.GetAll()
+	if err != nil || len(datastores.Items) != 1 {
+		t.Errorf("Expected the host to add its datastore, got %+v (%v)", datastores, err)
+	}
+	usage, err := client.System.GetSystemSize()
+	if err != nil || usage.NumberHosts != 1 || usage.NumberDatastores != 1 {
+		t.Errorf("Unexpected system usage: %+v (%v)", usage, err)
+	}
+}
+
+func TestServiceUpgrade(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	projectID := createProject(t, client)
+	imageID := server.AddImage("kubernetes-1.6", 1024)
+	newImageID := server.AddImage("kubernetes-1.7", 1024)
+	task, err := client.System.EnableServiceType(&photon.ServiceConfigurationSpec{Type: "KUBERNETES", ImageID: imageID})
+	wait(t, client, task, err)
+
+	task, err = client.Projects.CreateService(projectID, &photon.ServiceCreateSpec{
+		Name:               "k8s",
+		Type:               "KUBERNETES",
+		WorkerCount:        2,
+		ExtendedProperties: map[string]string{photon.ExtendedPropertyNumberOfMasters: "1"},
+	})
+	serviceID := wait(t, client, task, err)
+	vms, err := client.Services.GetVMs(serviceID)
+	if err != nil || len(vms.Items) != 4 {
+		t.Fatalf("Expected etcd, master and two worker VMs, got %+v (%v)", vms, err)
+	}
+	if vms.Items[1].Tags[0] != "service:"+serviceID+":master" || vms.Items[1].State != "STARTED" {
+		t.Errorf("Unexpected master VM: %+v", vms.Items[1])
+	}
+
+	task, err = client.Services.ChangeVersion(serviceID, &photon.ServiceChangeVersionOperation{NewImageID: newImageID})
+	if err != nil {
+		t.Fatal("Not expecting error upgrading service: " + err.Error())
+	}
+	for i := 0; i < 2; i++ {
+		_, err = client.Tasks.Get(task.ID)
+		if err != nil {
+			t.Fatal("Not expecting error polling upgrade task: " + err.Error())
+		}
+	}
+	service, err := client.Services.Get(serviceID)
+	if err != nil || service.State != "UPGRADING" || service.UpgradeStatus.NumNodesUpgraded != 1 ||
+		service.UpgradeStatus.TotalNodes != 4 {
+		t.Errorf("Expected the upgrade to be in progress, got %+v (%v)", service, err)
+	}
+	wait(t, client, task, nil)
+	service, err = client.Services.Get(serviceID)
+	if err != nil || service.State != "READY" || service.ImageID != newImageID {
+		t.Errorf("Expected the upgrade to complete, got %+v (%v)", service, err)
+	}
+
+	task, err = client.Services.Resize(serviceID, &photon.ServiceResizeOperation{NewWorkerCount: 1})
+	wait(t, client, task, err)
+	vms, err = client.Services.GetVMs(serviceID)
+	if err != nil || len(vms.Items) != 3 {
+		t.Errorf("Expected one worker to be removed, got %+v (%v)", vms, err)
+	}
+}
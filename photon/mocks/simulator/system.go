@@ -0,0 +1,149 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// Sets the authentication information reported by the system.
+func (sim *Simulator) SetAuthInfo(auth photon.AuthInfo) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.system.Auth = &auth
+}
+
+// Serves /info.
+func (sim *Simulator) serveInfo(req *request) {
+	if !req.is("GET", 1) {
+		req.notFound()
+		return
+	}
+	req.ok(photon.Info{
+		BaseVersion:   sim.system.BaseVersion,
+		FullVersion:   sim.system.FullVersion,
+		GitCommitHash: sim.system.GitCommitHash,
+		NetworkType:   sim.system.NetworkType,
+	})
+}
+
+// Serves /system.
+func (sim *Simulator) serveSystem(req *request) {
+	entity := photon.Entity{Kind: "deployment"}
+	switch {
+	case req.match("GET", "system", "status"):
+		components := []photon.Component{}
+		for _, name := range []string{"PHOTON_CONTROLLER", "LIGHTWAVE"} {
+			components = append(components, photon.Component{Component: name, Status: "READY"})
+		}
+		req.ok(photon.Status{Status: sim.system.State, Components: components})
+	case req.match("GET", "system", "info"):
+		info := sim.system
+		info.Auth = sim.authInfo()
+		req.ok(info)
+	case req.match("GET", "system", "auth"):
+		req.ok(sim.authInfo())
+	case req.match("GET", "system", "usage"):
+		req.ok(photon.SystemUsage{
+			NumberHosts:      len(sim.hosts),
+			NumberVMs:        len(sim.vms),
+			NumberTenants:    len(sim.tenants),
+			NumberProjects:   len(sim.projects),
+			NumberDatastores: len(sim.datastores),
+			NumberServices:   len(sim.services),
+		})
+	case req.match("GET", "system", "vms"):
+		vms := sim.systemVMs
+		if vms == nil {
+			vms = []photon.VM{}
+		}
+		sim.writeList(req, vms)
+	case req.match("POST", "system", "pause"):
+		sim.setSystemState(req, "PAUSE_SYSTEM", "PAUSED")
+	case req.match("POST", "system", "pause-background-tasks"):
+		sim.setSystemState(req, "PAUSE_BACKGROUND_TASKS", "BACKGROUND_PAUSED")
+	case req.match("POST", "system", "resume"):
+		sim.setSystemState(req, "RESUME_SYSTEM", "READY")
+	case req.match("POST", "system", "set-security-groups"):
+		spec := &photon.SecurityGroupsSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		sim.system.Auth.SecurityGroups = spec.Items
+		sim.startTask(req, sim.newTask(req, "UPDATE_DEPLOYMENT_SECURITY_GROUPS", entity))
+	case req.match("POST", "system", "enable-service-type"):
+		spec := &photon.ServiceConfigurationSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		if _, ok := sim.images[spec.ImageID]; !ok {
+			req.entityNotFound("image", spec.ImageID)
+			return
+		}
+		sim.removeServiceConfiguration(spec.Type)
+		sim.system.ServiceConfigurations = append(sim.system.ServiceConfigurations,
+			photon.ServiceConfiguration{Kind: "serviceConfiguration", Type: spec.Type, ImageID: spec.ImageID})
+		sim.startTask(req, sim.newTask(req, "CONFIGURE_SERVICE", entity))
+	case req.match("POST", "system", "disable-service-type"):
+		spec := &photon.ServiceConfigurationSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		sim.removeServiceConfiguration(spec.Type)
+		sim.startTask(req, sim.newTask(req, "DELETE_SERVICE_CONFIGURATION", entity))
+	case req.match("POST", "system", "configure-nsx"):
+		spec := &photon.NsxConfigurationSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		if len(spec.NsxAddress) == 0 || len(spec.NsxUsername) == 0 || len(spec.NsxPassword) == 0 {
+			req.badRequest("InvalidEntity", "nsx address, username and password are required")
+			return
+		}
+		floatingRange := spec.FloatingIpRootRange
+		sim.system.NetworkConfiguration = &photon.NetworkConfiguration{
+			Enabled:         true,
+			Address:         spec.NsxAddress,
+			Username:        spec.NsxUsername,
+			NetworkZoneId:   spec.OverlayTransportZoneId,
+			TopRouterId:     spec.T0RouterId,
+			EdgeIpPoolId:    spec.TunnelIpPoolId,
+			HostUplinkPnic:  spec.HostUplinkPnic,
+			FloatingIpRange: &floatingRange,
+		}
+		sim.system.NetworkType = "SOFTWARE_DEFINED"
+		sim.startTask(req, sim.newTask(req, "CONFIGURE_NSX", entity))
+	default:
+		req.notFound()
+	}
+}
+
+// Returns the authentication information without the password.
+func (sim *Simulator) authInfo() *photon.AuthInfo {
+	auth := *sim.system.Auth
+	auth.Password = ""
+	return &auth
+}
+
+func (sim *Simulator) setSystemState(req *request, operation, state string) {
+	task := sim.newTask(req, operation, photon.Entity{Kind: "deployment"})
+	task.onComplete = func() { sim.system.State = state }
+	sim.startTask(req, task)
+}
+
+func (sim *Simulator) removeServiceConfiguration(serviceType string) {
+	configurations := []photon.ServiceConfiguration{}
+	for _, config := range sim.system.ServiceConfigurations {
+		if config.Type != serviceType {
+			configurations = append(configurations, config)
+		}
+	}
+	sim.system.ServiceConfigurations = configurations
+}
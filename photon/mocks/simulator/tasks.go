@@ -0,0 +1,151 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+// A task and the changes it applies to the simulator as it progresses.
+type task struct {
+	photon.Task
+
+	// Called with the index of each step once it completes.
+	onStep func(step int)
+	// Called once all the steps completed.
+	onComplete func()
+	// Called if the task fails, to roll back changes made when it was created.
+	onError func()
+	// Error reported by the last step, set when a fault was injected.
+	fault *photon.ApiError
+}
+
+// Creates a queued task on the given entity with one step per operation
+// listed, or a single step named after the task when there are none.
+func (sim *Simulator) newTask(req *request, operation string, entity photon.Entity, steps ...string) *task {
+	if len(steps) == 0 {
+		steps = []string{operation}
+	}
+	id := sim.newID("task")
+	queued := now()
+	t := &task{
+		Task: photon.Task{
+			ID:         id,
+			Operation:  operation,
+			State:      "QUEUED",
+			QueuedTime: queued,
+			Entity:     entity,
+			SelfLink:   selfLink(req, "tasks/"+id),
+		},
+		fault: sim.taskFault,
+	}
+	sim.taskFault = nil
+	for i, step := range steps {
+		t.Steps = append(t.Steps, photon.Step{
+			ID:         id + "-" + step,
+			Operation:  step,
+			State:      "QUEUED",
+			QueuedTime: queued,
+			Sequence:   i,
+		})
+	}
+	sim.tasks[id] = t
+	return t
+}
+
+// Writes the task as the response to the request that started it.
+func (sim *Simulator) startTask(req *request, t *task) {
+	req.ok(t.Task)
+}
+
+// Moves the task one step forward: a queued task starts, a started task
+// completes its current step, and finishes when that was the last one.
+func (sim *Simulator) advance(t *task) {
+	switch t.State {
+	case "QUEUED":
+		t.State = "STARTED"
+		t.StartedTime = now()
+		t.Steps[0].State = "STARTED"
+		t.Steps[0].StartedTime = t.StartedTime
+	case "STARTED":
+		current := 0
+		for current < len(t.Steps) && t.Steps[current].State == "COMPLETED" {
+			current++
+		}
+		step := &t.Steps[current]
+		step.EndTime = now()
+		if t.fault != nil && current == len(t.Steps)-1 {
+			step.State = "ERROR"
+			step.Errors = []photon.ApiError{*t.fault}
+			t.State = "ERROR"
+			t.EndTime = step.EndTime
+			if t.onError != nil {
+				t.onError()
+			}
+			return
+		}
+		step.State = "COMPLETED"
+		if t.onStep != nil {
+			t.onStep(current)
+		}
+		if current+1 < len(t.Steps) {
+			t.Steps[current+1].State = "STARTED"
+			t.Steps[current+1].StartedTime = step.EndTime
+			return
+		}
+		t.State = "COMPLETED"
+		t.EndTime = step.EndTime
+		if t.onComplete != nil {
+			t.onComplete()
+		}
+	}
+}
+
+// Returns the tasks matching the filters of a task list request, optionally
+// restricted to a single entity.
+func (sim *Simulator) filterTasks(req *request, entityID string) []photon.Task {
+	if len(entityID) == 0 {
+		entityID = req.query("entityId")
+	}
+	entityKind := req.query("entityKind")
+	state := req.query("state")
+	kind := req.query("kind")
+
+	tasks := []photon.Task{}
+	for _, id := range sim.sortedIDs(sim.tasks) {
+		t := sim.tasks[id]
+		if (len(entityID) != 0 && t.Entity.ID != entityID) ||
+			(len(entityKind) != 0 && t.Entity.Kind != entityKind) ||
+			(len(state) != 0 && t.State != state) ||
+			(len(kind) != 0 && t.Operation != kind) {
+			continue
+		}
+		tasks = append(tasks, t.Task)
+	}
+	return tasks
+}
+
+// Serves /tasks; getting a task advances it.
+func (sim *Simulator) serveTasks(req *request) {
+	switch {
+	case req.is("GET", 1):
+		sim.writeList(req, sim.filterTasks(req, ""))
+	case req.is("GET", 2):
+		t, ok := sim.tasks[req.segs[1]]
+		if !ok {
+			req.entityNotFound("task", req.segs[1])
+			return
+		}
+		sim.advance(t)
+		req.ok(t.Task)
+	default:
+		req.notFound()
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+const defaultRouterCidr = "192.168.0.0/16"
+
+type tenant struct {
+	photon.Tenant
+	iam []photon.PolicyEntry
+}
+
+type project struct {
+	photon.ProjectCompact
+	tenantID string
+	iam      []photon.PolicyEntry
+}
+
+// Serves /tenants.
+func (sim *Simulator) serveTenants(req *request) {
+	if req.is("GET", 1) {
+		tenants := []photon.Tenant{}
+		for _, id := range sim.sortedIDs(sim.tenants) {
+			t := sim.tenantView(id)
+			if name := req.query("name"); len(name) == 0 || t.Name == name {
+				tenants = append(tenants, t)
+			}
+		}
+		sim.writeList(req, tenants)
+		return
+	}
+	if req.is("POST", 1) {
+		sim.createTenant(req)
+		return
+	}
+
+	t, ok := sim.tenants[req.segs[1]]
+	if !ok {
+		req.entityNotFound("tenant", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: t.ID, Kind: "tenant"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(sim.tenantView(t.ID))
+	case req.is("DELETE", 2):
+		for _, p := range sim.projects {
+			if p.tenantID == t.ID {
+				req.badRequest("ContainerNotEmpty", "tenant %s has projects", t.ID)
+				return
+			}
+		}
+		task := sim.newTask(req, "DELETE_TENANT", entity)
+		task.onComplete = func() { delete(sim.tenants, t.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "tenants", "*", "projects"):
+		projects := []photon.ProjectCompact{}
+		for _, id := range sim.sortedIDs(sim.projects) {
+			p := sim.projects[id]
+			if p.tenantID != t.ID {
+				continue
+			}
+			if name := req.query("name"); len(name) == 0 || p.Name == name {
+				projects = append(projects, sim.projectView(p))
+			}
+		}
+		sim.writeList(req, projects)
+	case req.match("POST", "tenants", "*", "projects"):
+		sim.createProject(req, t)
+	case req.match("GET", "tenants", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, t.ID))
+	case req.match("POST", "tenants", "*", "set_security_groups"):
+		spec := &photon.SecurityGroupsSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		t.SecurityGroups = securityGroups(spec.Items, false)
+		sim.startTask(req, sim.newTask(req, "SET_TENANT_SECURITY_GROUPS", entity))
+	case len(req.segs) < 3:
+		req.notFound()
+	case req.segs[2] == "quota":
+		sim.serveQuota(req, &t.ResourceQuota, entity)
+	case req.segs[2] == "iam":
+		sim.serveIam(req, &t.iam, entity)
+	default:
+		req.notFound()
+	}
+}
+
+func (sim *Simulator) createTenant(req *request) {
+	spec := &photon.TenantCreateSpec{}
+	if !req.decode(spec) {
+		return
+	}
+	for _, t := range sim.tenants {
+		if t.Name == spec.Name {
+			req.badRequest("NameTaken", "tenant name '%s' is already taken", spec.Name)
+			return
+		}
+	}
+	id := sim.newID("tenant")
+	t := &tenant{
+		Tenant: photon.Tenant{
+			Kind:           "tenant",
+			Name:           spec.Name,
+			ID:             id,
+			SelfLink:       selfLink(req, "tenants/"+id),
+			Tags:           []string{},
+			SecurityGroups: securityGroups(spec.SecurityGroups, false),
+			ResourceQuota:  spec.ResourceQuota,
+		},
+		iam: []photon.PolicyEntry{},
+	}
+	sim.tenants[id] = t
+	task := sim.newTask(req, "CREATE_TENANT", photon.Entity{ID: id, Kind: "tenant"})
+	task.onError = func() { delete(sim.tenants, id) }
+	sim.startTask(req, task)
+}
+
+func (sim *Simulator) tenantView(id string) photon.Tenant {
+	t := sim.tenants[id].Tenant
+	t.Projects = []photon.BaseCompact{}
+	for _, projectID := range sim.sortedIDs(sim.projects) {
+		p := sim.projects[projectID]
+		if p.tenantID == id {
+			t.Projects = append(t.Projects, photon.BaseCompact{Name: p.Name, ID: p.ID})
+		}
+	}
+	return t
+}
+
+// Returns the project with the security groups it inherits from its tenant.
+func (sim *Simulator) projectView(p *project) photon.ProjectCompact {
+	view := p.ProjectCompact
+	view.SecurityGroups = []photon.SecurityGroup{}
+	for _, group := range sim.tenants[p.tenantID].SecurityGroups {
+		view.SecurityGroups = append(view.SecurityGroups, photon.SecurityGroup{Name: group.Name, Inherited: true})
+	}
+	view.SecurityGroups = append(view.SecurityGroups, p.SecurityGroups...)
+	return view
+}
+
+func (sim *Simulator) createProject(req *request, t *tenant) {
+	spec := &photon.ProjectCreateSpec{}
+	if !req.decode(spec) {
+		return
+	}
+	for _, p := range sim.projects {
+		if p.tenantID == t.ID && p.Name == spec.Name {
+			req.badRequest("NameTaken", "project name '%s' is already taken", spec.Name)
+			return
+		}
+	}
+	cidr := spec.DefaultRouterPrivateIpCidr
+	if len(cidr) == 0 {
+		cidr = defaultRouterCidr
+	}
+	_, routerNet, err := parseCidr(cidr)
+	if err != nil {
+		req.badRequest("InvalidEntity", "invalid default router cidr '%s'", cidr)
+		return
+	}
+
+	id := sim.newID("project")
+	p := &project{
+		ProjectCompact: photon.ProjectCompact{
+			Kind:           "project",
+			Name:           spec.Name,
+			ID:             id,
+			Tags:           []string{},
+			SelfLink:       selfLink(req, "projects/"+id),
+			SecurityGroups: securityGroups(spec.SecurityGroups, false),
+			ResourceQuota:  spec.ResourceQuota,
+		},
+		tenantID: t.ID,
+		iam:      []photon.PolicyEntry{},
+	}
+	sim.projects[id] = p
+
+	// Every project gets a default router with a default subnet covering
+	// the first /24 of its range.
+	r := sim.addRouter(req, id, "default", routerNet.String(), true)
+	sim.addSubnet(req, r.ID, "default", firstSubnet(routerNet, 24), true)
+
+	task := sim.newTask(req, "CREATE_PROJECT", photon.Entity{ID: id, Kind: "project"})
+	task.onError = func() { sim.deleteProject(id) }
+	sim.startTask(req, task)
+}
+
+func (sim *Simulator) deleteProject(id string) {
+	for routerID, r := range sim.routers {
+		if r.projectID == id {
+			sim.deleteRouter(routerID)
+		}
+	}
+	delete(sim.projects, id)
+}
+
+// Serves /projects.
+func (sim *Simulator) serveProjects(req *request) {
+	if len(req.segs) < 2 {
+		req.notFound()
+		return
+	}
+	p, ok := sim.projects[req.segs[1]]
+	if !ok {
+		req.entityNotFound("project", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: p.ID, Kind: "project"}
+	switch {
+	case req.is("GET", 2):
+		req.ok(sim.projectView(p))
+	case req.is("DELETE", 2):
+		if sim.projectInUse(p.ID) {
+			req.badRequest("ContainerNotEmpty", "project %s is not empty", p.ID)
+			return
+		}
+		task := sim.newTask(req, "DELETE_PROJECT", entity)
+		task.onComplete = func() { sim.deleteProject(p.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "projects", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, p.ID))
+	case req.match("POST", "projects", "*", "set_security_groups"):
+		spec := &photon.SecurityGroupsSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		p.SecurityGroups = securityGroups(spec.Items, false)
+		sim.startTask(req, sim.newTask(req, "SET_PROJECT_SECURITY_GROUPS", entity))
+	case len(req.segs) < 3:
+		req.notFound()
+	case req.segs[2] == "quota":
+		sim.serveQuota(req, &p.ResourceQuota, entity)
+	case req.segs[2] == "iam":
+		sim.serveIam(req, &p.iam, entity)
+	case req.segs[2] == "vms":
+		sim.serveProjectVMs(req, p)
+	case req.segs[2] == "disks":
+		sim.serveProjectDisks(req, p)
+	case req.segs[2] == "images":
+		sim.serveProjectImages(req, p)
+	case req.segs[2] == "routers":
+		sim.serveProjectRouters(req, p)
+	case req.segs[2] == "services":
+		sim.serveProjectServices(req, p)
+	default:
+		req.notFound()
+	}
+}
+
+func (sim *Simulator) projectInUse(id string) bool {
+	for _, v := range sim.vms {
+		if v.projectID == id {
+			return true
+		}
+	}
+	for _, d := range sim.disks {
+		if d.projectID == id {
+			return true
+		}
+	}
+	for _, s := range sim.services {
+		if s.ProjectID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Serves the quota of a tenant or project: GET returns it, PUT replaces its
+// line items, PATCH updates the given ones and DELETE removes them.
+func (sim *Simulator) serveQuota(req *request, quota *photon.Quota, entity photon.Entity) {
+	if len(req.segs) != 3 {
+		req.notFound()
+		return
+	}
+	if req.r.Method == "GET" {
+		if quota.QuotaLineItems == nil {
+			quota.QuotaLineItems = map[string]photon.QuotaStatusLineItem{}
+		}
+		req.ok(quota)
+		return
+	}
+
+	spec := photon.QuotaSpec{}
+	if !req.decode(&spec) {
+		return
+	}
+	items := map[string]photon.QuotaStatusLineItem{}
+	for key, item := range quota.QuotaLineItems {
+		items[key] = item
+	}
+	switch req.r.Method {
+	case "PUT":
+		items = spec
+	case "PATCH":
+		for key, item := range spec {
+			item.Usage = items[key].Usage
+			items[key] = item
+		}
+	case "DELETE":
+		for key := range spec {
+			delete(items, key)
+		}
+	default:
+		req.notFound()
+		return
+	}
+	quota.QuotaLineItems = items
+	sim.startTask(req, sim.newTask(req, "SET_QUOTA", entity))
+}
+
+// Serves the IAM policy of an entity: GET returns it, POST replaces it and
+// PATCH adds or removes a role for a principal.
+func (sim *Simulator) serveIam(req *request, policy *[]photon.PolicyEntry, entity photon.Entity) {
+	if len(req.segs) != 3 {
+		req.notFound()
+		return
+	}
+	switch req.r.Method {
+	case "GET":
+		req.ok(policy)
+	case "POST":
+		entries := []photon.PolicyEntry{}
+		if !req.decode(&entries) {
+			return
+		}
+		*policy = entries
+		sim.startTask(req, sim.newTask(req, "SET_IAM_POLICY", entity))
+	case "PATCH":
+		delta := &photon.PolicyDelta{}
+		if !req.decode(delta) {
+			return
+		}
+		if delta.Action != "ADD" && delta.Action != "REMOVE" {
+			req.badRequest("InvalidEntity", "unknown action '%s'", delta.Action)
+			return
+		}
+		*policy = applyPolicyDelta(*policy, delta)
+		sim.startTask(req, sim.newTask(req, "MODIFY_IAM_POLICY", entity))
+	default:
+		req.notFound()
+	}
+}
+
+func applyPolicyDelta(policy []photon.PolicyEntry, delta *photon.PolicyDelta) []photon.PolicyEntry {
+	result := []photon.PolicyEntry{}
+	found := false
+	for _, entry := range policy {
+		if entry.Principal != delta.Principal {
+			result = append(result, entry)
+			continue
+		}
+		found = true
+		roles := []string{}
+		for _, role := range entry.Roles {
+			if role != delta.Role {
+				roles = append(roles, role)
+			}
+		}
+		if delta.Action == "ADD" {
+			roles = append(roles, delta.Role)
+		}
+		if len(roles) != 0 {
+			result = append(result, photon.PolicyEntry{Principal: entry.Principal, Roles: roles})
+		}
+	}
+	if !found && delta.Action == "ADD" {
+		result = append(result, photon.PolicyEntry{Principal: delta.Principal, Roles: []string{delta.Role}})
+	}
+	return result
+}
+
+func securityGroups(names []string, inherited bool) []photon.SecurityGroup {
+	groups := []photon.SecurityGroup{}
+	for _, name := range names {
+		groups = append(groups, photon.SecurityGroup{Name: name, Inherited: inherited})
+	}
+	return groups
+}
@@ -0,0 +1,509 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/vmware/photon-controller-go-sdk/photon"
+)
+
+type vm struct {
+	photon.VM
+	projectID string
+	networks  []nic
+}
+
+// A network connection of a VM.
+type nic struct {
+	subnetID   string
+	macAddress string
+	ipAddress  string
+	netmask    string
+}
+
+type disk struct {
+	photon.PersistentDisk
+	projectID string
+}
+
+// State transitions of the VM power operations: the states an operation is
+// allowed from, and the state it leaves the VM in.
+var vmOperations = map[string]struct {
+	operation string
+	from      []string
+	to        string
+}{
+	"start":   {"START_VM", []string{"STOPPED"}, "STARTED"},
+	"stop":    {"STOP_VM", []string{"STARTED", "SUSPENDED"}, "STOPPED"},
+	"restart": {"RESTART_VM", []string{"STARTED"}, "STARTED"},
+	"suspend": {"SUSPEND_VM", []string{"STARTED"}, "SUSPENDED"},
+	"resume":  {"RESUME_VM", []string{"SUSPENDED"}, "STARTED"},
+}
+
+// Serves /projects/{id}/vms.
+func (sim *Simulator) serveProjectVMs(req *request, p *project) {
+	switch {
+	case req.is("GET", 3):
+		vms := []photon.VM{}
+		for _, id := range sim.sortedIDs(sim.vms) {
+			v := sim.vms[id]
+			if v.projectID != p.ID {
+				continue
+			}
+			if name := req.query("name"); len(name) == 0 || v.Name == name {
+				vms = append(vms, v.VM)
+			}
+		}
+		sim.writeList(req, vms)
+	case req.is("POST", 3):
+		spec := &photon.VmCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		if _, ok := sim.findFlavor(spec.Flavor, "vm"); !ok {
+			req.badRequest("InvalidFlavorSpecification", "flavor '%s' of kind 'vm' does not exist", spec.Flavor)
+			return
+		}
+		if i, ok := sim.images[spec.SourceImageID]; !ok || i.State != "READY" {
+			req.badRequest("InvalidImageState", "image %s is not ready", spec.SourceImageID)
+			return
+		}
+		for _, d := range spec.AttachedDisks {
+			if _, ok := sim.findFlavor(d.Flavor, d.Kind); !ok {
+				req.badRequest("InvalidFlavorSpecification", "flavor '%s' of kind '%s' does not exist", d.Flavor, d.Kind)
+				return
+			}
+		}
+		subnets := spec.Subnets
+		if len(subnets) == 0 {
+			if s := sim.defaultSubnet(p.ID); s != nil {
+				subnets = []string{s.ID}
+			}
+		}
+		for _, id := range subnets {
+			if _, ok := sim.subnets[id]; !ok {
+				req.badRequest("InvalidEntity", "subnet %s does not exist", id)
+				return
+			}
+		}
+
+		v := sim.addVM(req, p.ID, spec, subnets)
+		task := sim.newTask(req, "CREATE_VM", photon.Entity{ID: v.ID, Kind: "vm"},
+			"RESERVE_RESOURCE", "CREATE_VM")
+		task.onComplete = func() { v.State = "STOPPED" }
+		task.onError = func() { delete(sim.vms, v.ID) }
+		sim.startTask(req, task)
+	default:
+		req.notFound()
+	}
+}
+
+func (sim *Simulator) addVM(req *request, projectID string, spec *photon.VmCreateSpec, subnets []string) *vm {
+	id := sim.newID("vm")
+	v := &vm{
+		VM: photon.VM{
+			SourceImageID: spec.SourceImageID,
+			Cost:          []photon.QuotaLineItem{},
+			Kind:          "vm",
+			AttachedDisks: []photon.AttachedDisk{},
+			Tags:          spec.Tags,
+			Metadata:      map[string]string{},
+			SelfLink:      selfLink(req, "vms/"+id),
+			Flavor:        spec.Flavor,
+			Name:          spec.Name,
+			State:         "CREATING",
+			ID:            id,
+		},
+		projectID: projectID,
+	}
+	if f, ok := sim.findFlavor(spec.Flavor, "vm"); ok {
+		v.Cost = f.Cost
+	}
+	if h := sim.placeVM(); h != nil {
+		v.Host = h.Address
+		v.Datastore = "datastore-" + h.ID
+	}
+	for _, d := range spec.AttachedDisks {
+		d.ID = sim.newID("disk")
+		d.State = "ATTACHED"
+		v.AttachedDisks = append(v.AttachedDisks, d)
+	}
+	for i, subnetID := range subnets {
+		ip, netmask := sim.subnets[subnetID].allocateIP()
+		v.networks = append(v.networks, nic{
+			subnetID:   subnetID,
+			macAddress: fmt.Sprintf("00:50:56:%02x:%02x:%02x", sim.seq[id]>>8&0xff, sim.seq[id]&0xff, i),
+			ipAddress:  ip,
+			netmask:    netmask,
+		})
+	}
+	sim.vms[id] = v
+	return v
+}
+
+// Returns the ready host running the fewest VMs, or nil if there is none.
+func (sim *Simulator) placeVM() *photon.Host {
+	var best *photon.Host
+	bestCount := 0
+	for _, id := range sim.sortedIDs(sim.hosts) {
+		h := sim.hosts[id]
+		if h.State != "READY" {
+			continue
+		}
+		count := 0
+		for _, v := range sim.vms {
+			if v.Host == h.Address {
+				count++
+			}
+		}
+		if best == nil || count < bestCount {
+			best, bestCount = h, count
+		}
+	}
+	return best
+}
+
+// Serves /vms.
+func (sim *Simulator) serveVMs(req *request) {
+	if len(req.segs) < 2 {
+		req.notFound()
+		return
+	}
+	v, ok := sim.vms[req.segs[1]]
+	if !ok {
+		req.entityNotFound("vm", req.segs[1])
+		return
+	}
+	entity := photon.Entity{ID: v.ID, Kind: "vm"}
+	if len(req.segs) == 2 {
+		switch req.r.Method {
+		case "GET":
+			req.ok(v.VM)
+		case "DELETE":
+			if v.State != "STOPPED" && v.State != "ERROR" {
+				req.badRequest("InvalidVmState", "VM %s must be stopped before it is deleted, it is %s", v.ID, v.State)
+				return
+			}
+			for _, d := range v.AttachedDisks {
+				if d.Kind == "persistent-disk" {
+					req.badRequest("PersistentDiskAttached", "disk %s is attached to VM %s", d.ID, v.ID)
+					return
+				}
+			}
+			task := sim.newTask(req, "DELETE_VM", entity)
+			task.onComplete = func() { delete(sim.vms, v.ID) }
+			sim.startTask(req, task)
+		default:
+			req.notFound()
+		}
+		return
+	}
+
+	action := req.segs[2]
+	if op, ok := vmOperations[action]; ok && req.is("POST", 3) {
+		allowed := false
+		for _, state := range op.from {
+			allowed = allowed || v.State == state
+		}
+		if !allowed {
+			req.badRequest("InvalidVmState", "cannot %s VM %s in state %s", action, v.ID, v.State)
+			return
+		}
+		task := sim.newTask(req, op.operation, entity)
+		task.onComplete = func() { v.State = op.to }
+		sim.startTask(req, task)
+		return
+	}
+
+	switch {
+	case req.match("GET", "vms", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, v.ID))
+	case req.match("POST", "vms", "*", "attach_disk"), req.match("POST", "vms", "*", "detach_disk"):
+		sim.vmDiskOperation(req, v, action == "attach_disk")
+	case req.match("POST", "vms", "*", "attach_iso"):
+		name, size, _, err := readUpload(req.r)
+		if err != nil {
+			req.badRequest("InvalidEntity", "reading ISO: %s", err)
+			return
+		}
+		if len(v.AttachedISOs) != 0 {
+			req.badRequest("IsoAlreadyAttached", "VM %s already has an ISO attached", v.ID)
+			return
+		}
+		v.AttachedISOs = []photon.ISO{{Name: name, Size: size, Kind: "iso", ID: sim.newID("iso")}}
+		sim.startTask(req, sim.newTask(req, "ATTACH_ISO", entity))
+	case req.match("POST", "vms", "*", "detach_iso"):
+		v.AttachedISOs = nil
+		sim.startTask(req, sim.newTask(req, "DETACH_ISO", entity))
+	case req.match("POST", "vms", "*", "set_metadata"):
+		spec := &photon.VmMetadata{}
+		if !req.decode(spec) {
+			return
+		}
+		v.Metadata = spec.Metadata
+		sim.startTask(req, sim.newTask(req, "SET_METADATA", entity))
+	case req.match("POST", "vms", "*", "tags"):
+		spec := &photon.VmTag{}
+		if !req.decode(spec) {
+			return
+		}
+		v.Tags = append(v.Tags, spec.Tag)
+		sim.startTask(req, sim.newTask(req, "ADD_TAG", entity))
+	case req.match("POST", "vms", "*", "create_image"):
+		spec := &photon.ImageCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		var size int64
+		if source, ok := sim.images[v.SourceImageID]; ok {
+			size = source.Size
+		}
+		i := sim.addImage(req, spec.Name, size, spec.ReplicationType, photon.ImageScope{Kind: "infrastructure"})
+		task := sim.newTask(req, "CREATE_VM_IMAGE", photon.Entity{ID: i.ID, Kind: "image"})
+		task.onComplete = func() { sim.imageReady(i) }
+		task.onError = func() { delete(sim.images, i.ID) }
+		sim.startTask(req, task)
+	case req.match("POST", "vms", "*", "acquire_floating_ip"):
+		if len(v.FloatingIp) == 0 {
+			v.FloatingIp = sim.allocateFloatingIP()
+		}
+		sim.startTask(req, sim.newTask(req, "ACQUIRE_FLOATING_IP", entity))
+	case req.match("DELETE", "vms", "*", "release_floating_ip"):
+		v.FloatingIp = ""
+		sim.startTask(req, sim.newTask(req, "RELEASE_FLOATING_IP", entity))
+	case req.match("GET", "vms", "*", "subnets"):
+		task := sim.newTask(req, "GET_NETWORKS", entity)
+		task.ResourceProperties = map[string]interface{}{"networkConnections": sim.networkConnections(v)}
+		sim.startTask(req, task)
+	case req.match("GET", "vms", "*", "mks_ticket"):
+		if v.State != "STARTED" {
+			req.badRequest("InvalidVmState", "VM %s is not started", v.ID)
+			return
+		}
+		task := sim.newTask(req, "GET_MKS_TICKET", entity)
+		task.ResourceProperties = map[string]interface{}{
+			"ticket":  "ticket-" + v.ID,
+			"host":    v.Host,
+			"port":    902,
+			"cfgFile": fmt.Sprintf("[%s] %s/%s.vmx", v.Datastore, v.ID, v.Name),
+		}
+		sim.startTask(req, task)
+	default:
+		req.notFound()
+	}
+}
+
+// Returns the network connections reported by the guest, which has an
+// address only while it runs.
+func (sim *Simulator) networkConnections(v *vm) []map[string]string {
+	connections := []map[string]string{}
+	for _, n := range v.networks {
+		connection := map[string]string{
+			"network":     n.subnetID,
+			"macAddress":  n.macAddress,
+			"netmask":     n.netmask,
+			"isConnected": "Disconnected",
+		}
+		if v.State == "STARTED" {
+			connection["ipAddress"] = n.ipAddress
+			connection["isConnected"] = "Connected"
+		}
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+func (sim *Simulator) allocateFloatingIP() string {
+	start := "10.250.0.1"
+	if config := sim.system.NetworkConfiguration; config != nil && config.FloatingIpRange != nil {
+		start = config.FloatingIpRange.Start
+	}
+	_, n, err := parseCidr(start + "/32")
+	if err != nil {
+		return ""
+	}
+	used := map[string]bool{}
+	for _, v := range sim.vms {
+		used[v.FloatingIp] = true
+	}
+	for i := 0; ; i++ {
+		ip := hostIP(n, i)
+		if !used[ip] {
+			return ip
+		}
+	}
+}
+
+func (sim *Simulator) vmDiskOperation(req *request, v *vm, attach bool) {
+	spec := &photon.VmDiskOperation{}
+	if !req.decode(spec) {
+		return
+	}
+	d, ok := sim.disks[spec.DiskID]
+	if !ok {
+		req.entityNotFound("disk", spec.DiskID)
+		return
+	}
+	entity := photon.Entity{ID: v.ID, Kind: "vm"}
+	if attach {
+		if d.State != "DETACHED" || d.projectID != v.projectID {
+			req.badRequest("InvalidEntity", "disk %s cannot be attached to VM %s", d.ID, v.ID)
+			return
+		}
+		d.State = "ATTACHED"
+		d.VMs = []string{v.ID}
+		v.AttachedDisks = append(v.AttachedDisks, photon.AttachedDisk{
+			Flavor:     d.Flavor,
+			Kind:       d.Kind,
+			CapacityGB: d.CapacityGB,
+			Name:       d.Name,
+			State:      "ATTACHED",
+			ID:         d.ID,
+		})
+		sim.startTask(req, sim.newTask(req, "ATTACH_DISK", entity))
+		return
+	}
+
+	disks := []photon.AttachedDisk{}
+	for _, attached := range v.AttachedDisks {
+		if attached.ID != d.ID {
+			disks = append(disks, attached)
+		}
+	}
+	if len(disks) == len(v.AttachedDisks) {
+		req.badRequest("DiskNotAttached", "disk %s is not attached to VM %s", d.ID, v.ID)
+		return
+	}
+	v.AttachedDisks = disks
+	d.State = "DETACHED"
+	d.VMs = []string{}
+	sim.startTask(req, sim.newTask(req, "DETACH_DISK", entity))
+}
+
+// Serves /projects/{id}/disks.
+func (sim *Simulator) serveProjectDisks(req *request, p *project) {
+	switch {
+	case req.is("GET", 3):
+		disks := []photon.PersistentDisk{}
+		for _, id := range sim.sortedIDs(sim.disks) {
+			d := sim.disks[id]
+			if d.projectID != p.ID {
+				continue
+			}
+			if name := req.query("name"); len(name) == 0 || d.Name == name {
+				disks = append(disks, d.PersistentDisk)
+			}
+		}
+		sim.writeList(req, disks)
+	case req.is("POST", 3):
+		spec := &photon.DiskCreateSpec{}
+		if !req.decode(spec) {
+			return
+		}
+		f, ok := sim.findFlavor(spec.Flavor, spec.Kind)
+		if !ok {
+			req.badRequest("InvalidFlavorSpecification", "flavor '%s' of kind '%s' does not exist", spec.Flavor, spec.Kind)
+			return
+		}
+		id := sim.newID("disk")
+		d := &disk{
+			PersistentDisk: photon.PersistentDisk{
+				Flavor:     spec.Flavor,
+				Cost:       f.Cost,
+				Kind:       spec.Kind,
+				CapacityGB: spec.CapacityGB,
+				Name:       spec.Name,
+				State:      "CREATING",
+				ID:         id,
+				VMs:        []string{},
+				Tags:       spec.Tags,
+				SelfLink:   selfLink(req, "disks/"+id),
+			},
+			projectID: p.ID,
+		}
+		if h := sim.placeVM(); h != nil {
+			d.Datastore = "datastore-" + h.ID
+		}
+		sim.disks[id] = d
+		task := sim.newTask(req, "CREATE_DISK", photon.Entity{ID: id, Kind: spec.Kind})
+		task.onComplete = func() { d.State = "DETACHED" }
+		task.onError = func() { delete(sim.disks, id) }
+		sim.startTask(req, task)
+	default:
+		req.notFound()
+	}
+}
+
+// Serves /disks.
+func (sim *Simulator) serveDisks(req *request) {
+	if len(req.segs) < 2 {
+		req.notFound()
+		return
+	}
+	d, ok := sim.disks[req.segs[1]]
+	if !ok {
+		req.entityNotFound("disk", req.segs[1])
+		return
+	}
+	switch {
+	case req.is("GET", 2):
+		req.ok(d.PersistentDisk)
+	case req.is("DELETE", 2):
+		if d.State == "ATTACHED" {
+			req.badRequest("InvalidEntity", "disk %s is attached to VM %s", d.ID, d.VMs[0])
+			return
+		}
+		task := sim.newTask(req, "DELETE_DISK", photon.Entity{ID: d.ID, Kind: d.Kind})
+		task.onComplete = func() { delete(sim.disks, d.ID) }
+		sim.startTask(req, task)
+	case req.match("GET", "disks", "*", "tasks"):
+		sim.writeList(req, sim.filterTasks(req, d.ID))
+	default:
+		req.notFound()
+	}
+}
+
+// Reads a multipart upload as sent by the SDK, and returns the name and
+// size of the file and the other form fields.
+func readUpload(r *http.Request) (name string, size int64, fields map[string]string, err error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return
+	}
+	fields = map[string]string{}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if part.FormName() == "file" {
+			name = part.FileName()
+			size, err = io.Copy(ioutil.Discard, part)
+		} else {
+			var value []byte
+			value, err = ioutil.ReadAll(part)
+			fields[part.FormName()] = string(value)
+		}
+		if err != nil {
+			return "", 0, nil, err
+		}
+	}
+	return name, size, fields, nil
+}
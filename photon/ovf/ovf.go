@@ -0,0 +1,173 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package ovf
+
+/**
+ * These utilities read the OVF descriptor of an appliance, either on its own or at the
+ * start of an OVA archive, to find the disk to create an image from.
+ *
+ * Images only keep the disk of an appliance: the hardware a VM gets comes from its flavor
+ * and the subnets it is created on, so the rest of the virtual hardware is reported as ignored.
+ */
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// CIM resource types of the virtual hardware items of a descriptor
+const (
+	resourceProcessor       = 3
+	resourceMemory          = 4
+	resourceIDEController   = 5
+	resourceSCSIController  = 6
+	resourceEthernetAdapter = 10
+	resourceDiskDrive       = 17
+	resourceSATAController  = 20
+)
+
+// OVF descriptor, with only the parts needed to import its disk.
+type Envelope struct {
+	Files    []File          `xml:"References>File"`
+	Disks    []Disk          `xml:"DiskSection>Disk"`
+	Systems  []VirtualSystem `xml:"VirtualSystem"`
+	Children []VirtualSystem `xml:"VirtualSystemCollection>VirtualSystem"`
+}
+
+// File of the appliance, such as a disk.
+type File struct {
+	ID   string `xml:"id,attr"`
+	Href string `xml:"href,attr"`
+	Size int64  `xml:"size,attr"`
+}
+
+// Virtual disk backed by a file.
+type Disk struct {
+	DiskID   string `xml:"diskId,attr"`
+	FileRef  string `xml:"fileRef,attr"`
+	Capacity string `xml:"capacity,attr"`
+}
+
+// Virtual machine of the appliance and its hardware.
+type VirtualSystem struct {
+	ID    string `xml:"id,attr"`
+	Items []Item `xml:"VirtualHardwareSection>Item"`
+}
+
+// Virtual hardware item, such as a CPU, memory or a network adapter.
+type Item struct {
+	ElementName     string `xml:"ElementName"`
+	ResourceType    int    `xml:"ResourceType"`
+	VirtualQuantity string `xml:"VirtualQuantity"`
+}
+
+// Disk of an OVA archive, read from the archive.
+type Archive struct {
+	Descriptor *Envelope
+	DiskName   string
+	DiskSize   int64
+	Disk       io.Reader
+}
+
+// Parses an OVF descriptor.
+func Parse(r io.Reader) (*Envelope, error) {
+	envelope := &Envelope{}
+	err := xml.NewDecoder(r).Decode(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("bad OVF descriptor: %s", err)
+	}
+	if len(envelope.Disks) == 0 {
+		return nil, fmt.Errorf("OVF descriptor has no disk")
+	}
+	return envelope, nil
+}
+
+// Returns the file of the first disk, which is the one imported.
+func (e *Envelope) DiskFile() (*File, error) {
+	disk := e.Disks[0]
+	for i := range e.Files {
+		if e.Files[i].ID == disk.FileRef {
+			return &e.Files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("disk '%s' of the OVF descriptor refers to unknown file '%s'", disk.DiskID, disk.FileRef)
+}
+
+// Returns a warning for each part of the appliance that is not imported.
+func (e *Envelope) Ignored() []string {
+	var warnings []string
+	for _, disk := range e.Disks[1:] {
+		warnings = append(warnings, fmt.Sprintf("Ignoring disk '%s': only the first disk is imported", disk.DiskID))
+	}
+	for _, system := range append(e.Systems, e.Children...) {
+		for _, item := range system.Items {
+			name := item.ElementName
+			if len(name) == 0 {
+				name = fmt.Sprintf("hardware of resource type %d", item.ResourceType)
+			}
+			switch item.ResourceType {
+			case resourceProcessor, resourceMemory:
+				warnings = append(warnings, fmt.Sprintf("Ignoring '%s': CPU and memory come from the VM flavor", name))
+			case resourceEthernetAdapter:
+				warnings = append(warnings, fmt.Sprintf("Ignoring '%s': VMs are connected to the subnets they are created on", name))
+			case resourceDiskDrive, resourceIDEController, resourceSCSIController, resourceSATAController:
+			default:
+				warnings = append(warnings, fmt.Sprintf("Ignoring '%s'", name))
+			}
+		}
+	}
+	return warnings
+}
+
+// Reads an OVA archive up to the disk to import. The OVF descriptor must come before
+// the disk in the archive, as the OVF specification requires.
+func OpenArchive(r io.Reader) (*Archive, error) {
+	archive := &Archive{}
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad OVA archive: %s", err)
+		}
+		name := path.Clean(header.Name)
+
+		if archive.Descriptor == nil {
+			if strings.HasSuffix(strings.ToLower(name), ".ovf") {
+				archive.Descriptor, err = Parse(reader)
+				if err != nil {
+					return nil, err
+				}
+				file, err := archive.Descriptor.DiskFile()
+				if err != nil {
+					return nil, err
+				}
+				archive.DiskName = path.Clean(path.Join(path.Dir(name), file.Href))
+			}
+			continue
+		}
+		if name == archive.DiskName {
+			archive.DiskSize = header.Size
+			archive.Disk = reader
+			return archive, nil
+		}
+	}
+
+	if archive.Descriptor == nil {
+		return nil, fmt.Errorf("OVA archive has no OVF descriptor")
+	}
+	return nil, fmt.Errorf("OVA archive has no disk file '%s' after its OVF descriptor", archive.DiskName)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2017 VMware, Inc. All Rights Reserved.
+//
+// This product is licensed to you under the Apache License, Version 2.0 (the "License").
+// You may not use this product except in compliance with the License.
+//
+// This product may include a number of subcomponents with separate copyright notices and
+// license terms. Your use of these subcomponents is subject to the terms and conditions
+// of the subcomponent's license, as noted in the LICENSE file.
+
+package ovf_test
+
+import (
+	. "github.com/vmware/photon-controller-cli/photon/ovf"
+
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const descriptor = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+    xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData">
+  <References>
+    <File ovf:id="file1" ovf:href="kube-disk1.vmdk" ovf:size="11"/>
+    <File ovf:id="file2" ovf:href="kube-disk2.vmdk"/>
+  </References>
+  <DiskSection>
+    <Disk ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:capacity="20"/>
+    <Disk ovf:diskId="vmdisk2" ovf:fileRef="file2" ovf:capacity="40"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="kube">
+    <VirtualHardwareSection>
+      <Item><rasd:ElementName>2 virtual CPU(s)</rasd:ElementName><rasd:ResourceType>3</rasd:ResourceType></Item>
+      <Item><rasd:ElementName>2048MB of memory</rasd:ElementName><rasd:ResourceType>4</rasd:ResourceType></Item>
+      <Item><rasd:ElementName>SCSI controller 0</rasd:ElementName><rasd:ResourceType>6</rasd:ResourceType></Item>
+      <Item><rasd:ElementName>Hard disk 1</rasd:ElementName><rasd:ResourceType>17</rasd:ResourceType></Item>
+      <Item><rasd:ElementName>Network adapter 1</rasd:ElementName><rasd:ResourceType>10</rasd:ResourceType></Item>
+      <Item><rasd:ResourceType>15</rasd:ResourceType></Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func archive(files ...string) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for i := 0; i < len(files); i += 2 {
+		err := w.WriteHeader(&tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1]))})
+		Expect(err).To(BeNil())
+		_, err = w.Write([]byte(files[i+1]))
+		Expect(err).To(BeNil())
+	}
+	Expect(w.Close()).To(BeNil())
+	return &buf
+}
+
+var _ = Describe("Ovf", func() {
+	Describe("Parse", func() {
+		It("finds the first disk", func() {
+			envelope, err := Parse(strings.NewReader(descriptor))
+			Expect(err).To(BeNil())
+			file, err := envelope.DiskFile()
+			Expect(err).To(BeNil())
+			Expect(file.Href).To(Equal("kube-disk1.vmdk"))
+			Expect(file.Size).To(Equal(int64(11)))
+		})
+
+		It("lists the ignored hardware", func() {
+			envelope, err := Parse(strings.NewReader(descriptor))
+			Expect(err).To(BeNil())
+			Expect(envelope.Ignored()).To(Equal([]string{
+				"Ignoring disk 'vmdisk2': only the first disk is imported",
+				"Ignoring '2 virtual CPU(s)': CPU and memory come from the VM flavor",
+				"Ignoring '2048MB of memory': CPU and memory come from the VM flavor",
+				"Ignoring 'Network adapter 1': VMs are connected to the subnets they are created on",
+				"Ignoring 'hardware of resource type 15'",
+			}))
+		})
+
+		It("rejects bad descriptors", func() {
+			_, err := Parse(strings.NewReader("<Envelope><References>"))
+			Expect(err.Error()).To(HavePrefix("bad OVF descriptor: "))
+
+			_, err = Parse(strings.NewReader("<Envelope></Envelope>"))
+			Expect(err).To(MatchError("OVF descriptor has no disk"))
+
+			envelope, err := Parse(strings.NewReader(`<Envelope><DiskSection><Disk diskId="d1" fileRef="f1"/></DiskSection></Envelope>`))
+			Expect(err).To(BeNil())
+			_, err = envelope.DiskFile()
+			Expect(err).To(MatchError("disk 'd1' of the OVF descriptor refers to unknown file 'f1'"))
+		})
+	})
+
+	Describe("OpenArchive", func() {
+		It("reads the disk after the descriptor", func() {
+			ova, err := OpenArchive(archive("kube.ovf", descriptor, "kube.mf", "SHA1(...)", "kube-disk1.vmdk", "disk 1 data"))
+			Expect(err).To(BeNil())
+			Expect(ova.DiskName).To(Equal("kube-disk1.vmdk"))
+			Expect(ova.DiskSize).To(Equal(int64(11)))
+			data, err := ioutil.ReadAll(ova.Disk)
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(Equal("disk 1 data"))
+		})
+
+		It("rejects archives without descriptor or disk", func() {
+			_, err := OpenArchive(archive("kube-disk1.vmdk", "disk 1 data"))
+			Expect(err).To(MatchError("OVA archive has no OVF descriptor"))
+
+			_, err = OpenArchive(archive("kube-disk1.vmdk", "disk 1 data", "kube.ovf", descriptor))
+			Expect(err).To(MatchError("OVA archive has no disk file 'kube-disk1.vmdk' after its OVF descriptor"))
+
+			_, err = OpenArchive(strings.NewReader("not an archive"))
+			Expect(err.Error()).To(HavePrefix("bad OVA archive: "))
+		})
+	})
+})
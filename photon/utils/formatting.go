@@ -40,7 +40,15 @@ func ValidateArgs(c *cli.Context) error {
 	if c.GlobalBool("non-interactive") == true && c.GlobalString("output") != "" {
 		return fmt.Errorf("--non-interactive and --output are mutually exclusive")
 	}
-	if c.GlobalString("output") != "" && c.GlobalString("output") != "json" {
+	switch c.GlobalString("output") {
+	case "", "json":
+	case "dot":
+		// Only the network topology can be printed as a Graphviz graph
+		args := c.Args()
+		if len(args) < 2 || args[0] != "network" || args[1] != "topology" {
+			return fmt.Errorf("output type 'dot' is only supported by 'network topology'")
+		}
+	default:
 		return fmt.Errorf("output type must be 'json'")
 	}
 	if c.GlobalBool("detail") == true && c.GlobalString("output") != "" {